@@ -0,0 +1,104 @@
+package psbt_sdk
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// checkAmount checks an amount is within 0 and 21M BTC
+func checkAmount(amount btcutil.Amount) error {
+	if amount < 0 {
+		return errors.New("negative amount")
+	}
+	if amount > btcutil.MaxSatoshi {
+		return errors.New(fmt.Sprintf("amount above %d", int64(btcutil.MaxSatoshi)))
+	}
+	return nil
+}
+
+// addAmount adds amount to a checked total, the sum can't overflow as both
+// stay within 21M BTC
+func addAmount(total, amount btcutil.Amount) (btcutil.Amount, error) {
+	if err := checkAmount(amount); err != nil {
+		return 0, err
+	}
+	if total > btcutil.MaxSatoshi-amount {
+		return 0, errors.New(fmt.Sprintf("total amount above %d", int64(btcutil.MaxSatoshi)))
+	}
+	return total + amount, nil
+}
+
+// checkSignInAmount checks the amount of a signIn and that it matches the
+// utxo value of the input already in the psbt
+func (s *PsbtBuilder) checkSignInAmount(v *InputSign) error {
+	if err := checkAmount(v.Amount); err != nil {
+		return &AmountError{Input: v.Index, Output: -1, Amount: v.Amount, Reason: err.Error()}
+	}
+	if prevOut := s.inputUtxo(v.Index); prevOut != nil && prevOut.Value != int64(v.Amount) {
+		return &AmountError{Input: v.Index, Output: -1, Amount: v.Amount,
+			Reason: fmt.Sprintf("does not match the utxo value %d", prevOut.Value)}
+	}
+	return nil
+}
+
+// checkOutputAmounts checks the amounts of outputs about to be added at
+// position first on
+func checkOutputAmounts(txOuts []*wire.TxOut, first int) error {
+	for i, txOut := range txOuts {
+		if err := checkAmount(btcutil.Amount(txOut.Value)); err != nil {
+			return &AmountError{Input: -1, Output: first + i, Amount: btcutil.Amount(txOut.Value), Reason: err.Error()}
+		}
+	}
+	return nil
+}
+
+// Fee returns the fee of the psbt, the inputs minus the outputs. It fails on
+// missing utxos, amounts out of range and outputs exceeding the inputs.
+func (s *PsbtBuilder) Fee() (btcutil.Amount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.fee()
+}
+
+func (s *PsbtBuilder) fee() (btcutil.Amount, error) {
+	inputs, outputs, missing, err := s.amounts()
+	if err != nil {
+		return 0, err
+	}
+	if missing >= 0 {
+		return 0, s.missingUtxoError(missing)
+	}
+	return inputs - outputs, nil
+}
+
+// amounts sums the known utxos and the outputs, missing is the index of the
+// first input without utxo or -1. Outputs may only exceed the inputs while
+// a utxo is missing.
+func (s *PsbtBuilder) amounts() (inputs, outputs btcutil.Amount, missing int, err error) {
+	missing = -1
+	for i := range s.PsbtUpdater.Upsbt.UnsignedTx.TxIn {
+		prevOut := s.inputUtxo(i)
+		if prevOut == nil {
+			if missing < 0 {
+				missing = i
+			}
+			continue
+		}
+		if inputs, err = addAmount(inputs, btcutil.Amount(prevOut.Value)); err != nil {
+			return 0, 0, 0, &AmountError{Input: i, Output: -1, Amount: btcutil.Amount(prevOut.Value), Reason: err.Error()}
+		}
+	}
+	for i, txOut := range s.PsbtUpdater.Upsbt.UnsignedTx.TxOut {
+		if outputs, err = addAmount(outputs, btcutil.Amount(txOut.Value)); err != nil {
+			return 0, 0, 0, &AmountError{Input: -1, Output: i, Amount: btcutil.Amount(txOut.Value), Reason: err.Error()}
+		}
+	}
+	if missing < 0 && outputs > inputs {
+		return 0, 0, 0, &AmountError{Input: -1, Output: -1, Amount: inputs - outputs,
+			Reason: fmt.Sprintf("outputs %d exceed inputs %d", int64(outputs), int64(inputs))}
+	}
+	return inputs, outputs, missing, nil
+}
@@ -0,0 +1,108 @@
+package psbt_sdk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestPsbtBuilder_OutputAmounts(t *testing.T) {
+	inputs := []Input{{OutTxId: "93b3484083c31769c6a82b9c1cb66979463d86b3edea5044dede1ffdb74c3db2"}}
+	for _, amount := range []btcutil.Amount{-1, btcutil.MaxSatoshi + 1} {
+		_, err := CreatePsbtBuilder(&chaincfg.SigNetParams, inputs, []Output{{Script: testP2wpkhScript(7), Amount: amount}})
+		var amountErr *AmountError
+		if !errors.Is(err, ErrInvalidAmount) || !errors.As(err, &amountErr) || amountErr.Output != 0 {
+			t.Fatalf("CreatePsbtBuilder(%d) error = %v, want AmountError", amount, err)
+		}
+	}
+
+	builder := testBuilder(t, 1)
+	err := builder.AddOutput([]Output{{Script: testP2wpkhScript(7), Amount: 1000}, {Script: testP2wpkhScript(7), Amount: -5}})
+	var amountErr *AmountError
+	if !errors.As(err, &amountErr) || amountErr.Output != 2 || len(builder.GetOutputs()) != 1 {
+		t.Fatalf("AddOutput() error = %v, want AmountError of output 2", err)
+	}
+
+	var signIn InputSign
+	if err = json.Unmarshal([]byte(`{"utxo_type":2,"amount":60000}`), &signIn); err != nil || signIn.Amount != 60000 {
+		t.Fatalf("json.Unmarshal() = %d, %v", signIn.Amount, err)
+	}
+}
+
+func TestPsbtBuilder_InputAmounts(t *testing.T) {
+	builder := testBuilder(t, 1)
+	signIn := &InputSign{UtxoType: Witness, Index: 0, PkScript: testP2wpkhScript(1), Amount: 40000,
+		SighashType: txscript.SigHashAll, PriHex: hex.EncodeToString(testPrivKey(1).Serialize())}
+	if err := builder.UpdateAndAddInputWitness([]*InputSign{signIn}); err != nil {
+		t.Fatalf("UpdateAndAddInputWitness() error = %v", err)
+	}
+	if _, err := builder.Fee(); !errors.Is(err, ErrInvalidAmount) {
+		t.Fatalf("Fee() error = %v, want outputs exceeding inputs", err)
+	}
+
+	signIn.Amount = 60000
+	err := builder.UpdateAndSignInput([]*InputSign{signIn})
+	var amountErr *AmountError
+	if !errors.As(err, &amountErr) || amountErr.Input != 0 || amountErr.Amount != 60000 {
+		t.Fatalf("UpdateAndSignInput() error = %v, want mismatch with the witness utxo", err)
+	}
+
+	// a legacy input is checked against its previous tx
+	prevTx := wire.NewMsgTx(2)
+	prevTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 3}, nil, nil))
+	prevTx.AddTxOut(wire.NewTxOut(25000, mustDecodeHex(t, testP2wpkhScript(2))))
+	var raw bytes.Buffer
+	if err = prevTx.Serialize(&raw); err != nil {
+		t.Fatalf("Serialize() error = %v", err)
+	}
+	if err = builder.AddInputOnly(Input{OutTxId: prevTx.TxHash().String()}); err != nil {
+		t.Fatalf("AddInputOnly() error = %v", err)
+	}
+	legacy := &InputSign{UtxoType: NonWitness, Index: 1, OutRaw: hex.EncodeToString(raw.Bytes()), Amount: 20000,
+		SighashType: txscript.SigHashAll}
+	if err = builder.UpdateAndAddInputWitness([]*InputSign{legacy}); !errors.As(err, &amountErr) || amountErr.Input != 1 {
+		t.Fatalf("UpdateAndAddInputWitness() error = %v, want mismatch with the previous tx", err)
+	}
+	legacy.Amount = 0
+	if err = builder.UpdateAndAddInputWitness([]*InputSign{legacy}); err != nil {
+		t.Fatalf("UpdateAndAddInputWitness() error = %v", err)
+	}
+	if fee, err := builder.Fee(); err != nil || fee != 15000 {
+		t.Fatalf("Fee() = %d, %v, want 15000", fee, err)
+	}
+}
+
+func TestPsbtBuilder_ExtractAmounts(t *testing.T) {
+	builder := testBuilder(t, 2)
+	if err := builder.UpdateAndSignInput(testSignIns(2)); err != nil {
+		t.Fatalf("UpdateAndSignInput() error = %v", err)
+	}
+	txHex, err := builder.ExtractPsbtTransaction()
+	if err != nil {
+		t.Fatalf("ExtractPsbtTransaction() error = %v", err)
+	}
+	// a finalized input without utxo doesn't stop the extraction
+	builder.PsbtUpdater.Upsbt.Inputs[0].WitnessUtxo = nil
+	if extracted, err := builder.ExtractPsbtTransaction(); err != nil || extracted != txHex {
+		t.Fatalf("ExtractPsbtTransaction() without utxo = %v, want the same tx", err)
+	}
+	if _, err = builder.Fee(); !errors.Is(err, ErrMissingUtxo) {
+		t.Fatalf("Fee() error = %v, want ErrMissingUtxo", err)
+	}
+
+	// outputs above the inputs are refused once every utxo is known
+	builder = testBuilder(t, 1)
+	if err = builder.UpdateAndSignInput(testSignIns(1)); err != nil {
+		t.Fatalf("UpdateAndSignInput() error = %v", err)
+	}
+	if _, err = builder.ExtractPsbtTransaction(); !errors.Is(err, ErrInvalidAmount) {
+		t.Fatalf("ExtractPsbtTransaction() of outputs above the inputs error = %v, want ErrInvalidAmount", err)
+	}
+}
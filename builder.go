@@ -2,6 +2,7 @@ package psbt_sdk
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -14,11 +15,24 @@ import (
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
+	"strconv"
+	"strings"
+	"sync"
 )
 
+// PsbtBuilder methods are safe for concurrent use. Reading or writing
+// PsbtUpdater directly bypasses the lock, hand out a Clone or a Snapshot
+// instead when other goroutines need the packet.
 type PsbtBuilder struct {
 	NetParams   *chaincfg.Params
 	PsbtUpdater *psbt.Updater
+
+	mu           sync.RWMutex
+	miniscripts  map[wire.OutPoint]*Miniscript
+	signWorkers  int
+	logger       Logger
+	logSensitive bool
+	guard        MutationGuard
 }
 
 // Create new psbt builder
@@ -61,6 +75,9 @@ func CreatePsbtBuilder(netParams *chaincfg.Params, ins []Input, outs []Output) (
 		txOut := wire.NewTxOut(int64(out.Amount), pkScript)
 		txOuts = append(txOuts, txOut)
 	}
+	if err := checkOutputAmounts(txOuts, 0); err != nil {
+		return nil, err
+	}
 
 	cPsbt, err := psbt.New(txIns, txOuts, int32(2), uint32(0), nSequences)
 	if err != nil {
@@ -77,84 +94,44 @@ func CreatePsbtBuilder(netParams *chaincfg.Params, ins []Input, outs []Output) (
 
 // add InputWitness without signed
 func (s *PsbtBuilder) UpdateAndAddInputWitness(signIns []*InputSign) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.resolveSignIns(signIns); err != nil {
+		return err
+	}
 	for _, v := range signIns {
 		switch v.UtxoType {
 		case NonWitness:
-			tx := wire.NewMsgTx(2)
-			nonWitnessUtxoHex, err := hex.DecodeString(v.OutRaw)
-			if err != nil {
-				return err
-			}
-			err = tx.Deserialize(bytes.NewReader(nonWitnessUtxoHex))
-			if err != nil {
-				return err
-			}
-			err = s.PsbtUpdater.AddInNonWitnessUtxo(tx, v.Index)
-			if err != nil {
-				return err
-			}
-			err = s.PsbtUpdater.AddInSighashType(v.SighashType, v.Index)
-			if err != nil {
+			if err := s.addInputNonWitnessUtxo(v); err != nil {
 				return err
 			}
-			break
 		case Witness, Taproot:
-			witnessUtxoScriptHex, err := hex.DecodeString(v.PkScript)
-			if err != nil {
-				return err
-			}
-			txOut := wire.TxOut{Value: int64(v.Amount), PkScript: witnessUtxoScriptHex}
-			err = s.PsbtUpdater.AddInWitnessUtxo(&txOut, v.Index)
-			if err != nil {
-				return err
-			}
-			err = s.PsbtUpdater.AddInSighashType(v.SighashType, v.Index)
-			if err != nil {
+			if err := s.addInputWitnessUtxo(v); err != nil {
 				return err
 			}
-			break
 		}
 	}
 	return nil
 }
 
 func (s *PsbtBuilder) UpdateAndSignTaprootInput(signIns []*InputSign) error {
-	var witnessScript []byte
-	_ = witnessScript
-
-	prevOutputFetcher := txscript.NewMultiPrevOutFetcher(nil)
-	for i, txIn := range s.PsbtUpdater.Upsbt.UnsignedTx.TxIn {
-		outPoint := txIn.PreviousOutPoint
-		txOut := s.PsbtUpdater.Upsbt.Inputs[i].WitnessUtxo
-		if txOut == nil {
-			txOut = s.PsbtUpdater.Upsbt.Inputs[i].NonWitnessUtxo.TxOut[outPoint.Index]
-		}
-		prevOutputFetcher.AddPrevOut(outPoint, txOut)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.resolveSignIns(signIns); err != nil {
+		return err
 	}
 	for _, v := range signIns {
-		pkScript, err := hex.DecodeString(v.PkScript)
-		if err != nil {
-			return err
-		}
-		outPoint := s.PsbtUpdater.Upsbt.UnsignedTx.TxIn[v.Index].PreviousOutPoint
-		txOut := wire.TxOut{Value: int64(v.Amount), PkScript: pkScript}
-		err = s.PsbtUpdater.AddInWitnessUtxo(&txOut, v.Index)
-		if err != nil {
+		if err := s.addInputWitnessUtxo(v); err != nil {
 			return err
 		}
-		err = s.PsbtUpdater.AddInSighashType(v.SighashType, v.Index)
-		if err != nil {
-			return err
-		}
-		prevOutputFetcher.AddPrevOut(outPoint, &txOut)
 	}
+	cache := s.newSigHashCache()
 
 	for _, v := range signIns {
 		var (
 			taprootKeySpendSig []byte
 			err                error
 		)
-		//fmt.Printf("UpdateAndSignInput - signIn: %+v\n", v)
 		privateKeyBytes, err := hex.DecodeString(v.PriHex)
 		if err != nil {
 			return err
@@ -162,31 +139,16 @@ func (s *PsbtBuilder) UpdateAndSignTaprootInput(signIns []*InputSign) error {
 		privateKey, _ := btcec.PrivKeyFromBytes(privateKeyBytes)
 		switch v.UtxoType {
 		case Taproot:
-			pkScript, err := hex.DecodeString(v.PkScript)
-			if err != nil {
-				return err
-			}
-
-			outPoint := s.PsbtUpdater.Upsbt.UnsignedTx.TxIn[v.Index].PreviousOutPoint
-			txOut := wire.TxOut{Value: int64(v.Amount), PkScript: pkScript}
-			err = s.PsbtUpdater.AddInWitnessUtxo(&txOut, v.Index)
-			if err != nil {
-				return err
-			}
-			err = s.PsbtUpdater.AddInSighashType(v.SighashType, v.Index)
-			if err != nil {
+			if err = cache.checkTaproot(v.Index); err != nil {
 				return err
 			}
-			prevOutputFetcher.AddPrevOut(outPoint, &txOut)
-			sigHashes := txscript.NewTxSigHashes(s.PsbtUpdater.Upsbt.UnsignedTx, prevOutputFetcher)
-
 			if v.RedeemScript != "" {
 				redeemScript, err := hex.DecodeString(v.RedeemScript)
 				if err != nil {
 					return err
 				}
-				witnessArray, err := txscript.CalcTapscriptSignaturehash(sigHashes,
-					v.SighashType, s.PsbtUpdater.Upsbt.UnsignedTx, v.Index, prevOutputFetcher, txscript.NewBaseTapLeaf(redeemScript))
+				witnessArray, err := txscript.CalcTapscriptSignaturehash(cache.sigHashes,
+					v.SighashType, s.PsbtUpdater.Upsbt.UnsignedTx, v.Index, cache.prevOutputFetcher, txscript.NewBaseTapLeaf(redeemScript))
 				if err != nil {
 					return err
 				}
@@ -220,25 +182,19 @@ func (s *PsbtBuilder) UpdateAndSignTaprootInput(signIns []*InputSign) error {
 				})
 				s.PsbtUpdater.Upsbt.Inputs[v.Index].TaprootLeafScript = newTaprootLeafScript
 			} else {
-				witness, err := txscript.TaprootWitnessSignature(s.PsbtUpdater.Upsbt.UnsignedTx, sigHashes,
-					v.Index, s.PsbtUpdater.Upsbt.Inputs[v.Index].WitnessUtxo.Value,
-					s.PsbtUpdater.Upsbt.Inputs[v.Index].WitnessUtxo.PkScript,
-					v.SighashType, privateKey)
+				taprootKeySpendSig, err = signTaprootKeySpend(s.PsbtUpdater.Upsbt.UnsignedTx, cache.sigHashes,
+					v.Index, s.PsbtUpdater.Upsbt.Inputs[v.Index].WitnessUtxo, v.SighashType, privateKey)
 				if err != nil {
 					return err
 				}
-				taprootKeySpendSig = witness[0]
 				s.PsbtUpdater.Upsbt.Inputs[v.Index].TaprootKeySpendSig = taprootKeySpendSig
 			}
-
-			fmt.Printf("TaprootWitnessSignature[%d]: %s\n", v.Index, hex.EncodeToString(taprootKeySpendSig))
+			s.logInput("psbt input signed", v.Index, "utxo_type", v.UtxoType, "sighash_type", v.SighashType,
+				"signature", s.sensitive(taprootKeySpendSig))
 			break
 		}
-		//fmt.Printf("index:%d\n, pri:%s\n, pub:%s\n, sigScript: %s\n", v.Index, v.PriHex, publicKey, hex.EncodeToString(sigScript))
-		_, err = psbt.MaybeFinalize(s.PsbtUpdater.Upsbt, v.Index)
-		if err != nil {
-			fmt.Printf("Index-[%d] %s\n", v.Index, s.PsbtUpdater.Upsbt.UnsignedTx.TxIn[v.Index].PreviousOutPoint.String())
-			return errors.New(fmt.Sprintf("Index-[%d] %s", v.Index, err))
+		if err = s.maybeFinalize(v.Index); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -246,153 +202,43 @@ func (s *PsbtBuilder) UpdateAndSignTaprootInput(signIns []*InputSign) error {
 
 // add InputWitness with signed
 func (s *PsbtBuilder) UpdateAndSignInput(signIns []*InputSign) error {
-	var witnessScript []byte
-	var pubByte []byte
-	var redeemScript []byte
-	multiPrevOutputFetcher := txscript.NewMultiPrevOutFetcher(nil)
-	for i, txIn := range s.PsbtUpdater.Upsbt.UnsignedTx.TxIn {
-		outPoint := txIn.PreviousOutPoint
-		txOut := s.PsbtUpdater.Upsbt.Inputs[i].WitnessUtxo
-		if txOut == nil {
-			if s.PsbtUpdater.Upsbt.Inputs[i].NonWitnessUtxo != nil {
-				txOut = s.PsbtUpdater.Upsbt.Inputs[i].NonWitnessUtxo.TxOut[outPoint.Index]
-			}
-		}
-		multiPrevOutputFetcher.AddPrevOut(outPoint, txOut)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.resolveSignIns(signIns); err != nil {
+		return err
 	}
-
 	for _, v := range signIns {
-		if v.PkScript != "" {
-			pkScript, err := hex.DecodeString(v.PkScript)
-			if err != nil {
-				return err
-			}
-			outPoint := s.PsbtUpdater.Upsbt.UnsignedTx.TxIn[v.Index].PreviousOutPoint
-			txOut := wire.TxOut{Value: int64(v.Amount), PkScript: pkScript}
-			err = s.PsbtUpdater.AddInWitnessUtxo(&txOut, v.Index)
-			if err != nil {
-				return err
-			}
-			err = s.PsbtUpdater.AddInSighashType(v.SighashType, v.Index)
-			if err != nil {
-				return err
-			}
-			multiPrevOutputFetcher.AddPrevOut(outPoint, &txOut)
+		if err := s.addSignInUtxo(v); err != nil {
+			return err
 		}
 	}
-
-	for _, v := range signIns {
-		privateKeyBytes, err := hex.DecodeString(v.PriHex)
-		if err != nil {
+	sigs, err := s.calcInputSigs(signIns, s.newSigHashCache())
+	if err != nil {
+		return err
+	}
+	for _, sig := range sigs {
+		if err = s.applyInputSig(sig); err != nil {
 			return err
 		}
-		privateKey, _ := btcec.PrivKeyFromBytes(privateKeyBytes)
-		sigScript := []byte{}
-		pubByte = privateKey.PubKey().SerializeCompressed()
-		if v.RedeemScript != "" {
-			redeemScript, err = hex.DecodeString(v.RedeemScript)
-			if err != nil {
-				return err
-			}
-		}
-		switch v.UtxoType {
-		case NonWitness:
-			tx := wire.NewMsgTx(2)
-			nonWitnessUtxoHex, err := hex.DecodeString(v.OutRaw)
-			if err != nil {
-				return err
-			}
-			err = tx.Deserialize(bytes.NewReader(nonWitnessUtxoHex))
-			if err != nil {
-				return err
-			}
-			err = s.PsbtUpdater.AddInNonWitnessUtxo(tx, v.Index)
-			if err != nil {
-				return err
-			}
-			err = s.PsbtUpdater.AddInSighashType(v.SighashType, v.Index)
-			if err != nil {
-				return err
-			}
-			sigScript, err = txscript.RawTxInSignature(s.PsbtUpdater.Upsbt.UnsignedTx, v.Index, s.PsbtUpdater.Upsbt.Inputs[v.Index].NonWitnessUtxo.TxOut[s.PsbtUpdater.Upsbt.UnsignedTx.TxIn[v.Index].PreviousOutPoint.Index].PkScript, v.SighashType, privateKey)
-			if err != nil {
-				return err
-			}
-			break
-		case Witness:
-			witnessUtxoScriptHex, err := hex.DecodeString(v.PkScript)
-			if err != nil {
-				return err
-			}
-			txOut := wire.TxOut{Value: int64(v.Amount), PkScript: witnessUtxoScriptHex}
-			err = s.PsbtUpdater.AddInWitnessUtxo(&txOut, v.Index)
-			if err != nil {
-				return err
-			}
-			err = s.PsbtUpdater.AddInSighashType(v.SighashType, v.Index)
-			if err != nil {
-				return err
-			}
-			prevOutputFetcher := NewPrevOutputFetcher(s.PsbtUpdater.Upsbt.Inputs[v.Index].WitnessUtxo.PkScript, s.PsbtUpdater.Upsbt.Inputs[v.Index].WitnessUtxo.Value)
-			sigHashes := txscript.NewTxSigHashes(s.PsbtUpdater.Upsbt.UnsignedTx, prevOutputFetcher)
-			sigScript, err = txscript.RawTxInWitnessSignature(s.PsbtUpdater.Upsbt.UnsignedTx, sigHashes,
-				v.Index, s.PsbtUpdater.Upsbt.Inputs[v.Index].WitnessUtxo.Value,
-				s.PsbtUpdater.Upsbt.Inputs[v.Index].WitnessUtxo.PkScript,
-				v.SighashType, privateKey)
-			if err != nil {
-				return err
-			}
-			break
-		case Taproot:
-			pkScript, err := hex.DecodeString(v.PkScript)
-			if err != nil {
-				return err
-			}
-
-			outPoint := s.PsbtUpdater.Upsbt.UnsignedTx.TxIn[v.Index].PreviousOutPoint
-			txOut := wire.TxOut{Value: int64(v.Amount), PkScript: pkScript}
-			err = s.PsbtUpdater.AddInWitnessUtxo(&txOut, v.Index)
-			if err != nil {
-				return err
-			}
-			err = s.PsbtUpdater.AddInSighashType(v.SighashType, v.Index)
-			if err != nil {
-				return err
-			}
-			multiPrevOutputFetcher.AddPrevOut(outPoint, &txOut)
-			sigHashes := txscript.NewTxSigHashes(s.PsbtUpdater.Upsbt.UnsignedTx, multiPrevOutputFetcher)
-			witness, err := txscript.TaprootWitnessSignature(s.PsbtUpdater.Upsbt.UnsignedTx, sigHashes,
-				v.Index, s.PsbtUpdater.Upsbt.Inputs[v.Index].WitnessUtxo.Value,
-				s.PsbtUpdater.Upsbt.Inputs[v.Index].WitnessUtxo.PkScript,
-				v.SighashType, privateKey)
-			if err != nil {
-				return err
-			}
-			witnessScript = witness[0]
-			break
-		}
-
-		if v.UtxoType == Taproot {
-			s.PsbtUpdater.Upsbt.Inputs[v.Index].TaprootKeySpendSig = witnessScript
-		} else {
-			res, err := s.PsbtUpdater.Sign(v.Index, sigScript, pubByte, redeemScript, nil)
-			if err != nil || res != 0 {
-				fmt.Printf("Index-[%d] %s  %s, SignOutcome:%d\n", v.Index, s.PsbtUpdater.Upsbt.UnsignedTx.TxIn[v.Index].PreviousOutPoint.String(), err, res)
-				return errors.New(fmt.Sprintf("Sign:Index-[%d] %s, SignOutcome:%d", v.Index, err, res))
-			}
-		}
-		_, err = psbt.MaybeFinalize(s.PsbtUpdater.Upsbt, v.Index)
-		if err != nil {
-			return errors.New(fmt.Sprintf("Index-[%d] %s", v.Index, err))
-		}
 	}
 	return nil
 }
 
 func (s *PsbtBuilder) UpdateAndSignInputNoFinalize(signIns []*InputSign) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.resolveSignIns(signIns); err != nil {
+		return err
+	}
+	for _, v := range signIns {
+		if err := s.addSignInUtxo(v); err != nil {
+			return err
+		}
+	}
+	cache := s.newSigHashCache()
+
 	for _, v := range signIns {
 		var redeemScript []byte
-		//fmt.Printf("UpdateAndSignInput - signIn: %+v\n", v)
 		privateKeyBytes, err := hex.DecodeString(v.PriHex)
 		if err != nil {
 			return err
@@ -407,50 +253,17 @@ func (s *PsbtBuilder) UpdateAndSignInputNoFinalize(signIns []*InputSign) error {
 		}
 		switch v.UtxoType {
 		case NonWitness:
-			tx := wire.NewMsgTx(2)
-			nonWitnessUtxoHex, err := hex.DecodeString(v.OutRaw)
-			if err != nil {
-				return err
-			}
-			err = tx.Deserialize(bytes.NewReader(nonWitnessUtxoHex))
-			if err != nil {
-				return err
-			}
-			err = s.PsbtUpdater.AddInNonWitnessUtxo(tx, v.Index)
-			if err != nil {
-				return err
-			}
-			err = s.PsbtUpdater.AddInSighashType(v.SighashType, v.Index)
-			if err != nil {
-				return err
-			}
-			sigScript, err = txscript.RawTxInSignature(s.PsbtUpdater.Upsbt.UnsignedTx, v.Index, s.PsbtUpdater.Upsbt.Inputs[v.Index].NonWitnessUtxo.TxOut[s.PsbtUpdater.Upsbt.UnsignedTx.TxIn[v.Index].PreviousOutPoint.Index].PkScript, v.SighashType, privateKey)
+			sigScript, err = txscript.RawTxInSignature(s.PsbtUpdater.Upsbt.UnsignedTx, v.Index, s.inputUtxo(v.Index).PkScript, v.SighashType, privateKey)
 			if err != nil {
 				return err
 			}
 			break
 		case Witness:
-			witnessUtxoScriptHex, err := hex.DecodeString(
-				v.PkScript)
-			if err != nil {
-				return err
-			}
-			txOut := wire.TxOut{Value: int64(v.Amount), PkScript: witnessUtxoScriptHex}
-			err = s.PsbtUpdater.AddInWitnessUtxo(&txOut, v.Index)
-			if err != nil {
-				return err
-			}
-			err = s.PsbtUpdater.AddInSighashType(v.SighashType, v.Index)
-			if err != nil {
-				return err
-			}
 			subScript := s.PsbtUpdater.Upsbt.Inputs[v.Index].WitnessUtxo.PkScript
 			if redeemScript != nil {
 				subScript = redeemScript
 			}
-			prevOutputFetcher := NewPrevOutputFetcher(s.PsbtUpdater.Upsbt.Inputs[v.Index].WitnessUtxo.PkScript, s.PsbtUpdater.Upsbt.Inputs[v.Index].WitnessUtxo.Value)
-			sigHashes := txscript.NewTxSigHashes(s.PsbtUpdater.Upsbt.UnsignedTx, prevOutputFetcher)
-			sigScript, err = txscript.RawTxInWitnessSignature(s.PsbtUpdater.Upsbt.UnsignedTx, sigHashes,
+			sigScript, err = txscript.RawTxInWitnessSignature(s.PsbtUpdater.Upsbt.UnsignedTx, cache.sigHashes,
 				v.Index, s.PsbtUpdater.Upsbt.Inputs[v.Index].WitnessUtxo.Value,
 				subScript,
 				v.SighashType, privateKey)
@@ -460,17 +273,12 @@ func (s *PsbtBuilder) UpdateAndSignInputNoFinalize(signIns []*InputSign) error {
 			break
 		}
 
-		publicKey := hex.EncodeToString(privateKey.PubKey().SerializeCompressed())
-		pubByte, err := hex.DecodeString(publicKey)
-		if err != nil {
-			return err
-		}
+		pubByte := privateKey.PubKey().SerializeCompressed()
 		res, err := s.PsbtUpdater.Sign(v.Index, sigScript, pubByte, nil, nil)
-		if err != nil || res != 0 {
+		if err = s.signOutcomeError(v.Index, res, err); err != nil {
 			return err
 		}
-		_, err = psbt.MaybeFinalize(s.PsbtUpdater.Upsbt, v.Index)
-		if err != nil {
+		if err = s.maybeFinalize(v.Index); err != nil {
 			return err
 		}
 	}
@@ -478,11 +286,22 @@ func (s *PsbtBuilder) UpdateAndSignInputNoFinalize(signIns []*InputSign) error {
 }
 
 func (s *PsbtBuilder) UpdateAndMultiSignInput(signIns []*InputSign) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.resolveSignIns(signIns); err != nil {
+		return err
+	}
+	for _, v := range signIns {
+		if err := s.addInputWitnessUtxo(v); err != nil {
+			return err
+		}
+	}
+	cache := s.newSigHashCache()
+
 	for _, v := range signIns {
 		var (
 			multiSigScriptByte []byte
-			//preSigScriptBytes  []byte
-			err error
+			err                error
 		)
 		if v.MultiSigScript != "" {
 			multiSigScriptByte, err = hex.DecodeString(v.MultiSigScript)
@@ -497,25 +316,7 @@ func (s *PsbtBuilder) UpdateAndMultiSignInput(signIns []*InputSign) error {
 		}
 		privateKey, _ := btcec.PrivKeyFromBytes(privateKeyBytes)
 
-		sigScript := []byte{}
-		witnessUtxoScriptHex, err := hex.DecodeString(
-			v.PkScript)
-		if err != nil {
-			return err
-		}
-		txOut := wire.TxOut{Value: int64(v.Amount), PkScript: witnessUtxoScriptHex}
-		err = s.PsbtUpdater.AddInWitnessUtxo(&txOut, v.Index)
-		if err != nil {
-			return err
-		}
-		err = s.PsbtUpdater.AddInSighashType(v.SighashType, v.Index)
-		if err != nil {
-			return err
-		}
-
-		prevOutputFetcher := NewPrevOutputFetcher(s.PsbtUpdater.Upsbt.Inputs[v.Index].WitnessUtxo.PkScript, s.PsbtUpdater.Upsbt.Inputs[v.Index].WitnessUtxo.Value)
-		sigHashes := txscript.NewTxSigHashes(s.PsbtUpdater.Upsbt.UnsignedTx, prevOutputFetcher)
-		sigScript, err = txscript.RawTxInWitnessSignature(s.PsbtUpdater.Upsbt.UnsignedTx, sigHashes,
+		sigScript, err := txscript.RawTxInWitnessSignature(s.PsbtUpdater.Upsbt.UnsignedTx, cache.sigHashes,
 			v.Index, s.PsbtUpdater.Upsbt.Inputs[v.Index].WitnessUtxo.Value,
 			multiSigScriptByte,
 			v.SighashType, privateKey)
@@ -523,61 +324,269 @@ func (s *PsbtBuilder) UpdateAndMultiSignInput(signIns []*InputSign) error {
 			return err
 		}
 
-		publicKey := hex.EncodeToString(privateKey.PubKey().SerializeCompressed())
-		pubByte, err := hex.DecodeString(publicKey)
-		if err != nil {
+		pubByte := privateKey.PubKey().SerializeCompressed()
+		res, err := s.PsbtUpdater.Sign(v.Index, sigScript, pubByte, nil, multiSigScriptByte)
+		if err = s.signOutcomeError(v.Index, res, err); err != nil {
 			return err
 		}
-		//fmt.Printf("index:%d\n, pri:%s\n, pub:%s\n, sigScript: %s\n", v.Index, v.PriHex, publicKey, hex.EncodeToString(sigScript))
-		res, err := s.PsbtUpdater.Sign(v.Index, sigScript, pubByte, nil, multiSigScriptByte)
-		if err != nil || res != 0 {
+	}
+	return nil
+}
+
+// IndexOfOutPoint returns the position of the input spending outPoint (txid:vout)
+func (s *PsbtBuilder) IndexOfOutPoint(outPoint string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.indexOfOutPoint(outPoint)
+}
+
+func (s *PsbtBuilder) indexOfOutPoint(outPoint string) (int, error) {
+	op, err := parseOutPoint(outPoint)
+	if err != nil {
+		return 0, &UnknownOutPointError{OutPoint: outPoint, Err: err}
+	}
+	for i, txIn := range s.PsbtUpdater.Upsbt.UnsignedTx.TxIn {
+		if txIn.PreviousOutPoint == *op {
+			return i, nil
+		}
+	}
+	return 0, &UnknownOutPointError{OutPoint: outPoint}
+}
+
+func parseOutPoint(outPoint string) (*wire.OutPoint, error) {
+	sep := strings.LastIndexByte(outPoint, ':')
+	if sep < 0 {
+		return nil, errors.New("outpoint should be txid:vout")
+	}
+	txHash, err := chainhash.NewHashFromStr(outPoint[:sep])
+	if err != nil {
+		return nil, err
+	}
+	vout, err := strconv.ParseUint(outPoint[sep+1:], 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	return wire.NewOutPoint(txHash, uint32(vout)), nil
+}
+
+// resolveSignIns sets the Index of every signIn that selects its input by
+// outpoint and checks the index of the others
+func (s *PsbtBuilder) resolveSignIns(signIns []*InputSign) error {
+	for _, v := range signIns {
+		if v.OutPoint != "" {
+			index, err := s.indexOfOutPoint(v.OutPoint)
+			if err != nil {
+				return err
+			}
+			v.Index = index
+		}
+		if err := s.checkInputIndex(v.Index); err != nil {
 			return err
 		}
+	}
+	return nil
+}
+
+// addSignInUtxo adds the utxo of signIn, the previous transaction for
+// NonWitness inputs and the witness utxo otherwise
+func (s *PsbtBuilder) addSignInUtxo(v *InputSign) error {
+	if v.UtxoType == NonWitness {
+		return s.addInputNonWitnessUtxo(v)
+	}
+	return s.addInputWitnessUtxo(v)
+}
 
+func (s *PsbtBuilder) addInputNonWitnessUtxo(v *InputSign) error {
+	if err := s.checkInputIndex(v.Index); err != nil {
+		return err
 	}
+	tx := wire.NewMsgTx(2)
+	nonWitnessUtxoHex, err := hex.DecodeString(v.OutRaw)
+	if err != nil {
+		return err
+	}
+	err = tx.Deserialize(bytes.NewReader(nonWitnessUtxoHex))
+	if err != nil {
+		return err
+	}
+	vout := s.outPoint(v.Index).Index
+	if int(vout) >= len(tx.TxOut) {
+		return s.inputError(v.Index, errors.New(fmt.Sprintf("previous tx has no output %d", vout)))
+	}
+	if v.Amount != 0 && tx.TxOut[vout].Value != int64(v.Amount) {
+		return &AmountError{Input: v.Index, Output: -1, Amount: v.Amount,
+			Reason: fmt.Sprintf("does not match the utxo value %d", tx.TxOut[vout].Value)}
+	}
+	err = s.PsbtUpdater.AddInNonWitnessUtxo(tx, v.Index)
+	if err != nil {
+		return err
+	}
+	if err = s.PsbtUpdater.AddInSighashType(v.SighashType, v.Index); err != nil {
+		return err
+	}
+	s.logInput("psbt input updated", v.Index, "utxo_type", v.UtxoType, "sighash_type", v.SighashType)
 	return nil
 }
 
+func (s *PsbtBuilder) addInputWitnessUtxo(v *InputSign) error {
+	if err := s.checkInputIndex(v.Index); err != nil {
+		return err
+	}
+	if err := s.checkSignInAmount(v); err != nil {
+		return err
+	}
+	witnessUtxoScriptHex, err := hex.DecodeString(v.PkScript)
+	if err != nil {
+		return err
+	}
+	txOut := wire.TxOut{Value: int64(v.Amount), PkScript: witnessUtxoScriptHex}
+	err = s.PsbtUpdater.AddInWitnessUtxo(&txOut, v.Index)
+	if err != nil {
+		return err
+	}
+	if err = s.PsbtUpdater.AddInSighashType(v.SighashType, v.Index); err != nil {
+		return err
+	}
+	s.logInput("psbt input updated", v.Index, "utxo_type", v.UtxoType, "sighash_type", v.SighashType,
+		"amount", txOut.Value, "pk_script", s.sensitive(txOut.PkScript))
+	return nil
+}
+
+// maybeFinalize finalizes the input when it carries enough signatures
+func (s *PsbtBuilder) maybeFinalize(index int) error {
+	if _, err := psbt.MaybeFinalize(s.PsbtUpdater.Upsbt, index); err != nil {
+		return &FinalizeError{Index: index, OutPoint: s.outPoint(index), Err: err}
+	}
+	if s.PsbtUpdater.Upsbt.Inputs[index].FinalScriptSig != nil || s.PsbtUpdater.Upsbt.Inputs[index].FinalScriptWitness != nil {
+		s.logInput("psbt input finalized", index)
+	}
+	return nil
+}
+
+// signInput signs an input whose utxo is already set with the midstates of
+// the signing session and finalizes it
+func (s *PsbtBuilder) signInput(v *InputSign, cache *sigHashCache) error {
+	sig, err := s.calcInputSig(v, cache)
+	if err != nil {
+		return err
+	}
+	return s.applyInputSig(sig)
+}
+
 func (s *PsbtBuilder) AddSinInStruct(sigIn *SigIn) error {
 	return s.AddSigIn(sigIn.WitnessUtxo, sigIn.SighashType, sigIn.FinalScriptWitness, sigIn.Index)
 }
 
+// AddSigIn sets a final witness produced outside of the builder, the witness
+// is run against the utxo and rejected when it does not satisfy it
 func (s *PsbtBuilder) AddSigIn(witnessUtxo *wire.TxOut, sighashType txscript.SigHashType, finalScriptWitness []byte, index int) error {
-	s.PsbtUpdater.Upsbt.Inputs[index].SighashType = sighashType
-	s.PsbtUpdater.Upsbt.Inputs[index].WitnessUtxo = witnessUtxo
-	s.PsbtUpdater.Upsbt.Inputs[index].FinalScriptWitness = finalScriptWitness
-	if err := s.PsbtUpdater.Upsbt.SanityCheck(); err != nil {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkInputIndex(index); err != nil {
+		return err
+	}
+	pIn := &s.PsbtUpdater.Upsbt.Inputs[index]
+	old := *pIn
+	pIn.SighashType = sighashType
+	pIn.WitnessUtxo = witnessUtxo
+	pIn.FinalScriptWitness = finalScriptWitness
+	if err := s.checkAddedInput(index); err != nil {
+		*pIn = old
 		return err
 	}
 	return nil
 }
 
+// AddMultiSigIn sets the witness script of a p2wsh multisig input, signatures
+// are added with AddSignature
 func (s *PsbtBuilder) AddMultiSigIn(witnessUtxo *wire.TxOut, sighashType txscript.SigHashType, ScriptWitness []byte, index int) error {
-	s.PsbtUpdater.Upsbt.Inputs[index].SighashType = sighashType
-	s.PsbtUpdater.Upsbt.Inputs[index].WitnessUtxo = witnessUtxo
-	s.PsbtUpdater.Upsbt.Inputs[index].WitnessScript = ScriptWitness
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkInputIndex(index); err != nil {
+		return err
+	}
+	if witnessUtxo != nil && txscript.IsPayToWitnessScriptHash(witnessUtxo.PkScript) {
+		scriptHash := sha256.Sum256(ScriptWitness)
+		if !bytes.Equal(witnessUtxo.PkScript[2:], scriptHash[:]) {
+			return s.inputError(index, errors.New("witness script does not match the p2wsh utxo"))
+		}
+	}
+	pIn := &s.PsbtUpdater.Upsbt.Inputs[index]
+	old := *pIn
+	pIn.SighashType = sighashType
+	pIn.WitnessUtxo = witnessUtxo
+	pIn.WitnessScript = ScriptWitness
 	if err := s.PsbtUpdater.Upsbt.SanityCheck(); err != nil {
+		*pIn = old
 		return err
 	}
 	return nil
 }
 
+// AddSigInForNonWitnessUtxo adds the previous transaction of a legacy input
+// together with its partial signatures, every signature is checked against the
+// sighash. A finalScriptSig is used instead when no partial signature is given.
 func (s *PsbtBuilder) AddSigInForNonWitnessUtxo(nonWitnessUtxo *wire.MsgTx, partialSigs []*psbt.PartialSig, sighashType txscript.SigHashType, finalScriptSig []byte, index int) error {
-	s.PsbtUpdater.Upsbt.Inputs[index].PartialSigs = partialSigs
-	s.PsbtUpdater.Upsbt.Inputs[index].SighashType = sighashType
-	s.PsbtUpdater.Upsbt.Inputs[index].NonWitnessUtxo = nonWitnessUtxo
-	if err := s.PsbtUpdater.Upsbt.SanityCheck(); err != nil {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkInputIndex(index); err != nil {
 		return err
 	}
-	res, err := s.PsbtUpdater.Sign(index, partialSigs[0].Signature, partialSigs[0].PubKey, nil, nil)
-	if err != nil || res != 0 {
-		return err
+	if nonWitnessUtxo == nil {
+		return s.missingUtxoError(index)
+	}
+	outPoint := s.PsbtUpdater.Upsbt.UnsignedTx.TxIn[index].PreviousOutPoint
+	if nonWitnessUtxo.TxHash() != outPoint.Hash || int(outPoint.Index) >= len(nonWitnessUtxo.TxOut) {
+		return s.inputError(index, errors.New("non witness utxo does not match the outpoint"))
+	}
+	if len(partialSigs) == 0 && finalScriptSig == nil {
+		return s.inputError(index, errors.New("no signature"))
 	}
 
+	pIn := &s.PsbtUpdater.Upsbt.Inputs[index]
+	old := *pIn
+	pIn.SighashType = sighashType
+	pIn.NonWitnessUtxo = nonWitnessUtxo
+	if len(partialSigs) == 0 {
+		pIn.FinalScriptSig = finalScriptSig
+		if err := s.checkAddedInput(index); err != nil {
+			*pIn = old
+			return err
+		}
+		return nil
+	}
+	for _, v := range partialSigs {
+		if err := s.addSignature(index, v.PubKey, v.Signature, nil); err != nil {
+			*pIn = old
+			return err
+		}
+	}
+	return nil
+}
+
+// checkAddedInput sanity checks the packet and runs a finalized input through
+// the script engine. Taproot inputs are only checked once every utxo is known.
+func (s *PsbtBuilder) checkAddedInput(index int) error {
+	if err := s.PsbtUpdater.Upsbt.SanityCheck(); err != nil {
+		return err
+	}
+	prevOut := s.inputUtxo(index)
+	if prevOut == nil {
+		return s.missingUtxoError(index)
+	}
+	cache := s.newSigHashCache()
+	if !cache.complete && txscript.IsPayToTaproot(prevOut.PkScript) {
+		return nil
+	}
+	if err := s.verifyFinalizedInput(index, prevOut, cache); err != nil {
+		return &InvalidSignatureError{Index: index, OutPoint: s.outPoint(index), Err: err}
+	}
 	return nil
 }
 
 func (s *PsbtBuilder) ToString() (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	var b bytes.Buffer
 	err := s.PsbtUpdater.Upsbt.Serialize(&b)
 	if err != nil {
@@ -605,148 +614,28 @@ func NewPsbtBuilder(netParams *chaincfg.Params, psbtHex string) (*PsbtBuilder, e
 }
 
 func (s *PsbtBuilder) GetInputs() []*wire.TxIn {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.PsbtUpdater.Upsbt.UnsignedTx.TxIn
 }
 
 func (s *PsbtBuilder) GetOutputs() []*wire.TxOut {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.PsbtUpdater.Upsbt.UnsignedTx.TxOut
 }
 
+// AddInput appends an input and signs it, signIn.Index is set to the
+// position of the new input
 func (s *PsbtBuilder) AddInput(in Input, signIn *InputSign) error {
-	txHash, err := chainhash.NewHashFromStr(in.OutTxId)
-	if err != nil {
-		return err
-	}
-	s.PsbtUpdater.Upsbt.UnsignedTx.AddTxIn(&wire.TxIn{
-		PreviousOutPoint: *wire.NewOutPoint(txHash, in.OutIndex),
-		Sequence:         wire.MaxTxInSequenceNum,
-	})
-	s.PsbtUpdater.Upsbt.Inputs = append(s.PsbtUpdater.Upsbt.Inputs, psbt.PInput{})
-
-	multiPrevOutputFetcher := txscript.NewMultiPrevOutFetcher(nil)
-	if signIn.UtxoType == Taproot {
-		for i, txIn := range s.PsbtUpdater.Upsbt.UnsignedTx.TxIn {
-			outPoint := txIn.PreviousOutPoint
-			txOut := s.PsbtUpdater.Upsbt.Inputs[i].WitnessUtxo
-			multiPrevOutputFetcher.AddPrevOut(outPoint, txOut)
-		}
-	}
-
-	privateKeyBytes, err := hex.DecodeString(signIn.PriHex)
-	if err != nil {
-		return err
-	}
-	privateKey, _ := btcec.PrivKeyFromBytes(privateKeyBytes)
-	sigScript := []byte{}
-	var witnessScript wire.TxWitness
-	switch signIn.UtxoType {
-	case NonWitness:
-		tx := wire.NewMsgTx(2)
-		nonWitnessUtxoHex, err := hex.DecodeString(signIn.OutRaw)
-		if err != nil {
-			return err
-		}
-		err = tx.Deserialize(bytes.NewReader(nonWitnessUtxoHex))
-		if err != nil {
-			return err
-		}
-		//fmt.Printf("nonWitnessUtxoHe-tx: %s\n", tx.TxHash().String())
-		err = s.PsbtUpdater.AddInNonWitnessUtxo(tx, signIn.Index)
-		if err != nil {
-			return err
-		}
-		err = s.PsbtUpdater.AddInSighashType(signIn.SighashType, signIn.Index)
-		if err != nil {
-			return err
-		}
-		sigScript, err = txscript.RawTxInSignature(s.PsbtUpdater.Upsbt.UnsignedTx, signIn.Index,
-			s.PsbtUpdater.Upsbt.Inputs[signIn.Index].NonWitnessUtxo.TxOut[in.OutIndex].PkScript, signIn.SighashType, privateKey)
-
-		if err != nil {
-			return err
-		}
-
-		break
-	case Witness:
-		witnessUtxoScriptHex, err := hex.DecodeString(
-			signIn.PkScript)
-		if err != nil {
-			return err
-		}
-		txout := wire.TxOut{Value: int64(signIn.Amount), PkScript: witnessUtxoScriptHex}
-		err = s.PsbtUpdater.AddInWitnessUtxo(&txout, signIn.Index)
-		if err != nil {
-			return err
-		}
-		err = s.PsbtUpdater.AddInSighashType(signIn.SighashType, signIn.Index)
-		if err != nil {
-			return err
-		}
-		prevOutputFetcher := NewPrevOutputFetcher(s.PsbtUpdater.Upsbt.Inputs[signIn.Index].WitnessUtxo.PkScript, s.PsbtUpdater.Upsbt.Inputs[signIn.Index].WitnessUtxo.Value)
-		sigHashes := txscript.NewTxSigHashes(s.PsbtUpdater.Upsbt.UnsignedTx, prevOutputFetcher)
-		sigScript, err = txscript.RawTxInWitnessSignature(s.PsbtUpdater.Upsbt.UnsignedTx, sigHashes, signIn.Index, s.PsbtUpdater.Upsbt.Inputs[signIn.Index].WitnessUtxo.Value, s.PsbtUpdater.Upsbt.Inputs[signIn.Index].WitnessUtxo.PkScript, signIn.SighashType, privateKey)
-		if err != nil {
-			return err
-		}
-		break
-	case Taproot:
-		pkScript, err := hex.DecodeString(signIn.PkScript)
-		if err != nil {
-			return err
-		}
-
-		outPoint := s.PsbtUpdater.Upsbt.UnsignedTx.TxIn[signIn.Index].PreviousOutPoint
-		txOut := wire.TxOut{Value: int64(signIn.Amount), PkScript: pkScript}
-		err = s.PsbtUpdater.AddInWitnessUtxo(&txOut, signIn.Index)
-		if err != nil {
-			return err
-		}
-		err = s.PsbtUpdater.AddInSighashType(signIn.SighashType, signIn.Index)
-		if err != nil {
-			return err
-		}
-		multiPrevOutputFetcher.AddPrevOut(outPoint, &txOut)
-		fmt.Printf("multiPrevOutputFetcher: %s, %s\n", outPoint.String(), hex.EncodeToString(txOut.PkScript))
-
-		for i, txIn := range s.PsbtUpdater.Upsbt.UnsignedTx.TxIn {
-			outPoint := txIn.PreviousOutPoint
-			txOut := multiPrevOutputFetcher.FetchPrevOutput(outPoint)
-			fmt.Printf("multiPrevOutputFetcher[%d]: %s\n", i, outPoint.String())
-			fmt.Printf("multiPrevOutputFetcher[%d]: %s\n", i, hex.EncodeToString(txOut.PkScript))
-		}
-		sigHashes := txscript.NewTxSigHashes(s.PsbtUpdater.Upsbt.UnsignedTx, multiPrevOutputFetcher)
-		witness, err := txscript.TaprootWitnessSignature(s.PsbtUpdater.Upsbt.UnsignedTx, sigHashes,
-			signIn.Index, s.PsbtUpdater.Upsbt.Inputs[signIn.Index].WitnessUtxo.Value,
-			s.PsbtUpdater.Upsbt.Inputs[signIn.Index].WitnessUtxo.PkScript,
-			signIn.SighashType, privateKey)
-		if err != nil {
-			return err
-		}
-		sigScript = witness[0]
-		break
-	}
-	_ = witnessScript
-	publicKey := hex.EncodeToString(privateKey.PubKey().SerializeCompressed())
-	pubByte, err := hex.DecodeString(publicKey)
-	if err != nil {
-		return err
-	}
-	if signIn.UtxoType == Taproot {
-		s.PsbtUpdater.Upsbt.Inputs[signIn.Index].TaprootKeySpendSig = sigScript
-	} else {
-		res, err := s.PsbtUpdater.Sign(signIn.Index, sigScript, pubByte, nil, nil)
-		if err != nil || res != 0 {
-			return err
-		}
-	}
-	_, err = psbt.MaybeFinalize(s.PsbtUpdater.Upsbt, signIn.Index)
-	if err != nil {
-		return err
-	}
-	return nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addInputAt(in, signIn, len(s.PsbtUpdater.Upsbt.UnsignedTx.TxIn))
 }
 
 func (s *PsbtBuilder) AddOutput(outs []Output) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	txOuts := make([]*wire.TxOut, 0)
 	for _, out := range outs {
 		var pkScript []byte
@@ -771,151 +660,90 @@ func (s *PsbtBuilder) AddOutput(outs []Output) error {
 		txOut := wire.NewTxOut(int64(out.Amount), pkScript)
 		txOuts = append(txOuts, txOut)
 	}
+	if err := checkOutputAmounts(txOuts, len(s.PsbtUpdater.Upsbt.UnsignedTx.TxOut)); err != nil {
+		return err
+	}
 
+	layout := s.unchangedLayout()
+	for range txOuts {
+		layout.outputs = append(layout.outputs, -1)
+	}
+	if err := s.checkLayout("add output", layout); err != nil {
+		return err
+	}
 	for _, out := range txOuts {
 		s.PsbtUpdater.Upsbt.UnsignedTx.AddTxOut(out)
+		s.PsbtUpdater.Upsbt.Outputs = append(s.PsbtUpdater.Upsbt.Outputs, psbt.POutput{})
 	}
-
-	s.PsbtUpdater.Upsbt.Outputs = make([]psbt.POutput, len(s.PsbtUpdater.Upsbt.UnsignedTx.TxOut))
 	return nil
 }
 
+// AddInputByIndex inserts an input at index and signs it, the inputs from
+// index on shift up by one with their metadata. signIn.Index is set to index.
 func (s *PsbtBuilder) AddInputByIndex(in Input, signIn *InputSign, index int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addInputAt(in, signIn, int(index))
+}
 
-	multiPrevOutputFetcher := txscript.NewMultiPrevOutFetcher(nil)
-	if signIn.UtxoType == Taproot {
-		for i, txIn := range s.PsbtUpdater.Upsbt.UnsignedTx.TxIn {
-			outPoint := txIn.PreviousOutPoint
-			txOut := s.PsbtUpdater.Upsbt.Inputs[i].WitnessUtxo
-			multiPrevOutputFetcher.AddPrevOut(outPoint, txOut)
-		}
-	}
+func (s *PsbtBuilder) AddInputOnly(in Input) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.insertInput(in, len(s.PsbtUpdater.Upsbt.UnsignedTx.TxIn))
+}
 
-	privateKeyBytes, err := hex.DecodeString(signIn.PriHex)
-	if err != nil {
+// addInputAt inserts an input at index and signs it with signIn
+func (s *PsbtBuilder) addInputAt(in Input, signIn *InputSign, index int) error {
+	if err := s.insertInput(in, index); err != nil {
 		return err
 	}
-	privateKey, _ := btcec.PrivKeyFromBytes(privateKeyBytes)
-	publicKey := hex.EncodeToString(privateKey.PubKey().SerializeCompressed())
-	pubByte, err := hex.DecodeString(publicKey)
-	if err != nil {
+	signIn.Index = index
+	signIn.OutPoint = ""
+	if err := s.addSignInUtxo(signIn); err != nil {
 		return err
 	}
-	sigScript := []byte{}
-	switch signIn.UtxoType {
-	case NonWitness:
-		tx := wire.NewMsgTx(2)
-		nonWitnessUtxoHex, err := hex.DecodeString(signIn.OutRaw)
-		if err != nil {
-			return err
-		}
-		err = tx.Deserialize(bytes.NewReader(nonWitnessUtxoHex))
-		if err != nil {
-			return err
-		}
-		//fmt.Printf("nonWitnessUtxoHe-tx: %s\n", tx.TxHash().String())
-		err = s.PsbtUpdater.AddInNonWitnessUtxo(tx, signIn.Index)
-		if err != nil {
-			return err
-		}
-		err = s.PsbtUpdater.AddInSighashType(signIn.SighashType, signIn.Index)
-		if err != nil {
-			return err
-		}
-		sigScript, err = txscript.RawTxInSignature(s.PsbtUpdater.Upsbt.UnsignedTx, signIn.Index,
-			s.PsbtUpdater.Upsbt.Inputs[signIn.Index].NonWitnessUtxo.TxOut[in.OutIndex].PkScript, signIn.SighashType, privateKey)
-		if err != nil {
-			return err
-		}
-		break
-	case Witness:
-		witnessUtxoScriptHex, err := hex.DecodeString(
-			signIn.PkScript)
-		if err != nil {
-			return err
-		}
-		txout := wire.TxOut{Value: int64(signIn.Amount), PkScript: witnessUtxoScriptHex}
-		err = s.PsbtUpdater.AddInWitnessUtxo(&txout, signIn.Index)
-		if err != nil {
-			return err
-		}
-		err = s.PsbtUpdater.AddInSighashType(signIn.SighashType, signIn.Index)
-		if err != nil {
-			return err
-		}
-		prevOutputFetcher := NewPrevOutputFetcher(s.PsbtUpdater.Upsbt.Inputs[signIn.Index].WitnessUtxo.PkScript, s.PsbtUpdater.Upsbt.Inputs[signIn.Index].WitnessUtxo.Value)
-		sigHashes := txscript.NewTxSigHashes(s.PsbtUpdater.Upsbt.UnsignedTx, prevOutputFetcher)
-		sigScript, err = txscript.RawTxInWitnessSignature(s.PsbtUpdater.Upsbt.UnsignedTx, sigHashes, signIn.Index, s.PsbtUpdater.Upsbt.Inputs[signIn.Index].WitnessUtxo.Value, s.PsbtUpdater.Upsbt.Inputs[signIn.Index].WitnessUtxo.PkScript, signIn.SighashType, privateKey)
-		if err != nil {
-			return err
-		}
-		break
-	case Taproot:
-		pkScript, err := hex.DecodeString(signIn.PkScript)
-		if err != nil {
-			return err
-		}
-
-		outPoint := s.PsbtUpdater.Upsbt.UnsignedTx.TxIn[signIn.Index].PreviousOutPoint
-		txOut := wire.TxOut{Value: int64(signIn.Amount), PkScript: pkScript}
-		err = s.PsbtUpdater.AddInWitnessUtxo(&txOut, signIn.Index)
-		if err != nil {
-			return err
-		}
-		err = s.PsbtUpdater.AddInSighashType(signIn.SighashType, signIn.Index)
-		if err != nil {
-			return err
-		}
-		multiPrevOutputFetcher.AddPrevOut(outPoint, &txOut)
-
-		//prevOutputFetcher := txscript.NewCannedPrevOutputFetcher(s.PsbtUpdater.Upsbt.Inputs[signIn.Index].WitnessUtxo.PkScript, s.PsbtUpdater.Upsbt.Inputs[signIn.Index].WitnessUtxo.Value)
-
-		sigHashes := txscript.NewTxSigHashes(s.PsbtUpdater.Upsbt.UnsignedTx, multiPrevOutputFetcher)
-		//sigHashes := txscript.NewTxSigHashes(s.PsbtUpdater.Upsbt.UnsignedTx, prevOutputFetcher)
-		witness, err := txscript.TaprootWitnessSignature(s.PsbtUpdater.Upsbt.UnsignedTx, sigHashes,
-			signIn.Index, s.PsbtUpdater.Upsbt.Inputs[signIn.Index].WitnessUtxo.Value,
-			s.PsbtUpdater.Upsbt.Inputs[signIn.Index].WitnessUtxo.PkScript,
-			signIn.SighashType, privateKey)
-		if err != nil {
-			return err
-		}
-		sigScript = witness[0]
-		break
-	}
+	return s.signInput(signIn, s.newSigHashCache())
+}
 
-	if signIn.UtxoType == Taproot {
-		s.PsbtUpdater.Upsbt.Inputs[signIn.Index].TaprootKeySpendSig = sigScript
-	} else {
-		res, err := s.PsbtUpdater.Sign(signIn.Index, sigScript, pubByte, nil, nil)
-		if err != nil || res != 0 {
-			return err
-		}
+// insertInput inserts an unsigned input at index, index may be the input
+// count to append
+func (s *PsbtBuilder) insertInput(in Input, index int) error {
+	count := len(s.PsbtUpdater.Upsbt.UnsignedTx.TxIn)
+	if index < 0 || index > count {
+		return &InputIndexError{Index: index, Count: count}
 	}
-	_, err = psbt.MaybeFinalize(s.PsbtUpdater.Upsbt, signIn.Index)
+	txHash, err := chainhash.NewHashFromStr(in.OutTxId)
 	if err != nil {
 		return err
 	}
-	return nil
-}
-func (s *PsbtBuilder) AddInputOnly(in Input) error {
-	txHash, err := chainhash.NewHashFromStr(in.OutTxId)
-	if err != nil {
+	layout := s.unchangedLayout()
+	layout.inputs = append(layout.inputs[:index], append([]int{-1}, layout.inputs[index:]...)...)
+	if err = s.checkLayout("add input", layout); err != nil {
 		return err
 	}
+
 	s.PsbtUpdater.Upsbt.UnsignedTx.AddTxIn(&wire.TxIn{
 		PreviousOutPoint: *wire.NewOutPoint(txHash, in.OutIndex),
 		Sequence:         wire.MaxTxInSequenceNum,
 	})
 	s.PsbtUpdater.Upsbt.Inputs = append(s.PsbtUpdater.Upsbt.Inputs, psbt.PInput{})
+	// the appended entry takes the new position
+	layout.inputs[index] = count
+	s.applyLayout(layout)
+	s.logInput("psbt input added", index)
 	return nil
 }
 
 func (s *PsbtBuilder) IsComplete() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.PsbtUpdater.Upsbt.IsComplete()
 }
 
 func (s *PsbtBuilder) CalculateFee(feeRate int64, extraSize int64) (int64, error) {
-	txHex, err := s.ExtractPsbtTransaction()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	txHex, err := s.extractPsbtTransaction()
 	if err != nil {
 		return 0, err
 	}
@@ -928,6 +756,12 @@ func (s *PsbtBuilder) CalculateFee(feeRate int64, extraSize int64) (int64, error
 }
 
 func (s *PsbtBuilder) CalTxSize() (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.calTxSize()
+}
+
+func (s *PsbtBuilder) calTxSize() (int64, error) {
 	var (
 		tx          *wire.MsgTx = s.PsbtUpdater.Upsbt.UnsignedTx
 		txTotalSize int         = tx.SerializeSize()
@@ -943,12 +777,38 @@ func (s *PsbtBuilder) CalTxSize() (int64, error) {
 		emptyTaprootWitness  = wire.TxWitness{make([]byte, 64)}
 	)
 
-	for _, v := range pIns {
+	for i, v := range pIns {
+		if ms := s.inputMiniscript(i); ms != nil && v.WitnessUtxo != nil {
+			witnessSize, err := s.miniscriptWitnessSize(i, ms)
+			if err != nil {
+				return 0, err
+			}
+			txTotalSize += witnessSize
+			continue
+		}
+		if v.FinalScriptSig != nil || v.FinalScriptWitness != nil {
+			// finalized inputs are sized exactly, the unsigned tx already
+			// counts an empty script sig
+			scriptSigSize := wire.VarIntSerializeSize(uint64(len(v.FinalScriptSig))) - 1 + len(v.FinalScriptSig)
+			txBaseSize += scriptSigSize
+			txTotalSize += scriptSigSize + len(v.FinalScriptWitness)
+			continue
+		}
+		if v.WitnessUtxo != nil && len(v.TaprootLeafScript) > 0 {
+			// script path spend of the first leaf, a single signature
+			leaf := v.TaprootLeafScript[0]
+			sig := make([]byte, schnorr.SignatureSize)
+			if v.SighashType != txscript.SigHashDefault {
+				sig = append(sig, byte(v.SighashType))
+			}
+			txTotalSize += wire.TxWitness{sig, leaf.Script, leaf.ControlBlock}.SerializeSize()
+			continue
+		}
 		if v.WitnessUtxo != nil {
 			if v.RedeemScript != nil {
 				txBaseSize += 40 + wire.VarIntSerializeSize(uint64(len(emptyNestSignature))) + len(emptyNestSignature)
 			}
-			if v.TaprootKeySpendSig != nil {
+			if v.TaprootKeySpendSig != nil || txscript.IsPayToTaproot(v.WitnessUtxo.PkScript) {
 				txTotalSize += emptyTaprootWitness.SerializeSize()
 			} else {
 				txTotalSize += emptySegwitWitenss.SerializeSize()
@@ -958,27 +818,43 @@ func (s *PsbtBuilder) CalTxSize() (int64, error) {
 		}
 	}
 
+	if txTotalSize > tx.SerializeSize() && !tx.HasWitness() {
+		// segwit marker and flag bytes
+		txTotalSize += 2
+	}
 	weight = int64(txBaseSize*3 + txTotalSize)
 	vSize = (weight + (blockchain.WitnessScaleFactor - 1)) / blockchain.WitnessScaleFactor
 	return vSize, nil
 }
 
 func (s *PsbtBuilder) ExtractPsbtTransaction() (string, error) {
-	if !s.IsComplete() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.extractPsbtTransaction()
+}
+
+func (s *PsbtBuilder) extractPsbtTransaction() (string, error) {
+	if !s.PsbtUpdater.Upsbt.IsComplete() {
 		for i := range s.PsbtUpdater.Upsbt.UnsignedTx.TxIn {
-			success, err := psbt.MaybeFinalize(s.PsbtUpdater.Upsbt, i)
-			if err != nil || !success {
-				return "", err
+			if s.inputMiniscript(i) != nil && s.PsbtUpdater.Upsbt.Inputs[i].FinalScriptWitness == nil {
+				if err := s.finalizeMiniscriptInput(i); err != nil {
+					return "", err
+				}
 			}
 		}
-
-		err := psbt.MaybeFinalizeAll(s.PsbtUpdater.Upsbt)
-		if err != nil {
-			return "", err
+		for i := range s.PsbtUpdater.Upsbt.UnsignedTx.TxIn {
+			if err := s.maybeFinalize(i); err != nil {
+				return "", err
+			}
 		}
 	}
+	// the amounts are checked before the tx leaves the builder, as far as
+	// the utxos are known
+	if _, _, _, err := s.amounts(); err != nil {
+		return "", err
+	}
 
-	tx, err := psbt.Extract(s.PsbtUpdater.Upsbt)
+	tx, err := extractTx(s.PsbtUpdater.Upsbt)
 	if err != nil {
 		return "", err
 	}
@@ -987,6 +863,7 @@ func (s *PsbtBuilder) ExtractPsbtTransaction() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	s.logDebug("psbt transaction extracted", "txid", tx.TxHash().String(), "size", b.Len())
 	return hex.EncodeToString(b.Bytes()), nil
 }
 
@@ -1008,3 +885,35 @@ func (d *PrevOutputFetcher) FetchPrevOutput(wire.OutPoint) *wire.TxOut {
 		PkScript: d.pkScript,
 	}
 }
+
+// inputUtxo returns the output spent by input index, or nil when the psbt
+// carries no utxo for it
+func (s *PsbtBuilder) inputUtxo(index int) *wire.TxOut {
+	pIn := &s.PsbtUpdater.Upsbt.Inputs[index]
+	if pIn.WitnessUtxo != nil {
+		return pIn.WitnessUtxo
+	}
+	outIndex := s.PsbtUpdater.Upsbt.UnsignedTx.TxIn[index].PreviousOutPoint.Index
+	if pIn.NonWitnessUtxo != nil && int(outIndex) < len(pIn.NonWitnessUtxo.TxOut) {
+		return pIn.NonWitnessUtxo.TxOut[outIndex]
+	}
+	return nil
+}
+
+// prevOutputFetcher collects the spent output of every input, the second
+// return is false when an input has no utxo and a placeholder was used
+func (s *PsbtBuilder) prevOutputFetcher() (*txscript.MultiPrevOutFetcher, bool) {
+	var (
+		fetcher  = txscript.NewMultiPrevOutFetcher(nil)
+		complete = true
+	)
+	for i, txIn := range s.PsbtUpdater.Upsbt.UnsignedTx.TxIn {
+		txOut := s.inputUtxo(i)
+		if txOut == nil {
+			txOut = &wire.TxOut{}
+			complete = false
+		}
+		fetcher.AddPrevOut(txIn.PreviousOutPoint, txOut)
+	}
+	return fetcher, complete
+}
@@ -0,0 +1,418 @@
+package psbt_sdk
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// BIP174 input key types for hash preimages
+var preimageKeyTypes = map[string]byte{
+	"ripemd160": 0x0a,
+	"sha256":    0x0b,
+	"hash160":   0x0c,
+	"hash256":   0x0d,
+}
+
+// add miniscript witness script (Witness) or tapscript leaf (Taproot) to inputs
+func (s *PsbtBuilder) UpdateMiniscriptInput(signIns []*InputSign) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.resolveSignIns(signIns); err != nil {
+		return err
+	}
+	for _, v := range signIns {
+		if err := s.checkInputIndex(v.Index); err != nil {
+			return err
+		}
+		var ctx MiniscriptContext
+		switch v.UtxoType {
+		case Witness:
+			ctx = SegwitV0
+		case Taproot:
+			ctx = Tapscript
+		default:
+			return s.inputError(v.Index, errors.New("miniscript requires a Witness or Taproot input"))
+		}
+		ms, err := ParseMiniscript(v.Miniscript, ctx)
+		if err != nil {
+			return s.inputError(v.Index, err)
+		}
+		// a script that is not sane can lock the funds or be malleated
+		if err = ms.CheckSane(); err != nil {
+			return s.inputError(v.Index, err)
+		}
+		if err = s.checkSignInAmount(v); err != nil {
+			return err
+		}
+		pkScript, err := hex.DecodeString(v.PkScript)
+		if err != nil {
+			return err
+		}
+		txOut := wire.TxOut{Value: int64(v.Amount), PkScript: pkScript}
+		err = s.PsbtUpdater.AddInWitnessUtxo(&txOut, v.Index)
+		if err != nil {
+			return err
+		}
+		err = s.PsbtUpdater.AddInSighashType(v.SighashType, v.Index)
+		if err != nil {
+			return err
+		}
+
+		switch ctx {
+		case SegwitV0:
+			scriptHash := sha256.Sum256(ms.Script())
+			if !txscript.IsPayToWitnessScriptHash(pkScript) || !bytes.Equal(pkScript[2:], scriptHash[:]) {
+				return s.inputError(v.Index, errors.New("pk script is not the P2WSH of the miniscript"))
+			}
+			err = s.PsbtUpdater.AddInWitnessScript(ms.Script(), v.Index)
+			if err != nil {
+				return err
+			}
+		case Tapscript:
+			controlBlockBytes, err := hex.DecodeString(v.ControlBlockWitness)
+			if err != nil {
+				return err
+			}
+			controlBlock, err := txscript.ParseControlBlock(controlBlockBytes)
+			if err != nil {
+				return err
+			}
+			outputKey := txscript.ComputeTaprootOutputKey(controlBlock.InternalKey, controlBlock.RootHash(ms.Script()))
+			if !txscript.IsPayToTaproot(pkScript) || !bytes.Equal(pkScript[2:], schnorr.SerializePubKey(outputKey)) {
+				return s.inputError(v.Index, errors.New("control block does not commit the miniscript leaf to the pk script"))
+			}
+			leaf := txscript.NewBaseTapLeaf(ms.Script())
+			leafHash := leaf.TapHash()
+			pIn := &s.PsbtUpdater.Upsbt.Inputs[v.Index]
+			if _, err := psbt.FindLeafScript(pIn, leafHash[:]); err != nil {
+				pIn.TaprootLeafScript = append(pIn.TaprootLeafScript, &psbt.TaprootTapLeafScript{
+					ControlBlock: controlBlockBytes,
+					Script:       leaf.Script,
+					LeafVersion:  leaf.LeafVersion,
+				})
+			}
+		}
+		s.setInputMiniscript(v.Index, ms)
+		s.logInput("psbt input updated", v.Index, "miniscript", true, "utxo_type", v.UtxoType)
+	}
+	return nil
+}
+
+// sign miniscript inputs, signatures are attached but not finalized
+func (s *PsbtBuilder) SignMiniscriptInput(signIns []*InputSign) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.resolveSignIns(signIns); err != nil {
+		return err
+	}
+	cache := s.newSigHashCache()
+	for _, v := range signIns {
+		if err := s.checkInputIndex(v.Index); err != nil {
+			return err
+		}
+		ms := s.inputMiniscript(v.Index)
+		if ms == nil {
+			return s.inputError(v.Index, errors.New("no miniscript for input, call UpdateMiniscriptInput first"))
+		}
+		pIn := &s.PsbtUpdater.Upsbt.Inputs[v.Index]
+		if pIn.WitnessUtxo == nil {
+			return s.missingUtxoError(v.Index)
+		}
+		privateKeyBytes, err := hex.DecodeString(v.PriHex)
+		if err != nil {
+			return err
+		}
+		privateKey, _ := btcec.PrivKeyFromBytes(privateKeyBytes)
+
+		pubKey := privateKey.PubKey().SerializeCompressed()
+		if ms.Context() == Tapscript {
+			pubKey = schnorr.SerializePubKey(privateKey.PubKey())
+		}
+		isKey := false
+		for _, key := range ms.Keys() {
+			isKey = isKey || bytes.Equal(key, pubKey)
+		}
+		if !isKey {
+			return s.inputError(v.Index, errors.New(fmt.Sprintf("key %x is not part of miniscript %s", pubKey, ms)))
+		}
+
+		switch ms.Context() {
+		case SegwitV0:
+			sig, err := txscript.RawTxInWitnessSignature(s.PsbtUpdater.Upsbt.UnsignedTx, cache.sigHashes,
+				v.Index, pIn.WitnessUtxo.Value, ms.Script(), v.SighashType, privateKey)
+			if err != nil {
+				return err
+			}
+			res, err := s.PsbtUpdater.Sign(v.Index, sig, pubKey, nil, nil)
+			if err = s.signOutcomeError(v.Index, res, err); err != nil {
+				return err
+			}
+			s.logInput("psbt input signed", v.Index, "miniscript", true, "pubkey", s.sensitive(pubKey),
+				"signature", s.sensitive(sig))
+		case Tapscript:
+			if err = cache.checkTaproot(v.Index); err != nil {
+				return err
+			}
+			leaf := txscript.NewBaseTapLeaf(ms.Script())
+			sigHash, err := txscript.CalcTapscriptSignaturehash(cache.sigHashes, v.SighashType,
+				s.PsbtUpdater.Upsbt.UnsignedTx, v.Index, cache.prevOutputFetcher, leaf)
+			if err != nil {
+				return err
+			}
+			signature, err := schnorr.Sign(privateKey, sigHash)
+			if err != nil {
+				return err
+			}
+			leafHash := leaf.TapHash()
+			addTaprootScriptSpendSig(pIn, &psbt.TaprootScriptSpendSig{
+				XOnlyPubKey: pubKey,
+				LeafHash:    leafHash.CloneBytes(),
+				Signature:   signature.Serialize(),
+				SigHash:     v.SighashType,
+			})
+			s.logInput("psbt input signed", v.Index, "miniscript", true, "pubkey", s.sensitive(pubKey),
+				"leaf_hash", hex.EncodeToString(leafHash[:]), "signature", s.sensitive(signature.Serialize()))
+		}
+	}
+	return nil
+}
+
+// add hash preimage to input, hashFunc is one of sha256, hash256, ripemd160, hash160
+func (s *PsbtBuilder) AddInPreimage(hashFunc string, preimage []byte, index int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkInputIndex(index); err != nil {
+		return err
+	}
+	keyType, ok := preimageKeyTypes[hashFunc]
+	if !ok {
+		return errors.New(fmt.Sprintf("unknown hash function %q", hashFunc))
+	}
+	var hash []byte
+	switch hashFunc {
+	case "sha256":
+		h := sha256.Sum256(preimage)
+		hash = h[:]
+	case "hash256":
+		hash = chainhash.DoubleHashB(preimage)
+	case "ripemd160":
+		h := ripemd160.New()
+		h.Write(preimage)
+		hash = h.Sum(nil)
+	case "hash160":
+		hash = btcutil.Hash160(preimage)
+	}
+	key := append([]byte{keyType}, hash...)
+	pIn := &s.PsbtUpdater.Upsbt.Inputs[index]
+	for _, u := range pIn.Unknowns {
+		if bytes.Equal(u.Key, key) {
+			u.Value = preimage
+			return nil
+		}
+	}
+	pIn.Unknowns = append(pIn.Unknowns, &psbt.Unknown{Key: key, Value: preimage})
+	return nil
+}
+
+// finalize miniscript input with the signatures, preimages and timelocks in the psbt
+func (s *PsbtBuilder) FinalizeMiniscriptInput(index int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.finalizeMiniscriptInput(index)
+}
+
+func (s *PsbtBuilder) finalizeMiniscriptInput(index int) error {
+	if err := s.checkInputIndex(index); err != nil {
+		return err
+	}
+	ms := s.inputMiniscript(index)
+	if ms == nil {
+		return s.inputError(index, errors.New("no miniscript for input"))
+	}
+	pIn := &s.PsbtUpdater.Upsbt.Inputs[index]
+	if pIn.WitnessUtxo == nil {
+		return s.missingUtxoError(index)
+	}
+	satisfier := &psbtSatisfier{packet: s.PsbtUpdater.Upsbt, index: index}
+	var controlBlock []byte
+	if ms.Context() == Tapscript {
+		leafHash := txscript.NewBaseTapLeaf(ms.Script()).TapHash()
+		leafScript, err := psbt.FindLeafScript(pIn, leafHash[:])
+		if err != nil {
+			return s.inputError(index, err)
+		}
+		satisfier.leafHash = leafHash[:]
+		controlBlock = leafScript.ControlBlock
+	}
+
+	witness, err := ms.Satisfy(satisfier)
+	if err != nil {
+		return &FinalizeError{Index: index, OutPoint: s.outPoint(index), Err: err}
+	}
+	witness = append(witness, ms.Script())
+	if controlBlock != nil {
+		witness = append(witness, controlBlock)
+	}
+	var b bytes.Buffer
+	if err := psbt.WriteTxWitness(&b, witness); err != nil {
+		return err
+	}
+
+	newInput := psbt.NewPsbtInput(nil, pIn.WitnessUtxo)
+	newInput.FinalScriptWitness = b.Bytes()
+	s.PsbtUpdater.Upsbt.Inputs[index] = *newInput
+	s.logInput("psbt input finalized", index, "miniscript", true, "witness_items", len(witness))
+	return nil
+}
+
+// miniscriptKey is the BIP174 proprietary input key holding the miniscript
+// of an input, so it survives serialization
+var miniscriptKey = append(append([]byte{0xfc, byte(len(miniscriptKeyPrefix))}, miniscriptKeyPrefix...), 0x00)
+
+const miniscriptKeyPrefix = "psbt-sdk"
+
+func (s *PsbtBuilder) setInputMiniscript(index int, ms *Miniscript) {
+	if s.miniscripts == nil {
+		s.miniscripts = make(map[wire.OutPoint]*Miniscript)
+	}
+	s.miniscripts[s.PsbtUpdater.Upsbt.UnsignedTx.TxIn[index].PreviousOutPoint] = ms
+
+	pIn := &s.PsbtUpdater.Upsbt.Inputs[index]
+	for _, u := range pIn.Unknowns {
+		if bytes.Equal(u.Key, miniscriptKey) {
+			u.Value = []byte(ms.String())
+			return
+		}
+	}
+	pIn.Unknowns = append(pIn.Unknowns, &psbt.Unknown{Key: miniscriptKey, Value: []byte(ms.String())})
+}
+
+// inputMiniscript returns the miniscript of the input, parsed again from
+// the psbt when the builder was loaded from one
+func (s *PsbtBuilder) inputMiniscript(index int) *Miniscript {
+	if ms := s.miniscripts[s.PsbtUpdater.Upsbt.UnsignedTx.TxIn[index].PreviousOutPoint]; ms != nil {
+		return ms
+	}
+	pIn := &s.PsbtUpdater.Upsbt.Inputs[index]
+	for _, u := range pIn.Unknowns {
+		if !bytes.Equal(u.Key, miniscriptKey) {
+			continue
+		}
+		ctx := SegwitV0
+		if pIn.WitnessUtxo != nil && txscript.IsPayToTaproot(pIn.WitnessUtxo.PkScript) {
+			ctx = Tapscript
+		}
+		ms, err := ParseMiniscript(string(u.Value), ctx)
+		if err != nil {
+			return nil
+		}
+		// the miniscript must still be the script the input spends
+		if ctx == SegwitV0 && !bytes.Equal(pIn.WitnessScript, ms.Script()) {
+			return nil
+		}
+		leafHash := txscript.NewBaseTapLeaf(ms.Script()).TapHash()
+		if _, err = psbt.FindLeafScript(pIn, leafHash[:]); ctx == Tapscript && err != nil {
+			return nil
+		}
+		return ms
+	}
+	return nil
+}
+
+// miniscriptWitnessSize is the largest witness of a miniscript input,
+// including the control block for tapscript
+func (s *PsbtBuilder) miniscriptWitnessSize(index int, ms *Miniscript) (int, error) {
+	pIn := &s.PsbtUpdater.Upsbt.Inputs[index]
+	if pIn.FinalScriptWitness != nil {
+		return len(pIn.FinalScriptWitness), nil
+	}
+	size, err := ms.MaxWitnessSize()
+	if err != nil {
+		return 0, err
+	}
+	if ms.Context() == Tapscript {
+		leafHash := txscript.NewBaseTapLeaf(ms.Script()).TapHash()
+		leafScript, err := psbt.FindLeafScript(pIn, leafHash[:])
+		if err != nil {
+			return 0, s.inputError(index, err)
+		}
+		size += wire.VarIntSerializeSize(uint64(len(leafScript.ControlBlock))) + len(leafScript.ControlBlock)
+	}
+	return size, nil
+}
+
+// psbtSatisfier satisfies a miniscript from the data attached to a psbt input
+type psbtSatisfier struct {
+	packet   *psbt.Packet
+	index    int
+	leafHash []byte
+}
+
+func (p *psbtSatisfier) Signature(pubKey []byte) ([]byte, bool) {
+	pIn := &p.packet.Inputs[p.index]
+	if p.leafHash != nil {
+		for _, sig := range pIn.TaprootScriptSpendSig {
+			if bytes.Equal(sig.XOnlyPubKey, pubKey) && bytes.Equal(sig.LeafHash, p.leafHash) {
+				signature := append([]byte{}, sig.Signature...)
+				if sig.SigHash != txscript.SigHashDefault {
+					signature = append(signature, byte(sig.SigHash))
+				}
+				return signature, true
+			}
+		}
+		return nil, false
+	}
+	for _, sig := range pIn.PartialSigs {
+		if bytes.Equal(sig.PubKey, pubKey) {
+			return sig.Signature, true
+		}
+	}
+	return nil, false
+}
+
+func (p *psbtSatisfier) Preimage(hashFunc string, hash []byte) ([]byte, bool) {
+	key := append([]byte{preimageKeyTypes[hashFunc]}, hash...)
+	for _, u := range p.packet.Inputs[p.index].Unknowns {
+		if bytes.Equal(u.Key, key) {
+			return u.Value, true
+		}
+	}
+	return nil, false
+}
+
+// CheckOlder follows the BIP112 relative lock-time rules
+func (p *psbtSatisfier) CheckOlder(n uint32) bool {
+	tx := p.packet.UnsignedTx
+	sequence := tx.TxIn[p.index].Sequence
+	if tx.Version < 2 || sequence&sequenceLockTimeDisabled != 0 {
+		return false
+	}
+	if sequence&sequenceLockTimeIsSeconds != n&sequenceLockTimeIsSeconds {
+		return false
+	}
+	return sequence&sequenceLockTimeMask >= n&sequenceLockTimeMask
+}
+
+// CheckAfter follows the BIP65 absolute lock-time rules
+func (p *psbtSatisfier) CheckAfter(n uint32) bool {
+	tx := p.packet.UnsignedTx
+	if tx.TxIn[p.index].Sequence == wire.MaxTxInSequenceNum {
+		return false
+	}
+	if (tx.LockTime < lockTimeThreshold) != (n < lockTimeThreshold) {
+		return false
+	}
+	return tx.LockTime >= n
+}
@@ -1,10 +1,13 @@
 package psbt_sdk
 
 import (
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/txscript"
 	"log"
+	"strings"
 	"testing"
 )
 
@@ -110,3 +113,106 @@ func TestPsbtBuilder_CreatePsbtTransaction(t *testing.T) {
 	fmt.Printf("FinalRaw2:%s\n", txRaw)
 	//https://mempool.space/zh/signet/tx/71d5492dce16436cbda2ff09e4bc3d19c90c0ceae1ea037cd8b2a4f572b66dea
 }
+
+func TestPsbtBuilder_SignByOutPoint(t *testing.T) {
+	builder := testBuilder(t, 3)
+	signIns := testSignIns(3)
+	// select the inputs by outpoint in reverse order, Index is ignored
+	for i, v := range signIns {
+		v.OutPoint = builder.GetInputs()[i].PreviousOutPoint.String()
+		v.Index = 0
+	}
+	signIns[0], signIns[2] = signIns[2], signIns[0]
+	if err := builder.UpdateAndAddInputWitness(signIns); err != nil {
+		t.Fatalf("UpdateAndAddInputWitness() error = %v", err)
+	}
+	for i, v := range []int{2, 1, 0} {
+		if signIns[i].Index != v {
+			t.Fatalf("signIns[%d].Index = %d, want %d", i, signIns[i].Index, v)
+		}
+	}
+	if err := builder.UpdateAndSignInput(signIns); err != nil {
+		t.Fatalf("UpdateAndSignInput() error = %v", err)
+	}
+	if !builder.IsComplete() {
+		t.Fatal("IsComplete() = false, want true")
+	}
+
+	index, err := builder.IndexOfOutPoint(builder.GetInputs()[1].PreviousOutPoint.String())
+	if err != nil || index != 1 {
+		t.Fatalf("IndexOfOutPoint() = %d, %v, want 1", index, err)
+	}
+	for _, outPoint := range []string{
+		"93b3484083c31769c6a82b9c1cb66979463d86b3edea5044dede1ffdb74c3db2:7",
+		"93b3484083c31769c6a82b9c1cb66979463d86b3edea5044dede1ffdb74c3db2",
+		"xyz:0",
+	} {
+		err = builder.UpdateAndSignInput([]*InputSign{{UtxoType: Witness, OutPoint: outPoint}})
+		var outPointErr *UnknownOutPointError
+		if !errors.Is(err, ErrUnknownOutPoint) || !errors.As(err, &outPointErr) || outPointErr.OutPoint != outPoint {
+			t.Fatalf("UpdateAndSignInput(%q) error = %v, want UnknownOutPointError", outPoint, err)
+		}
+	}
+}
+
+func TestPsbtBuilder_AddInputByIndex(t *testing.T) {
+	builder := testBuilder(t, 3)
+	if err := builder.AddOutput([]Output{{Script: testP2wpkhScript(7), Amount: 1000}}); err != nil {
+		t.Fatalf("AddOutput() error = %v", err)
+	}
+	signIns := testSignIns(3)
+	// input 1 sells with SINGLE|ANYONECANPAY and is paired with output 1
+	signIns[1].SighashType = txscript.SigHashSingle | txscript.SigHashAnyOneCanPay
+	if err := builder.UpdateAndAddInputWitness(signIns); err != nil {
+		t.Fatalf("UpdateAndAddInputWitness() error = %v", err)
+	}
+	if err := builder.UpdateAndSignInput(signIns[1:2]); err != nil {
+		t.Fatalf("UpdateAndSignInput() error = %v", err)
+	}
+	seller := builder.GetInputs()[1].PreviousOutPoint
+
+	newInput := Input{OutTxId: strings.Repeat("44", 32), OutIndex: 5}
+	newSignIn := func() *InputSign {
+		return &InputSign{UtxoType: Witness, PkScript: testP2wpkhScript(3), Amount: 20000,
+			SighashType: txscript.SigHashAll, PriHex: hex.EncodeToString(testPrivKey(3).Serialize())}
+	}
+	if err := builder.AddInputByIndex(newInput, newSignIn(), 9); !errors.Is(err, ErrInputIndex) {
+		t.Fatalf("AddInputByIndex(9) error = %v, want ErrInputIndex", err)
+	}
+	// inserting before the seller would pair it with another output
+	err := builder.AddInputByIndex(newInput, newSignIn(), 0)
+	var invalidated *SignatureInvalidatedError
+	if !errors.As(err, &invalidated) || invalidated.Signatures[0].OutPoint != seller {
+		t.Fatalf("AddInputByIndex(0) error = %v, want signature of the seller invalidated", err)
+	}
+	if len(builder.GetInputs()) != 3 {
+		t.Fatalf("refused insertion reached the psbt")
+	}
+
+	signIn := newSignIn()
+	if err = builder.AddInputByIndex(newInput, signIn, 2); err != nil {
+		t.Fatalf("AddInputByIndex(2) error = %v", err)
+	}
+	inputs := builder.GetInputs()
+	if signIn.Index != 2 || inputs[2].PreviousOutPoint.Index != 5 || inputs[1].PreviousOutPoint != seller {
+		t.Fatalf("AddInputByIndex(2) inputs = %v, signIn.Index = %d", inputs, signIn.Index)
+	}
+	// the former input 2 moved up with its utxo
+	if inputs[3].PreviousOutPoint.Index != 2 ||
+		hex.EncodeToString(builder.PsbtUpdater.Upsbt.Inputs[3].WitnessUtxo.PkScript) != signIns[2].PkScript {
+		t.Fatalf("input 3 = %v, want former input 2 with its utxo", inputs[3].PreviousOutPoint)
+	}
+	results, err := builder.Verify()
+	if err != nil || !results[1].Valid || !results[2].Valid || !results[2].Finalized {
+		t.Fatalf("Verify() error = %v, want seller and new input valid", err)
+	}
+
+	// without the guard the seller ends up paired with output 2 and breaks
+	builder.SetMutationGuard(GuardOff)
+	if err = builder.AddInputByIndex(Input{OutTxId: strings.Repeat("55", 32)}, newSignIn(), 0); err != nil {
+		t.Fatalf("AddInputByIndex(0) with GuardOff error = %v", err)
+	}
+	if results, _ = builder.Verify(); results[2].Valid {
+		t.Fatalf("Verify() seller valid at input 2, want it broken")
+	}
+}
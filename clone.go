@@ -0,0 +1,198 @@
+package psbt_sdk
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Clone returns a deep copy of the builder, changes to the clone never reach
+// the original. Useful to try fee variants or other speculative changes.
+func (s *PsbtBuilder) Clone() (*PsbtBuilder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	clone := &PsbtBuilder{
+		NetParams:    s.NetParams,
+		signWorkers:  s.signWorkers,
+		logger:       s.logger,
+		logSensitive: s.logSensitive,
+		guard:        s.guard,
+	}
+	updater, err := psbt.NewUpdater(clonePacket(s.PsbtUpdater.Upsbt))
+	if err != nil {
+		return nil, err
+	}
+	clone.PsbtUpdater = updater
+	if s.miniscripts != nil {
+		// parsed miniscripts are never modified and can be shared
+		clone.miniscripts = make(map[wire.OutPoint]*Miniscript, len(s.miniscripts))
+		for k, v := range s.miniscripts {
+			clone.miniscripts[k] = v
+		}
+	}
+	return clone, nil
+}
+
+// PsbtSnapshot is an immutable copy of a builder's packet that can be shared
+// between goroutines
+type PsbtSnapshot struct {
+	netParams *chaincfg.Params
+	packet    *psbt.Packet
+	raw       []byte
+}
+
+// Snapshot captures the current packet
+func (s *PsbtBuilder) Snapshot() (*PsbtSnapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var b bytes.Buffer
+	if err := s.PsbtUpdater.Upsbt.Serialize(&b); err != nil {
+		return nil, err
+	}
+	return &PsbtSnapshot{
+		netParams: s.NetParams,
+		packet:    clonePacket(s.PsbtUpdater.Upsbt),
+		raw:       b.Bytes(),
+	}, nil
+}
+
+func (p *PsbtSnapshot) ToString() string {
+	return hex.EncodeToString(p.raw)
+}
+
+func (p *PsbtSnapshot) IsComplete() bool {
+	return p.packet.IsComplete()
+}
+
+// Packet returns a copy of the snapshot packet
+func (p *PsbtSnapshot) Packet() *psbt.Packet {
+	return clonePacket(p.packet)
+}
+
+// Builder returns a new builder starting from the snapshot
+func (p *PsbtSnapshot) Builder() (*PsbtBuilder, error) {
+	updater, err := psbt.NewUpdater(clonePacket(p.packet))
+	if err != nil {
+		return nil, err
+	}
+	return &PsbtBuilder{NetParams: p.netParams, PsbtUpdater: updater}, nil
+}
+
+func clonePacket(p *psbt.Packet) *psbt.Packet {
+	c := &psbt.Packet{
+		UnsignedTx: p.UnsignedTx.Copy(),
+		Inputs:     make([]psbt.PInput, len(p.Inputs)),
+		Outputs:    make([]psbt.POutput, len(p.Outputs)),
+		Unknowns:   cloneUnknowns(p.Unknowns),
+	}
+	for i := range p.Inputs {
+		c.Inputs[i] = clonePInput(&p.Inputs[i])
+	}
+	for i := range p.Outputs {
+		c.Outputs[i] = clonePOutput(&p.Outputs[i])
+	}
+	return c
+}
+
+func clonePInput(in *psbt.PInput) psbt.PInput {
+	c := psbt.PInput{
+		SighashType:            in.SighashType,
+		RedeemScript:           cloneBytes(in.RedeemScript),
+		WitnessScript:          cloneBytes(in.WitnessScript),
+		Bip32Derivation:        cloneBip32Derivation(in.Bip32Derivation),
+		FinalScriptSig:         cloneBytes(in.FinalScriptSig),
+		FinalScriptWitness:     cloneBytes(in.FinalScriptWitness),
+		TaprootKeySpendSig:     cloneBytes(in.TaprootKeySpendSig),
+		TaprootBip32Derivation: cloneTaprootBip32Derivation(in.TaprootBip32Derivation),
+		TaprootInternalKey:     cloneBytes(in.TaprootInternalKey),
+		TaprootMerkleRoot:      cloneBytes(in.TaprootMerkleRoot),
+		Unknowns:               cloneUnknowns(in.Unknowns),
+	}
+	if in.NonWitnessUtxo != nil {
+		c.NonWitnessUtxo = in.NonWitnessUtxo.Copy()
+	}
+	if in.WitnessUtxo != nil {
+		c.WitnessUtxo = wire.NewTxOut(in.WitnessUtxo.Value, cloneBytes(in.WitnessUtxo.PkScript))
+	}
+	for _, v := range in.PartialSigs {
+		c.PartialSigs = append(c.PartialSigs, &psbt.PartialSig{
+			PubKey:    cloneBytes(v.PubKey),
+			Signature: cloneBytes(v.Signature),
+		})
+	}
+	for _, v := range in.TaprootScriptSpendSig {
+		c.TaprootScriptSpendSig = append(c.TaprootScriptSpendSig, &psbt.TaprootScriptSpendSig{
+			XOnlyPubKey: cloneBytes(v.XOnlyPubKey),
+			LeafHash:    cloneBytes(v.LeafHash),
+			Signature:   cloneBytes(v.Signature),
+			SigHash:     v.SigHash,
+		})
+	}
+	for _, v := range in.TaprootLeafScript {
+		c.TaprootLeafScript = append(c.TaprootLeafScript, &psbt.TaprootTapLeafScript{
+			ControlBlock: cloneBytes(v.ControlBlock),
+			Script:       cloneBytes(v.Script),
+			LeafVersion:  v.LeafVersion,
+		})
+	}
+	return c
+}
+
+func clonePOutput(out *psbt.POutput) psbt.POutput {
+	return psbt.POutput{
+		RedeemScript:           cloneBytes(out.RedeemScript),
+		WitnessScript:          cloneBytes(out.WitnessScript),
+		Bip32Derivation:        cloneBip32Derivation(out.Bip32Derivation),
+		TaprootInternalKey:     cloneBytes(out.TaprootInternalKey),
+		TaprootTapTree:         cloneBytes(out.TaprootTapTree),
+		TaprootBip32Derivation: cloneTaprootBip32Derivation(out.TaprootBip32Derivation),
+		Unknowns:               cloneUnknowns(out.Unknowns),
+	}
+}
+
+func cloneBip32Derivation(derivations []*psbt.Bip32Derivation) []*psbt.Bip32Derivation {
+	var c []*psbt.Bip32Derivation
+	for _, v := range derivations {
+		c = append(c, &psbt.Bip32Derivation{
+			PubKey:               cloneBytes(v.PubKey),
+			MasterKeyFingerprint: v.MasterKeyFingerprint,
+			Bip32Path:            append([]uint32(nil), v.Bip32Path...),
+		})
+	}
+	return c
+}
+
+func cloneTaprootBip32Derivation(derivations []*psbt.TaprootBip32Derivation) []*psbt.TaprootBip32Derivation {
+	var c []*psbt.TaprootBip32Derivation
+	for _, v := range derivations {
+		leafHashes := make([][]byte, 0, len(v.LeafHashes))
+		for _, h := range v.LeafHashes {
+			leafHashes = append(leafHashes, cloneBytes(h))
+		}
+		c = append(c, &psbt.TaprootBip32Derivation{
+			XOnlyPubKey:          cloneBytes(v.XOnlyPubKey),
+			LeafHashes:           leafHashes,
+			MasterKeyFingerprint: v.MasterKeyFingerprint,
+			Bip32Path:            append([]uint32(nil), v.Bip32Path...),
+		})
+	}
+	return c
+}
+
+func cloneUnknowns(unknowns []*psbt.Unknown) []*psbt.Unknown {
+	var c []*psbt.Unknown
+	for _, v := range unknowns {
+		c = append(c, &psbt.Unknown{Key: cloneBytes(v.Key), Value: cloneBytes(v.Value)})
+	}
+	return c
+}
+
+func cloneBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
@@ -0,0 +1,96 @@
+package psbt_sdk
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestPsbtBuilder_Clone(t *testing.T) {
+	builder := testBuilder(t, 4)
+	if err := builder.UpdateAndAddInputWitness(testSignIns(4)); err != nil {
+		t.Fatalf("UpdateAndAddInputWitness() error = %v", err)
+	}
+	want, _ := builder.ToString()
+
+	clone, err := builder.Clone()
+	if err != nil {
+		t.Fatalf("Clone() error = %v", err)
+	}
+	if got, _ := clone.ToString(); got != want {
+		t.Fatalf("Clone() = %s, want %s", got, want)
+	}
+	if err = clone.AddOutput([]Output{{Script: testP2wpkhScript(7), Amount: 1000}}); err != nil {
+		t.Fatalf("AddOutput() error = %v", err)
+	}
+	if err = clone.UpdateAndSignInput(testSignIns(4)); err != nil {
+		t.Fatalf("UpdateAndSignInput() error = %v", err)
+	}
+	clone.PsbtUpdater.Upsbt.Inputs[0].WitnessUtxo.PkScript[0] = 0x51
+	if got, _ := builder.ToString(); got != want {
+		t.Fatalf("changes to the clone reached the original builder")
+	}
+}
+
+func TestPsbtBuilder_Snapshot(t *testing.T) {
+	builder := testBuilder(t, 2)
+	if err := builder.UpdateAndAddInputWitness(testSignIns(2)); err != nil {
+		t.Fatalf("UpdateAndAddInputWitness() error = %v", err)
+	}
+	snapshot, err := builder.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+	want := snapshot.ToString()
+
+	if err = builder.UpdateAndSignInput(testSignIns(2)); err != nil {
+		t.Fatalf("UpdateAndSignInput() error = %v", err)
+	}
+	snapshot.Packet().Inputs[0].WitnessUtxo.Value = 1
+	if snapshot.ToString() != want || snapshot.IsComplete() {
+		t.Fatalf("snapshot changed after the builder was signed")
+	}
+
+	resumed, err := snapshot.Builder()
+	if err != nil {
+		t.Fatalf("Builder() error = %v", err)
+	}
+	if got, _ := resumed.ToString(); got != want {
+		t.Fatalf("Builder() = %s, want %s", got, want)
+	}
+	if resumed.PsbtUpdater.Upsbt.Inputs[0].WitnessUtxo.Value == 1 {
+		t.Fatalf("Packet() returned the snapshot packet itself")
+	}
+}
+
+// run with -race
+func TestPsbtBuilder_Concurrent(t *testing.T) {
+	const nIn = 8
+	builder := testBuilder(t, nIn)
+	var wg sync.WaitGroup
+	for i := 0; i < nIn; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			signIn := testSignIns(nIn)[i]
+			if err := builder.UpdateAndAddInputWitness([]*InputSign{signIn}); err != nil {
+				t.Errorf("UpdateAndAddInputWitness() error = %v", err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			if _, err := builder.ToString(); err != nil {
+				t.Errorf("ToString() error = %v", err)
+			}
+			if _, err := builder.CalTxSize(); err != nil {
+				t.Errorf("CalTxSize() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := builder.UpdateAndSignInput(testSignIns(nIn)); err != nil {
+		t.Fatalf("UpdateAndSignInput() error = %v", err)
+	}
+	if _, err := builder.Verify(); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+}
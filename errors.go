@@ -0,0 +1,270 @@
+package psbt_sdk
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Sentinel errors matched by the typed errors below with errors.Is
+var (
+	ErrInputIndex           = errors.New("input index out of range")
+	ErrOutputIndex          = errors.New("output index out of range")
+	ErrUnknownOutPoint      = errors.New("unknown outpoint")
+	ErrMissingUtxo          = errors.New("missing utxo")
+	ErrInvalidSighash       = errors.New("invalid sighash")
+	ErrInvalidSignature     = errors.New("invalid signature")
+	ErrSignOutcome          = errors.New("sign failed")
+	ErrFinalize             = errors.New("finalize failed")
+	ErrSignatureInvalidated = errors.New("change invalidates signatures")
+	ErrInvalidAmount        = errors.New("invalid amount")
+	ErrSatFlow              = errors.New("inscription misrouted")
+)
+
+// InputIndexError is returned when an index does not address an input
+type InputIndexError struct {
+	Index int
+	Count int
+}
+
+func (e *InputIndexError) Error() string {
+	return fmt.Sprintf("Index-[%d] out of range, psbt has %d inputs", e.Index, e.Count)
+}
+
+func (e *InputIndexError) Is(target error) bool { return target == ErrInputIndex }
+
+// OutputIndexError is returned when an index does not address an output
+type OutputIndexError struct {
+	Index int
+	Count int
+}
+
+func (e *OutputIndexError) Error() string {
+	return fmt.Sprintf("Output-[%d] out of range, psbt has %d outputs", e.Index, e.Count)
+}
+
+func (e *OutputIndexError) Is(target error) bool { return target == ErrOutputIndex }
+
+// UnknownOutPointError is returned when an outpoint is not spent by the psbt
+type UnknownOutPointError struct {
+	OutPoint string
+	Err      error
+}
+
+func (e *UnknownOutPointError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("outpoint %q: %s", e.OutPoint, e.Err)
+	}
+	return fmt.Sprintf("outpoint %s is not an input of the psbt", e.OutPoint)
+}
+
+func (e *UnknownOutPointError) Is(target error) bool { return target == ErrUnknownOutPoint }
+
+func (e *UnknownOutPointError) Unwrap() error { return e.Err }
+
+// InputError is a failure of a single input, Err holds the cause
+type InputError struct {
+	Index    int
+	OutPoint wire.OutPoint
+	Err      error
+}
+
+func (e *InputError) Error() string {
+	return fmt.Sprintf("Index-[%d] %s %s", e.Index, e.OutPoint.String(), e.Err)
+}
+
+func (e *InputError) Unwrap() error { return e.Err }
+
+// MissingUtxoError is returned when the spent output of an input is needed
+// but neither a witness nor a non witness utxo is set
+type MissingUtxoError struct {
+	Index    int
+	OutPoint wire.OutPoint
+	// Reason is set when the utxo of another input is missing, as for
+	// taproot sighashes committing to every prevout
+	Reason string
+}
+
+func (e *MissingUtxoError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("Index-[%d] %s %s", e.Index, e.OutPoint.String(), e.Reason)
+	}
+	return fmt.Sprintf("Index-[%d] %s missing utxo", e.Index, e.OutPoint.String())
+}
+
+func (e *MissingUtxoError) Is(target error) bool { return target == ErrMissingUtxo }
+
+// InvalidSighashError is returned when a sighash can't be computed or a
+// signature commits to an unexpected sighash type
+type InvalidSighashError struct {
+	Index       int
+	OutPoint    wire.OutPoint
+	SighashType txscript.SigHashType
+	Err         error
+}
+
+func (e *InvalidSighashError) Error() string {
+	return fmt.Sprintf("Index-[%d] %s sighash %d: %s", e.Index, e.OutPoint.String(), e.SighashType, e.Err)
+}
+
+func (e *InvalidSighashError) Is(target error) bool { return target == ErrInvalidSighash }
+
+func (e *InvalidSighashError) Unwrap() error { return e.Err }
+
+// InvalidSignatureError is returned when a signature does not verify
+// against the sighash of the input
+type InvalidSignatureError struct {
+	Index    int
+	OutPoint wire.OutPoint
+	PubKey   []byte
+	Err      error
+}
+
+func (e *InvalidSignatureError) Error() string {
+	return fmt.Sprintf("Index-[%d] %s pubkey %x: %s", e.Index, e.OutPoint.String(), e.PubKey, e.Err)
+}
+
+func (e *InvalidSignatureError) Is(target error) bool { return target == ErrInvalidSignature }
+
+func (e *InvalidSignatureError) Unwrap() error { return e.Err }
+
+// SignOutcomeError is returned when psbt.Updater.Sign rejects a signature,
+// Err is nil when only the outcome reports the failure
+type SignOutcomeError struct {
+	Index    int
+	OutPoint wire.OutPoint
+	Outcome  psbt.SignOutcome
+	Err      error
+}
+
+func (e *SignOutcomeError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("Sign:Index-[%d] %s %s, SignOutcome:%d", e.Index, e.OutPoint.String(), e.Err, e.Outcome)
+	}
+	return fmt.Sprintf("Sign:Index-[%d] %s SignOutcome:%d", e.Index, e.OutPoint.String(), e.Outcome)
+}
+
+func (e *SignOutcomeError) Is(target error) bool { return target == ErrSignOutcome }
+
+func (e *SignOutcomeError) Unwrap() error { return e.Err }
+
+// FinalizeError is returned when an input can't be finalized
+type FinalizeError struct {
+	Index    int
+	OutPoint wire.OutPoint
+	Err      error
+}
+
+func (e *FinalizeError) Error() string {
+	return fmt.Sprintf("Index-[%d] %s finalize: %s", e.Index, e.OutPoint.String(), e.Err)
+}
+
+func (e *FinalizeError) Is(target error) bool { return target == ErrFinalize }
+
+func (e *FinalizeError) Unwrap() error { return e.Err }
+
+// SignatureInvalidatedError is returned when a change of the inputs or
+// outputs breaks signatures committing to them
+type SignatureInvalidatedError struct {
+	Op         string
+	Signatures []*InputSignature
+}
+
+func (e *SignatureInvalidatedError) Error() string {
+	if len(e.Signatures) == 0 {
+		return fmt.Sprintf("%s invalidates signatures", e.Op)
+	}
+	sig := e.Signatures[0]
+	msg := fmt.Sprintf("%s invalidates signature of Index-[%d] %s, sighash %d", e.Op, sig.Index, sig.OutPoint.String(), sig.SighashType)
+	if len(e.Signatures) > 1 {
+		msg += fmt.Sprintf(" and %d more", len(e.Signatures)-1)
+	}
+	return msg
+}
+
+func (e *SignatureInvalidatedError) Is(target error) bool { return target == ErrSignatureInvalidated }
+
+// AmountError is returned for an amount out of range or not matching the
+// psbt. Input and Output address the offending entry, they are -1 for the
+// amounts of the whole tx.
+type AmountError struct {
+	Input  int
+	Output int
+	Amount btcutil.Amount
+	Reason string
+}
+
+func (e *AmountError) Error() string {
+	switch {
+	case e.Input >= 0:
+		return fmt.Sprintf("Index-[%d] amount %d %s", e.Input, int64(e.Amount), e.Reason)
+	case e.Output >= 0:
+		return fmt.Sprintf("Output-[%d] amount %d %s", e.Output, int64(e.Amount), e.Reason)
+	}
+	return fmt.Sprintf("amount %d %s", int64(e.Amount), e.Reason)
+}
+
+func (e *AmountError) Is(target error) bool { return target == ErrInvalidAmount }
+
+// checkInputIndex returns an InputIndexError when index does not address an input
+func (s *PsbtBuilder) checkInputIndex(index int) error {
+	if index < 0 || index >= len(s.PsbtUpdater.Upsbt.Inputs) || index >= len(s.PsbtUpdater.Upsbt.UnsignedTx.TxIn) {
+		return &InputIndexError{Index: index, Count: len(s.PsbtUpdater.Upsbt.Inputs)}
+	}
+	return nil
+}
+
+// checkOutputIndex returns an OutputIndexError when index does not address an output
+func (s *PsbtBuilder) checkOutputIndex(index int) error {
+	if index < 0 || index >= len(s.PsbtUpdater.Upsbt.Outputs) || index >= len(s.PsbtUpdater.Upsbt.UnsignedTx.TxOut) {
+		return &OutputIndexError{Index: index, Count: len(s.PsbtUpdater.Upsbt.UnsignedTx.TxOut)}
+	}
+	return nil
+}
+
+func (s *PsbtBuilder) outPoint(index int) wire.OutPoint {
+	return s.PsbtUpdater.Upsbt.UnsignedTx.TxIn[index].PreviousOutPoint
+}
+
+func (s *PsbtBuilder) inputError(index int, err error) error {
+	return &InputError{Index: index, OutPoint: s.outPoint(index), Err: err}
+}
+
+func (s *PsbtBuilder) missingUtxoError(index int) error {
+	return &MissingUtxoError{Index: index, OutPoint: s.outPoint(index)}
+}
+
+// signOutcomeError turns a failed psbt.Updater.Sign call into an error, it
+// returns nil for psbt.SignSuccesful
+func (s *PsbtBuilder) signOutcomeError(index int, outcome psbt.SignOutcome, err error) error {
+	if err == nil && outcome == psbt.SignSuccesful {
+		return nil
+	}
+	return &SignOutcomeError{Index: index, OutPoint: s.outPoint(index), Outcome: outcome, Err: err}
+}
+
+// SatFlowError is returned when an inscription does not reach the output it
+// must end in. Output is -1 when it is spent to fees, Want is -1 when any
+// output is fine.
+type SatFlowError struct {
+	Id     string
+	Input  int
+	Output int
+	Want   int
+}
+
+func (e *SatFlowError) Error() string {
+	switch {
+	case e.Output < 0:
+		return fmt.Sprintf("inscription %s of Index-[%d] is spent to fees", e.Id, e.Input)
+	case e.Want >= 0:
+		return fmt.Sprintf("inscription %s of Index-[%d] ends in Output-[%d], want Output-[%d]", e.Id, e.Input, e.Output, e.Want)
+	}
+	return fmt.Sprintf("inscription %s of Index-[%d] ends in Output-[%d]", e.Id, e.Input, e.Output)
+}
+
+func (e *SatFlowError) Is(target error) bool { return target == ErrSatFlow }
@@ -0,0 +1,87 @@
+package psbt_sdk
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestPsbtBuilder_Errors(t *testing.T) {
+	builder := testBuilder(t, 2)
+	signIns := testSignIns(2)
+
+	_, err := builder.CalcInputSigHash(5)
+	var indexErr *InputIndexError
+	if !errors.Is(err, ErrInputIndex) || !errors.As(err, &indexErr) || indexErr.Index != 5 || indexErr.Count != 2 {
+		t.Fatalf("CalcInputSigHash(5) error = %v, want InputIndexError", err)
+	}
+	signIns[0].Index = 9
+	if err = builder.UpdateAndSignInput(signIns[:1]); !errors.Is(err, ErrInputIndex) {
+		t.Fatalf("UpdateAndSignInput() error = %v, want InputIndexError", err)
+	}
+
+	// taproot sighashes need the utxo of the other input as well
+	if err = builder.UpdateAndAddInputWitness(signIns[1:]); err != nil {
+		t.Fatalf("UpdateAndAddInputWitness() error = %v", err)
+	}
+	_, err = builder.CalcInputSigHash(1)
+	var utxoErr *MissingUtxoError
+	if !errors.Is(err, ErrMissingUtxo) || !errors.As(err, &utxoErr) || utxoErr.OutPoint != builder.GetInputs()[1].PreviousOutPoint {
+		t.Fatalf("CalcInputSigHash(1) error = %v, want MissingUtxoError", err)
+	}
+
+	signIns[0].Index = 0
+	if err = builder.UpdateAndAddInputWitness(signIns[:1]); err != nil {
+		t.Fatalf("UpdateAndAddInputWitness() error = %v", err)
+	}
+	hash, err := builder.CalcInputSigHash(0)
+	if err != nil {
+		t.Fatalf("CalcInputSigHash(0) error = %v", err)
+	}
+	sig := append(ecdsa.Sign(testPrivKey(4), hash.SigHash).Serialize(), byte(txscript.SigHashAll))
+	err = builder.AddSignature(0, testPrivKey(1).PubKey().SerializeCompressed(), sig, nil)
+	var sigErr *InvalidSignatureError
+	if !errors.Is(err, ErrInvalidSignature) || !errors.As(err, &sigErr) || sigErr.Index != 0 {
+		t.Fatalf("AddSignature() error = %v, want InvalidSignatureError", err)
+	}
+	sig = append(ecdsa.Sign(testPrivKey(1), hash.SigHash).Serialize(), byte(txscript.SigHashSingle))
+	if err = builder.AddSignature(0, testPrivKey(1).PubKey().SerializeCompressed(), sig, nil); !errors.Is(err, ErrInvalidSighash) {
+		t.Fatalf("AddSignature() error = %v, want InvalidSighashError", err)
+	}
+
+	// nothing is signed, extracting must fail instead of returning an empty tx
+	_, err = builder.ExtractPsbtTransaction()
+	var finalizeErr *FinalizeError
+	if !errors.Is(err, ErrFinalize) || !errors.As(err, &finalizeErr) || !errors.Is(err, psbt.ErrNotFinalizable) {
+		t.Fatalf("ExtractPsbtTransaction() error = %v, want FinalizeError", err)
+	}
+
+	// signing an input that is already finalized reports the outcome
+	if err = builder.UpdateAndSignInputNoFinalize(signIns[:1]); err != nil {
+		t.Fatalf("UpdateAndSignInputNoFinalize() error = %v", err)
+	}
+	err = builder.UpdateAndSignInputNoFinalize(signIns[:1])
+	var outcomeErr *SignOutcomeError
+	if !errors.Is(err, ErrSignOutcome) || !errors.As(err, &outcomeErr) || outcomeErr.Outcome != psbt.SignFinalized {
+		t.Fatalf("UpdateAndSignInputNoFinalize() error = %v, want SignOutcomeError", err)
+	}
+}
+
+func TestSignOutcomeError_Error(t *testing.T) {
+	var outPoint wire.OutPoint
+	outPoint.Index = 1
+	err := &SignOutcomeError{Index: 0, OutPoint: outPoint, Outcome: psbt.SignInvalid}
+	want := "Sign:Index-[0] " + outPoint.String() + " SignOutcome:-1"
+	if err.Error() != want {
+		t.Fatalf("Error() = %q, want %q", err.Error(), want)
+	}
+	err.Err = errors.New("bad key")
+	want = "Sign:Index-[0] " + outPoint.String() + " bad key, SignOutcome:-1"
+	if err.Error() != want {
+		t.Fatalf("Error() = %q, want %q", err.Error(), want)
+	}
+}
@@ -0,0 +1,293 @@
+package psbt_sdk
+
+import (
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// MutationGuard sets how the builder handles changes of the inputs or
+// outputs that invalidate existing signatures
+type MutationGuard int
+
+const (
+	// GuardReject refuses the change with a SignatureInvalidatedError
+	GuardReject MutationGuard = iota
+	// GuardWarn applies the change and logs every invalidated signature
+	GuardWarn
+	// GuardOff applies the change without checking signatures
+	GuardOff
+)
+
+// SetMutationGuard sets the handling of changes that invalidate signatures,
+// GuardReject by default
+func (s *PsbtBuilder) SetMutationGuard(guard MutationGuard) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.guard = guard
+}
+
+// SignatureCommitment lists the inputs and outputs a signature commits to.
+// Changing any of them, or their positions, invalidates the signature.
+type SignatureCommitment struct {
+	Signature *InputSignature
+	Inputs    []int
+	Outputs   []int
+}
+
+// SignatureCommitments reports the signatures of the psbt and what each one
+// commits to according to its sighash type
+func (s *PsbtBuilder) SignatureCommitments() []*SignatureCommitment {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tx := s.PsbtUpdater.Upsbt.UnsignedTx
+	var commitments []*SignatureCommitment
+	for index := range tx.TxIn {
+		legacy := s.isLegacyInput(index)
+		for _, sig := range s.inputSignatures(index) {
+			commitment := &SignatureCommitment{Signature: sig, Inputs: []int{index}}
+			if sig.SighashType&txscript.SigHashAnyOneCanPay == 0 {
+				commitment.Inputs = sequence(len(tx.TxIn))
+			}
+			switch sig.SighashType & sigHashMask {
+			case txscript.SigHashNone:
+			case txscript.SigHashSingle:
+				// a legacy sighash also commits to the output count, with the
+				// outputs before the pair blanked
+				if index < len(tx.TxOut) && legacy {
+					commitment.Outputs = sequence(index + 1)
+				} else if index < len(tx.TxOut) {
+					commitment.Outputs = []int{index}
+				}
+			default:
+				commitment.Outputs = sequence(len(tx.TxOut))
+			}
+			commitments = append(commitments, commitment)
+		}
+	}
+	return commitments
+}
+
+// sigHashMask selects the base type of a sighash flag, as in txscript
+const sigHashMask = 0x1f
+
+// InputSignature is a signature carried by an input. PubKey is unknown for
+// signatures of finalized legacy and segwit v0 inputs.
+type InputSignature struct {
+	Index       int
+	OutPoint    wire.OutPoint
+	PubKey      []byte
+	LeafHash    []byte
+	SighashType txscript.SigHashType
+	Finalized   bool
+}
+
+// txLayout describes a change of the tx, every position of the changed
+// inputs and outputs holds the position the entry had before, -1 for new
+// entries
+type txLayout struct {
+	inputs  []int
+	outputs []int
+}
+
+// unchangedLayout is the layout of the tx as it is
+func (s *PsbtBuilder) unchangedLayout() *txLayout {
+	return &txLayout{
+		inputs:  sequence(len(s.PsbtUpdater.Upsbt.UnsignedTx.TxIn)),
+		outputs: sequence(len(s.PsbtUpdater.Upsbt.UnsignedTx.TxOut)),
+	}
+}
+
+func sequence(n int) []int {
+	seq := make([]int, n)
+	for i := range seq {
+		seq[i] = i
+	}
+	return seq
+}
+
+func isSequence(positions []int, n int) bool {
+	if len(positions) != n {
+		return false
+	}
+	for i, v := range positions {
+		if v != i {
+			return false
+		}
+	}
+	return true
+}
+
+// inputSignatures lists the signatures of the input at index. Signatures of
+// finalized inputs are recovered from the final scripts; a final script that
+// can't be parsed is reported as one SIGHASH_ALL signature, the strictest
+// commitment.
+func (s *PsbtBuilder) inputSignatures(index int) []*InputSignature {
+	pIn := &s.PsbtUpdater.Upsbt.Inputs[index]
+	outPoint := s.outPoint(index)
+	var sigs []*InputSignature
+	add := func(pubKey, leafHash []byte, sighashType txscript.SigHashType, finalized bool) {
+		sigs = append(sigs, &InputSignature{Index: index, OutPoint: outPoint, PubKey: pubKey,
+			LeafHash: leafHash, SighashType: sighashType, Finalized: finalized})
+	}
+
+	for _, partialSig := range pIn.PartialSigs {
+		if len(partialSig.Signature) > 0 {
+			add(partialSig.PubKey, nil, txscript.SigHashType(partialSig.Signature[len(partialSig.Signature)-1]), false)
+		}
+	}
+	if len(pIn.TaprootKeySpendSig) > 0 {
+		_, sighashType, err := splitSchnorrSig(pIn.TaprootKeySpendSig)
+		if err != nil {
+			sighashType = txscript.SigHashAll
+		}
+		add(pIn.TaprootInternalKey, nil, sighashType, false)
+	}
+	for _, scriptSig := range pIn.TaprootScriptSpendSig {
+		add(scriptSig.XOnlyPubKey, scriptSig.LeafHash, scriptSig.SigHash, false)
+	}
+
+	if len(pIn.FinalScriptWitness) > 0 {
+		witness, err := parseTxWitness(pIn.FinalScriptWitness)
+		if err != nil {
+			add(nil, nil, txscript.SigHashAll, true)
+			return sigs
+		}
+		prevOut := s.inputUtxo(index)
+		taproot := prevOut != nil && txscript.IsPayToTaproot(prevOut.PkScript)
+		for _, item := range witness {
+			if sighashType, ok := itemSighashType(item, taproot); ok {
+				add(nil, nil, sighashType, true)
+			}
+		}
+	}
+	if len(pIn.FinalScriptSig) > 0 {
+		pushes, err := txscript.PushedData(pIn.FinalScriptSig)
+		if err != nil {
+			add(nil, nil, txscript.SigHashAll, true)
+			return sigs
+		}
+		for _, item := range pushes {
+			if sighashType, ok := itemSighashType(item, false); ok {
+				add(nil, nil, sighashType, true)
+			}
+		}
+	}
+	return sigs
+}
+
+// isLegacyInput reports whether the input is signed with the legacy sighash,
+// an input without utxo or redeem script counts as legacy unless it has a
+// final witness
+func (s *PsbtBuilder) isLegacyInput(index int) bool {
+	pIn := &s.PsbtUpdater.Upsbt.Inputs[index]
+	prevOut := s.inputUtxo(index)
+	if prevOut == nil {
+		return len(pIn.FinalScriptWitness) == 0
+	}
+	if !txscript.IsPayToScriptHash(prevOut.PkScript) {
+		return !txscript.IsWitnessProgram(prevOut.PkScript)
+	}
+	if pIn.RedeemScript != nil {
+		return !txscript.IsWitnessProgram(pIn.RedeemScript)
+	}
+	return len(pIn.FinalScriptWitness) == 0
+}
+
+// itemSighashType returns the sighash type of a witness or script sig item
+// that looks like a signature
+func itemSighashType(item []byte, taproot bool) (txscript.SigHashType, bool) {
+	if taproot {
+		if _, sighashType, err := splitSchnorrSig(item); err == nil {
+			return sighashType, true
+		}
+		return 0, false
+	}
+	if len(item) < 9 {
+		return 0, false
+	}
+	if _, err := ecdsa.ParseDERSignature(item[:len(item)-1]); err != nil {
+		return 0, false
+	}
+	return txscript.SigHashType(item[len(item)-1]), true
+}
+
+// invalidatedSignatures returns the signatures that no longer verify once
+// the tx takes the new layout. Signatures of removed inputs are dropped with
+// them and not reported.
+func (s *PsbtBuilder) invalidatedSignatures(layout *txLayout) []*InputSignature {
+	tx := s.PsbtUpdater.Upsbt.UnsignedTx
+	inputsKept := isSequence(layout.inputs, len(tx.TxIn))
+	outputsKept := isSequence(layout.outputs, len(tx.TxOut))
+
+	newIndex := make([]int, len(tx.TxIn))
+	for i := range newIndex {
+		newIndex[i] = -1
+	}
+	for i, old := range layout.inputs {
+		if old >= 0 {
+			newIndex[old] = i
+		}
+	}
+
+	var invalidated []*InputSignature
+	for index := range tx.TxIn {
+		if newIndex[index] < 0 {
+			continue
+		}
+		legacy := s.isLegacyInput(index)
+		for _, sig := range s.inputSignatures(index) {
+			if !sigCommitmentHolds(sig.SighashType, legacy, index, newIndex[index], len(tx.TxOut), layout, inputsKept, outputsKept) {
+				invalidated = append(invalidated, sig)
+			}
+		}
+	}
+	return invalidated
+}
+
+// sigCommitmentHolds reports whether a signature of the input moving from
+// index to newIndex still commits to the same data under the new layout
+func sigCommitmentHolds(sighashType txscript.SigHashType, legacy bool, index, newIndex, outputCount int, layout *txLayout,
+	inputsKept, outputsKept bool) bool {
+	// without ANYONECANPAY every input and its position is committed
+	if sighashType&txscript.SigHashAnyOneCanPay == 0 && !inputsKept {
+		return false
+	}
+	switch sighashType & sigHashMask {
+	case txscript.SigHashNone:
+		return true
+	case txscript.SigHashSingle:
+		// the output at the position of the input is committed
+		if index >= outputCount {
+			return newIndex >= len(layout.outputs)
+		}
+		// a legacy sighash also commits to the output count up to the pair,
+		// so the input can't change position
+		if legacy && newIndex != index {
+			return false
+		}
+		return newIndex < len(layout.outputs) && layout.outputs[newIndex] == index
+	default:
+		return outputsKept
+	}
+}
+
+// checkLayout refuses or logs a change that would invalidate existing
+// signatures, depending on the mutation guard
+func (s *PsbtBuilder) checkLayout(op string, layout *txLayout) error {
+	if s.guard == GuardOff {
+		return nil
+	}
+	invalidated := s.invalidatedSignatures(layout)
+	if len(invalidated) == 0 {
+		return nil
+	}
+	if s.guard == GuardWarn {
+		for _, sig := range invalidated {
+			s.logInput("psbt signature invalidated", sig.Index, "op", op, "sighash_type", sig.SighashType,
+				"pubkey", s.sensitive(sig.PubKey))
+		}
+		return nil
+	}
+	return &SignatureInvalidatedError{Op: op, Signatures: invalidated}
+}
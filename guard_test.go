@@ -0,0 +1,158 @@
+package psbt_sdk
+
+import (
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+func TestPsbtBuilder_SignatureCommitments(t *testing.T) {
+	builder := testBuilder(t, 3)
+	if err := builder.AddOutput([]Output{{Script: testP2wpkhScript(7), Amount: 1000}}); err != nil {
+		t.Fatalf("AddOutput() error = %v", err)
+	}
+	signIns := testSignIns(3)
+	signIns[1].SighashType = txscript.SigHashSingle | txscript.SigHashAnyOneCanPay
+	signIns[2].SighashType = txscript.SigHashNone
+	if err := builder.UpdateAndAddInputWitness(signIns); err != nil {
+		t.Fatalf("UpdateAndAddInputWitness() error = %v", err)
+	}
+	if err := builder.UpdateAndSignInput(signIns); err != nil {
+		t.Fatalf("UpdateAndSignInput() error = %v", err)
+	}
+
+	commitments := builder.SignatureCommitments()
+	want := []struct {
+		inputs  []int
+		outputs []int
+	}{
+		{[]int{0, 1, 2}, []int{0, 1}},
+		{[]int{1}, []int{1}},
+		{[]int{0, 1, 2}, nil},
+	}
+	if len(commitments) != len(want) {
+		t.Fatalf("SignatureCommitments() = %d signatures, want %d", len(commitments), len(want))
+	}
+	for i, c := range commitments {
+		if c.Signature.Index != i || !c.Signature.Finalized || c.Signature.SighashType != signIns[i].SighashType ||
+			!reflect.DeepEqual(c.Inputs, want[i].inputs) || !reflect.DeepEqual(c.Outputs, want[i].outputs) {
+			t.Fatalf("SignatureCommitments()[%d] = %+v %+v, want %v", i, c, c.Signature, want[i])
+		}
+	}
+}
+
+func TestPsbtBuilder_MutationGuard(t *testing.T) {
+	newBuilder := func(sighashType txscript.SigHashType) *PsbtBuilder {
+		builder := testBuilder(t, 1)
+		signIn := &InputSign{UtxoType: Witness, Index: 0, PkScript: testP2wpkhScript(1), Amount: 60000,
+			SighashType: sighashType, PriHex: hex.EncodeToString(testPrivKey(1).Serialize())}
+		if err := builder.UpdateAndSignInput([]*InputSign{signIn}); err != nil {
+			t.Fatalf("UpdateAndSignInput() error = %v", err)
+		}
+		return builder
+	}
+	newInput := Input{OutTxId: strings.Repeat("44", 32), OutIndex: 1}
+	newOutput := []Output{{Script: testP2wpkhScript(7), Amount: 1000}}
+
+	builder := newBuilder(txscript.SigHashAll)
+	err := builder.AddOutput(newOutput)
+	var invalidated *SignatureInvalidatedError
+	if !errors.As(err, &invalidated) || invalidated.Op != "add output" || len(invalidated.Signatures) != 1 ||
+		invalidated.Signatures[0].SighashType != txscript.SigHashAll {
+		t.Fatalf("AddOutput() error = %v, want SignatureInvalidatedError", err)
+	}
+	if err = builder.AddInputOnly(newInput); !errors.Is(err, ErrSignatureInvalidated) {
+		t.Fatalf("AddInputOnly() error = %v, want ErrSignatureInvalidated", err)
+	}
+	if len(builder.GetInputs()) != 1 || len(builder.GetOutputs()) != 1 {
+		t.Fatalf("refused changes reached the psbt")
+	}
+
+	// SINGLE|ANYONECANPAY lets others add inputs and outputs after its pair
+	builder = newBuilder(txscript.SigHashSingle | txscript.SigHashAnyOneCanPay)
+	if err = builder.AddInputOnly(newInput); err != nil {
+		t.Fatalf("AddInputOnly() error = %v", err)
+	}
+	if err = builder.AddOutput(newOutput); err != nil {
+		t.Fatalf("AddOutput() error = %v", err)
+	}
+	if results, err := builder.Verify(); err != nil || !results[0].Valid {
+		t.Fatalf("Verify() error = %v, want input 0 valid", err)
+	}
+
+	// a warning guard applies the change and logs the broken signature
+	builder = newBuilder(txscript.SigHashAll)
+	logger := &testLogger{}
+	builder.SetLogger(logger)
+	builder.SetMutationGuard(GuardWarn)
+	if err = builder.AddOutput(newOutput); err != nil {
+		t.Fatalf("AddOutput() with GuardWarn error = %v", err)
+	}
+	if len(builder.GetOutputs()) != 2 || !strings.Contains(logger.String(), "psbt signature invalidated") {
+		t.Fatalf("AddOutput() with GuardWarn logged:\n%s", logger)
+	}
+	if results, _ := builder.Verify(); results[0].Valid {
+		t.Fatalf("Verify() input 0 valid after its outputs changed")
+	}
+
+	builder.SetMutationGuard(GuardOff)
+	logger.records = nil
+	if err = builder.AddInputOnly(newInput); err != nil || strings.Contains(logger.String(), "psbt signature invalidated") {
+		t.Fatalf("AddInputOnly() with GuardOff error = %v, logged:\n%s", err, logger)
+	}
+}
+
+func TestPsbtBuilder_MutationGuardLegacySingle(t *testing.T) {
+	p2pkh, _ := btcutil.NewAddressPubKeyHash(btcutil.Hash160(testPrivKey(1).PubKey().SerializeCompressed()), &chaincfg.SigNetParams)
+	p2pkhScript, _ := txscript.PayToAddrScript(p2pkh)
+	prevTx, prevRaw := testPrevTx(t, 60000, hex.EncodeToString(p2pkhScript))
+	priHex := hex.EncodeToString(testPrivKey(1).Serialize())
+
+	for name, signIn := range map[string]*InputSign{
+		"legacy": {UtxoType: NonWitness, Index: 1, OutRaw: prevRaw, PriHex: priHex},
+		"segwit": {UtxoType: Witness, Index: 1, PkScript: testP2wpkhScript(1), Amount: 60000, PriHex: priHex},
+	} {
+		// bip69 moves the signed input and its paired output both to 0
+		builder, err := CreatePsbtBuilder(&chaincfg.SigNetParams,
+			[]Input{{OutTxId: strings.Repeat("ff", 32)}, {OutTxId: prevTx.TxHash().String()}},
+			[]Output{{Script: testP2wpkhScript(7), Amount: 50000}, {Script: testP2wpkhScript(8), Amount: 1000}})
+		if err != nil {
+			t.Fatalf("%s: CreatePsbtBuilder() error = %v", name, err)
+		}
+		signIn.SighashType = txscript.SigHashSingle | txscript.SigHashAnyOneCanPay
+		if err = builder.UpdateAndSignInput([]*InputSign{signIn}); err != nil {
+			t.Fatalf("%s: UpdateAndSignInput() error = %v", name, err)
+		}
+		commitments := builder.SignatureCommitments()
+		if len(commitments) != 1 {
+			t.Fatalf("%s: SignatureCommitments() = %d signatures, want 1", name, len(commitments))
+		}
+
+		err = builder.SortBip69()
+		if name == "legacy" {
+			// the legacy sighash commits to the output count before the pair
+			if !reflect.DeepEqual(commitments[0].Outputs, []int{0, 1}) {
+				t.Fatalf("%s: SignatureCommitments() outputs = %v, want [0 1]", name, commitments[0].Outputs)
+			}
+			if !errors.Is(err, ErrSignatureInvalidated) {
+				t.Fatalf("%s: SortBip69() error = %v, want ErrSignatureInvalidated", name, err)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(commitments[0].Outputs, []int{1}) {
+			t.Fatalf("%s: SignatureCommitments() outputs = %v, want [1]", name, commitments[0].Outputs)
+		}
+		if err != nil {
+			t.Fatalf("%s: SortBip69() error = %v", name, err)
+		}
+		if results, err := builder.Verify(); err != nil || !results[0].Valid {
+			t.Fatalf("%s: Verify() error = %v, want the moved input valid", name, err)
+		}
+	}
+}
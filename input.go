@@ -1,6 +1,7 @@
 package psbt_sdk
 
 import (
+	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 )
@@ -13,15 +14,39 @@ type Input struct {
 type InputSign struct {
 	UtxoType            UtxoType             `json:"utxo_type"`
 	Index               int                  `json:"index"`
+	OutPoint            string               `json:"out_point"` // txid:vout, overrides Index when set
 	OutRaw              string               `json:"out_raw"`
 	PkScript            string               `json:"pk_script"`
 	RedeemScript        string               `json:"redeem_script"`
 	ControlBlockWitness string               `json:"control_block_witness"`
-	Amount              uint64               `json:"amount"`
+	Amount              btcutil.Amount       `json:"amount"` // optional for NonWitness inputs
 	SighashType         txscript.SigHashType `json:"sighash_type"`
 	PriHex              string               `json:"pri_hex"`
 	MultiSigScript      string               `json:"multi_sig_script"`
 	PreSigScript        string               `json:"pre_sig_script"`
+	Miniscript          string               `json:"miniscript"`
+}
+
+// Utxo is an output spent by an input left unsigned for a wallet, the
+// fields follow InputSign
+type Utxo struct {
+	Input
+	UtxoType     UtxoType       `json:"utxo_type"`
+	OutRaw       string         `json:"out_raw"`
+	PkScript     string         `json:"pk_script"`
+	RedeemScript string         `json:"redeem_script"`
+	Amount       btcutil.Amount `json:"amount"` // optional for NonWitness utxos
+}
+
+// signIn describes the utxo as the unsigned input at index, with the
+// default sighash type of its utxo type
+func (u *Utxo) signIn(index int) *InputSign {
+	sighashType := txscript.SigHashAll
+	if u.UtxoType == Taproot {
+		sighashType = txscript.SigHashDefault
+	}
+	return &InputSign{UtxoType: u.UtxoType, Index: index, OutRaw: u.OutRaw, PkScript: u.PkScript,
+		RedeemScript: u.RedeemScript, Amount: u.Amount, SighashType: sighashType}
 }
 
 type SigIn struct {
@@ -0,0 +1,54 @@
+package psbt_sdk
+
+import (
+	"encoding/hex"
+)
+
+// Logger receives the debug events of the builder stages: update, sign,
+// finalize and extract. *slog.Logger satisfies it.
+type Logger interface {
+	Debug(msg string, args ...any)
+}
+
+const redacted = "[redacted]"
+
+// SetLogger sets the logger of the builder, nil disables logging
+func (s *PsbtBuilder) SetLogger(logger Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = logger
+}
+
+// SetLogSensitive makes the logger receive signatures, pubkeys and
+// outpoints, they are redacted by default
+func (s *PsbtBuilder) SetLogSensitive(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logSensitive = enabled
+}
+
+func (s *PsbtBuilder) logDebug(msg string, args ...any) {
+	if s.logger != nil {
+		s.logger.Debug(msg, args...)
+	}
+}
+
+// logInput logs an event of the input at index with its outpoint
+func (s *PsbtBuilder) logInput(msg string, index int, args ...any) {
+	if s.logger == nil {
+		return
+	}
+	s.logger.Debug(msg, append([]any{"index", index, "outpoint", s.sensitiveString(s.outPoint(index).String())}, args...)...)
+}
+
+// sensitive hex encodes signing material when sensitive logging is enabled
+func (s *PsbtBuilder) sensitive(b []byte) string {
+	return s.sensitiveString(hex.EncodeToString(b))
+}
+
+func (s *PsbtBuilder) sensitiveString(v string) string {
+	if !s.logSensitive {
+		return redacted
+	}
+	return v
+}
@@ -0,0 +1,82 @@
+package psbt_sdk
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type testLogger struct {
+	records []string
+}
+
+func (l *testLogger) Debug(msg string, args ...any) {
+	l.records = append(l.records, fmt.Sprint(append([]any{msg}, args...)...))
+}
+
+func (l *testLogger) String() string {
+	return strings.Join(l.records, "\n")
+}
+
+func TestPsbtBuilder_SetLogger(t *testing.T) {
+	for _, sensitive := range []bool{false, true} {
+		logger := &testLogger{}
+		builder := testBuilder(t, 2)
+		builder.SetLogger(logger)
+		builder.SetLogSensitive(sensitive)
+		if err := builder.UpdateAndSignInput(testSignIns(2)); err != nil {
+			t.Fatalf("UpdateAndSignInput() error = %v", err)
+		}
+		if _, err := builder.ExtractPsbtTransaction(); err != nil {
+			t.Fatalf("ExtractPsbtTransaction() error = %v", err)
+		}
+
+		for _, stage := range []string{"psbt input updated", "psbt input signed", "psbt input finalized", "psbt transaction extracted"} {
+			if !strings.Contains(logger.String(), stage) {
+				t.Fatalf("missing %q event in:\n%s", stage, logger)
+			}
+		}
+		signature := hex.EncodeToString(builder.PsbtUpdater.Upsbt.Inputs[1].FinalScriptWitness[2:66])
+		if strings.Contains(logger.String(), signature) != sensitive {
+			t.Fatalf("signature logged = %v, want %v:\n%s", !sensitive, sensitive, logger)
+		}
+		if !sensitive && !strings.Contains(logger.String(), redacted) {
+			t.Fatalf("no redacted values in:\n%s", logger)
+		}
+	}
+}
+
+func TestPsbtBuilder_SetLoggerRedactsInputs(t *testing.T) {
+	for _, sensitive := range []bool{false, true} {
+		logger := &testLogger{}
+		builder := testBuilder(t, 2)
+		builder.SetLogger(logger)
+		if sensitive {
+			builder.SetLogSensitive(true)
+		}
+		builder.SetSignWorkers(2)
+		if err := builder.UpdateAndSignInput(testSignIns(2)); err != nil {
+			t.Fatalf("UpdateAndSignInput() error = %v", err)
+		}
+
+		var identifying []string
+		for i := 0; i < 2; i++ {
+			pubKey := testPrivKey(byte(i + 1)).PubKey().SerializeCompressed()
+			identifying = append(identifying, builder.outPoint(i).String(), hex.EncodeToString(pubKey),
+				hex.EncodeToString(pubKey[1:]))
+		}
+		logged := 0
+		for _, v := range identifying {
+			if strings.Contains(logger.String(), v) {
+				logged++
+				if !sensitive {
+					t.Fatalf("%s logged by a default builder:\n%s", v, logger)
+				}
+			}
+		}
+		if sensitive && logged == 0 {
+			t.Fatalf("no outpoint or pubkey logged with sensitive logging:\n%s", logger)
+		}
+	}
+}
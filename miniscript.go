@@ -0,0 +1,703 @@
+package psbt_sdk
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+type MiniscriptContext int
+
+const (
+	SegwitV0  MiniscriptContext = 0
+	Tapscript MiniscriptContext = 1
+)
+
+const (
+	maxStandardP2wshScriptSize = 3600
+	maxMultiSigKeys            = 20
+	maxTapscriptMultiAKeys     = 999
+	sequenceLockTimeDisabled   = 1 << 31
+	sequenceLockTimeIsSeconds  = 1 << 22
+	sequenceLockTimeMask       = 0x0000ffff
+	lockTimeThreshold          = 500000000
+)
+
+// msType is the miniscript correctness type of a fragment: one of the basic
+// types B, V, K, W and the z, o, n, d, u properties, the e, f, s, m
+// malleability properties and the g, h, i, j, k timelock properties.
+type msType struct {
+	base byte
+	z    bool
+	o    bool
+	n    bool
+	d    bool
+	u    bool
+	e    bool // dissatisfaction is unique and needs no signature
+	f    bool // never dissatisfied
+	s    bool // satisfaction needs a signature
+	m    bool // non-malleable satisfaction exists
+	g    bool // relative time timelock
+	h    bool // relative height timelock
+	i    bool // absolute time timelock
+	j    bool // absolute height timelock
+	k    bool // no height and time timelocks in one spending path
+}
+
+type msNode struct {
+	frag string
+	keys [][]byte
+	hash []byte
+	k    uint32
+	subs []*msNode
+	typ  msType
+}
+
+type Miniscript struct {
+	ctx    MiniscriptContext
+	expr   string
+	root   *msNode
+	script []byte
+}
+
+// Parse miniscript expression for segwit v0 (P2WSH) or tapscript
+func ParseMiniscript(expr string, ctx MiniscriptContext) (*Miniscript, error) {
+	if ctx != SegwitV0 && ctx != Tapscript {
+		return nil, errors.New(fmt.Sprintf("unknown miniscript context %d", ctx))
+	}
+	expr = strings.Join(strings.Fields(expr), "")
+	root, err := parseMsNode(expr, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if root.typ.base != 'B' {
+		return nil, errors.New(fmt.Sprintf("miniscript top level must be type B, got %c", root.typ.base))
+	}
+	script, err := root.compile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if ctx == SegwitV0 && len(script) > maxStandardP2wshScriptSize {
+		return nil, errors.New(fmt.Sprintf("miniscript script size %d exceeds %d", len(script), maxStandardP2wshScriptSize))
+	}
+	return &Miniscript{ctx: ctx, expr: expr, root: root, script: script}, nil
+}
+
+// CheckSane reports why a miniscript is not sane, as Bitcoin Core's IsSane:
+// it mixes height and time timelocks in one spending path, repeats a key,
+// can be satisfied without a signature or has no non-malleable satisfaction
+func (m *Miniscript) CheckSane() error {
+	if !m.root.typ.k {
+		return errors.New(fmt.Sprintf("miniscript %s mixes height and time timelocks", m.expr))
+	}
+	var (
+		keys = make(map[string]bool)
+		walk func(n *msNode) error
+	)
+	walk = func(n *msNode) error {
+		for _, key := range n.keys {
+			if keys[string(key)] {
+				return errors.New(fmt.Sprintf("miniscript %s repeats key %x", m.expr, key))
+			}
+			keys[string(key)] = true
+		}
+		for _, sub := range n.subs {
+			if err := walk(sub); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(m.root); err != nil {
+		return err
+	}
+	if !m.root.typ.s {
+		return errors.New(fmt.Sprintf("miniscript %s can be satisfied without a signature", m.expr))
+	}
+	if !m.root.typ.m {
+		return errors.New(fmt.Sprintf("miniscript %s has no non-malleable satisfaction", m.expr))
+	}
+	return nil
+}
+
+func (m *Miniscript) String() string {
+	return m.expr
+}
+
+func (m *Miniscript) Context() MiniscriptContext {
+	return m.ctx
+}
+
+// Script returns the witness script (segwit v0) or leaf script (tapscript)
+func (m *Miniscript) Script() []byte {
+	return append([]byte{}, m.script...)
+}
+
+func parseMsNode(expr string, ctx MiniscriptContext) (*msNode, error) {
+	paren := strings.IndexByte(expr, '(')
+	colon := strings.IndexByte(expr, ':')
+	if colon >= 0 && (paren < 0 || colon < paren) {
+		wrappers := expr[:colon]
+		if wrappers == "" {
+			return nil, errors.New(fmt.Sprintf("empty wrapper in %q", expr))
+		}
+		node, err := parseMsNode(expr[colon+1:], ctx)
+		if err != nil {
+			return nil, err
+		}
+		for i := len(wrappers) - 1; i >= 0; i-- {
+			node, err = wrapMsNode(wrappers[i], node, ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return node, nil
+	}
+
+	name, args := expr, []string(nil)
+	if paren >= 0 {
+		if !strings.HasSuffix(expr, ")") {
+			return nil, errors.New(fmt.Sprintf("unbalanced parentheses in %q", expr))
+		}
+		name = expr[:paren]
+		var err error
+		args, err = splitMsArgs(expr[paren+1 : len(expr)-1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch name {
+	case "0", "1":
+		if args != nil {
+			return nil, errors.New(fmt.Sprintf("%s takes no arguments", name))
+		}
+		return newMsNode(name, nil, ctx)
+	case "pk_k", "pk_h", "pk", "pkh":
+		if len(args) != 1 {
+			return nil, errors.New(fmt.Sprintf("%s takes one key", name))
+		}
+		key, err := parseMsKey(args[0], ctx)
+		if err != nil {
+			return nil, err
+		}
+		frag := name
+		if name == "pk" || name == "pkh" {
+			frag = map[string]string{"pk": "pk_k", "pkh": "pk_h"}[name]
+		}
+		node, err := newMsNode(frag, nil, ctx, func(n *msNode) { n.keys = [][]byte{key} })
+		if err != nil {
+			return nil, err
+		}
+		if name == "pk" || name == "pkh" {
+			return wrapMsNode('c', node, ctx)
+		}
+		return node, nil
+	case "older", "after":
+		if len(args) != 1 {
+			return nil, errors.New(fmt.Sprintf("%s takes one number", name))
+		}
+		k, err := strconv.ParseUint(args[0], 10, 32)
+		if err != nil || k < 1 || k >= 1<<31 {
+			return nil, errors.New(fmt.Sprintf("invalid %s value %q", name, args[0]))
+		}
+		return newMsNode(name, nil, ctx, func(n *msNode) { n.k = uint32(k) })
+	case "sha256", "hash256", "ripemd160", "hash160":
+		if len(args) != 1 {
+			return nil, errors.New(fmt.Sprintf("%s takes one hash", name))
+		}
+		hash, err := hex.DecodeString(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if len(hash) != msHashLen(name) {
+			return nil, errors.New(fmt.Sprintf("%s hash must be %d bytes", name, msHashLen(name)))
+		}
+		return newMsNode(name, nil, ctx, func(n *msNode) { n.hash = hash })
+	case "andor", "and_v", "and_b", "and_n", "or_b", "or_c", "or_d", "or_i":
+		want := 2
+		if name == "andor" {
+			want = 3
+		}
+		if len(args) != want {
+			return nil, errors.New(fmt.Sprintf("%s takes %d arguments", name, want))
+		}
+		subs := make([]*msNode, 0, len(args))
+		for _, arg := range args {
+			sub, err := parseMsNode(arg, ctx)
+			if err != nil {
+				return nil, err
+			}
+			subs = append(subs, sub)
+		}
+		if name == "and_n" {
+			zero, _ := newMsNode("0", nil, ctx)
+			return newMsNode("andor", append(subs, zero), ctx)
+		}
+		return newMsNode(name, subs, ctx)
+	case "thresh", "multi", "multi_a":
+		if len(args) < 2 {
+			return nil, errors.New(fmt.Sprintf("%s takes a threshold and at least one argument", name))
+		}
+		k, err := strconv.ParseUint(args[0], 10, 32)
+		if err != nil || k < 1 || int(k) > len(args)-1 {
+			return nil, errors.New(fmt.Sprintf("invalid %s threshold %q", name, args[0]))
+		}
+		if name == "thresh" {
+			subs := make([]*msNode, 0, len(args)-1)
+			for _, arg := range args[1:] {
+				sub, err := parseMsNode(arg, ctx)
+				if err != nil {
+					return nil, err
+				}
+				subs = append(subs, sub)
+			}
+			return newMsNode(name, subs, ctx, func(n *msNode) { n.k = uint32(k) })
+		}
+		keys := make([][]byte, 0, len(args)-1)
+		for _, arg := range args[1:] {
+			key, err := parseMsKey(arg, ctx)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+		return newMsNode(name, nil, ctx, func(n *msNode) { n.k = uint32(k); n.keys = keys })
+	}
+	return nil, errors.New(fmt.Sprintf("unknown miniscript fragment %q", name))
+}
+
+func splitMsArgs(s string) ([]string, error) {
+	var (
+		args  []string
+		depth int
+		start int
+	)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, errors.New(fmt.Sprintf("unbalanced parentheses in %q", s))
+			}
+		case ',':
+			if depth == 0 {
+				args = append(args, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, errors.New(fmt.Sprintf("unbalanced parentheses in %q", s))
+	}
+	args = append(args, s[start:])
+	for _, arg := range args {
+		if arg == "" {
+			return nil, errors.New(fmt.Sprintf("empty argument in %q", s))
+		}
+	}
+	return args, nil
+}
+
+func parseMsKey(s string, ctx MiniscriptContext) ([]byte, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid key %q: %s", s, err))
+	}
+	switch ctx {
+	case SegwitV0:
+		if len(key) != 33 || (key[0] != 0x02 && key[0] != 0x03) {
+			return nil, errors.New(fmt.Sprintf("segwit v0 miniscript requires compressed public keys, got %q", s))
+		}
+	case Tapscript:
+		if len(key) != 32 {
+			return nil, errors.New(fmt.Sprintf("tapscript miniscript requires x-only public keys, got %q", s))
+		}
+	}
+	return key, nil
+}
+
+func msHashLen(frag string) int {
+	if frag == "ripemd160" || frag == "hash160" {
+		return 20
+	}
+	return 32
+}
+
+// wrapMsNode applies a single wrapper letter, expanding the t:, l: and u:
+// syntactic sugar.
+func wrapMsNode(w byte, sub *msNode, ctx MiniscriptContext) (*msNode, error) {
+	switch w {
+	case 'a', 's', 'c', 'd', 'v', 'j', 'n':
+		return newMsNode(string(w), []*msNode{sub}, ctx)
+	case 't':
+		one, _ := newMsNode("1", nil, ctx)
+		return newMsNode("and_v", []*msNode{sub, one}, ctx)
+	case 'l':
+		zero, _ := newMsNode("0", nil, ctx)
+		return newMsNode("or_i", []*msNode{zero, sub}, ctx)
+	case 'u':
+		zero, _ := newMsNode("0", nil, ctx)
+		return newMsNode("or_i", []*msNode{sub, zero}, ctx)
+	}
+	return nil, errors.New(fmt.Sprintf("unknown miniscript wrapper %q", w))
+}
+
+func newMsNode(frag string, subs []*msNode, ctx MiniscriptContext, opts ...func(*msNode)) (*msNode, error) {
+	n := &msNode{frag: frag, subs: subs}
+	for _, opt := range opts {
+		opt(n)
+	}
+	typ, err := n.computeType(ctx)
+	if err != nil {
+		return nil, err
+	}
+	n.typ = n.timelockType(typ)
+	return n, nil
+}
+
+// timelockType sets the timelock properties of a fragment. Timelocks of
+// both kinds may only be mixed in different spending paths.
+func (n *msNode) timelockType(t msType) msType {
+	switch n.frag {
+	case "older":
+		t.g, t.h = n.k&sequenceLockTimeIsSeconds != 0, n.k&sequenceLockTimeIsSeconds == 0
+		t.k = true
+		return t
+	case "after":
+		t.i, t.j = n.k >= lockTimeThreshold, n.k < lockTimeThreshold
+		t.k = true
+		return t
+	}
+	t.k = true
+	var acc msType
+	for idx, sub := range n.subs {
+		st := sub.typ
+		t.k = t.k && st.k
+		// the conjunctions need every sub, andor its first two, thresh k
+		// of them
+		conjunction := n.frag == "and_v" || n.frag == "and_b" || (n.frag == "andor" && idx < 2) ||
+			(n.frag == "thresh" && n.k > 1)
+		if conjunction && msTimelocksMix(acc, st) {
+			t.k = false
+		}
+		acc.g, acc.h, acc.i, acc.j = acc.g || st.g, acc.h || st.h, acc.i || st.i, acc.j || st.j
+	}
+	t.g, t.h, t.i, t.j = acc.g, acc.h, acc.i, acc.j
+	return t
+}
+
+func msTimelocksMix(a, b msType) bool {
+	return (a.g && b.h) || (a.h && b.g) || (a.i && b.j) || (a.j && b.i)
+}
+
+func (n *msNode) computeType(ctx MiniscriptContext) (msType, error) {
+	var x, y, z msType
+	if len(n.subs) > 0 {
+		x = n.subs[0].typ
+	}
+	if len(n.subs) > 1 {
+		y = n.subs[1].typ
+	}
+	if len(n.subs) > 2 {
+		z = n.subs[2].typ
+	}
+	bad := func(req string) (msType, error) {
+		return msType{}, errors.New(fmt.Sprintf("miniscript %s: %s", n.frag, req))
+	}
+
+	switch n.frag {
+	case "0":
+		return msType{base: 'B', z: true, u: true, d: true, e: true, s: true, m: true}, nil
+	case "1":
+		return msType{base: 'B', z: true, u: true, f: true, m: true}, nil
+	case "pk_k":
+		return msType{base: 'K', o: true, n: true, d: true, u: true, e: true, s: true, m: true}, nil
+	case "pk_h":
+		return msType{base: 'K', n: true, d: true, u: true, e: true, s: true, m: true}, nil
+	case "older", "after":
+		return msType{base: 'B', z: true, f: true, m: true}, nil
+	case "sha256", "hash256", "ripemd160", "hash160":
+		return msType{base: 'B', o: true, n: true, d: true, u: true, m: true}, nil
+	case "multi":
+		if ctx != SegwitV0 {
+			return bad("only valid in segwit v0, use multi_a in tapscript")
+		}
+		if len(n.keys) > maxMultiSigKeys {
+			return bad(fmt.Sprintf("at most %d keys", maxMultiSigKeys))
+		}
+		return msType{base: 'B', n: true, d: true, u: true, e: true, s: true, m: true}, nil
+	case "multi_a":
+		if ctx != Tapscript {
+			return bad("only valid in tapscript, use multi in segwit v0")
+		}
+		if len(n.keys) > maxTapscriptMultiAKeys {
+			return bad(fmt.Sprintf("at most %d keys", maxTapscriptMultiAKeys))
+		}
+		return msType{base: 'B', d: true, u: true, e: true, s: true, m: true}, nil
+	case "andor":
+		if x.base != 'B' || !x.d || !x.u {
+			return bad("first argument must be Bdu")
+		}
+		if y.base != z.base || (y.base != 'B' && y.base != 'K' && y.base != 'V') {
+			return bad("second and third arguments must both be B, K or V")
+		}
+		return msType{
+			base: y.base,
+			z:    x.z && y.z && z.z,
+			o:    (x.z && y.o && z.o) || (x.o && y.z && z.z),
+			u:    y.u && z.u,
+			d:    z.d,
+			e:    z.e && (x.s || y.f),
+			f:    z.f && (x.s || y.f),
+			s:    z.s && (x.s || y.s),
+			m:    x.m && y.m && z.m && x.e && (x.s || y.s || z.s),
+		}, nil
+	case "and_v":
+		if x.base != 'V' || (y.base != 'B' && y.base != 'K' && y.base != 'V') {
+			return bad("requires V and B, K or V arguments")
+		}
+		return msType{
+			base: y.base,
+			z:    x.z && y.z,
+			o:    (x.z && y.o) || (x.o && y.z),
+			n:    x.n || (x.z && y.n),
+			u:    y.u,
+			f:    y.f || x.s,
+			s:    x.s || y.s,
+			m:    x.m && y.m,
+		}, nil
+	case "and_b":
+		if x.base != 'B' || y.base != 'W' {
+			return bad("requires B and W arguments")
+		}
+		return msType{
+			base: 'B',
+			z:    x.z && y.z,
+			o:    (x.z && y.o) || (x.o && y.z),
+			n:    x.n || (x.z && y.n),
+			d:    x.d && y.d,
+			u:    true,
+			e:    x.e && y.e && x.s && y.s,
+			f:    (x.f && y.f) || (x.s && x.f) || (y.s && y.f),
+			s:    x.s || y.s,
+			m:    x.m && y.m,
+		}, nil
+	case "or_b":
+		if x.base != 'B' || !x.d || y.base != 'W' || !y.d {
+			return bad("requires Bd and Wd arguments")
+		}
+		return msType{
+			base: 'B',
+			z:    x.z && y.z,
+			o:    (x.z && y.o) || (x.o && y.z),
+			d:    true,
+			u:    true,
+			e:    x.e && y.e,
+			s:    x.s && y.s,
+			m:    x.m && y.m && x.e && y.e && (x.s || y.s),
+		}, nil
+	case "or_c":
+		if x.base != 'B' || !x.d || !x.u || y.base != 'V' {
+			return bad("requires Bdu and V arguments")
+		}
+		return msType{base: 'V', z: x.z && y.z, o: x.o && y.z, f: true, s: x.s && y.s,
+			m: x.m && y.m && x.e && (x.s || y.s)}, nil
+	case "or_d":
+		if x.base != 'B' || !x.d || !x.u || y.base != 'B' {
+			return bad("requires Bdu and B arguments")
+		}
+		return msType{base: 'B', z: x.z && y.z, o: x.o && y.z, d: y.d, u: y.u, e: x.e && y.e, f: y.f,
+			s: x.s && y.s, m: x.m && y.m && x.e && (x.s || y.s)}, nil
+	case "or_i":
+		if x.base != y.base || (x.base != 'B' && x.base != 'K' && x.base != 'V') {
+			return bad("arguments must both be B, K or V")
+		}
+		return msType{base: x.base, o: x.z && y.z, u: x.u && y.u, d: x.d || y.d,
+			e: (x.e && y.f) || (x.f && y.e), f: x.f && y.f, s: x.s && y.s, m: x.m && y.m && (x.s || y.s)}, nil
+	case "thresh":
+		var (
+			allZ  = true
+			allE  = true
+			allM  = true
+			zOrO  = 0
+			numS  = 0
+			first = true
+		)
+		for i, sub := range n.subs {
+			t := sub.typ
+			if first && (t.base != 'B' || !t.d || !t.u) {
+				return bad("first argument must be Bdu")
+			}
+			if !first && (t.base != 'W' || !t.d || !t.u) {
+				return bad(fmt.Sprintf("argument %d must be Wdu", i+1))
+			}
+			first = false
+			allE, allM = allE && t.e, allM && t.m
+			if t.s {
+				numS++
+			}
+			if !t.z {
+				allZ = false
+				if t.o {
+					zOrO++
+				} else {
+					zOrO = len(n.subs) + 1
+				}
+			}
+		}
+		subs := len(n.subs)
+		return msType{base: 'B', z: allZ, o: !allZ && zOrO == 1, d: true, u: true, e: allE && numS == subs,
+			s: numS >= subs-int(n.k)+1, m: allE && allM && numS >= subs-int(n.k)}, nil
+	case "a":
+		if x.base != 'B' {
+			return bad("requires a B argument")
+		}
+		return msType{base: 'W', d: x.d, u: x.u, e: x.e, f: x.f, s: x.s, m: x.m}, nil
+	case "s":
+		if x.base != 'B' || !x.o {
+			return bad("requires a Bo argument")
+		}
+		return msType{base: 'W', d: x.d, u: x.u, e: x.e, f: x.f, s: x.s, m: x.m}, nil
+	case "c":
+		if x.base != 'K' {
+			return bad("requires a K argument")
+		}
+		return msType{base: 'B', o: x.o, n: x.n, d: x.d, u: true, e: x.e, f: x.f, s: true, m: x.m}, nil
+	case "d":
+		if x.base != 'V' || !x.z {
+			return bad("requires a Vz argument")
+		}
+		return msType{base: 'B', o: true, n: true, d: true, u: ctx == Tapscript, e: x.f, s: x.s, m: x.m}, nil
+	case "v":
+		if x.base != 'B' {
+			return bad("requires a B argument")
+		}
+		return msType{base: 'V', z: x.z, o: x.o, n: x.n, f: true, s: x.s, m: x.m}, nil
+	case "j":
+		if x.base != 'B' || !x.n {
+			return bad("requires a Bn argument")
+		}
+		return msType{base: 'B', o: x.o, n: true, d: true, u: x.u, e: x.f, s: x.s, m: x.m}, nil
+	case "n":
+		if x.base != 'B' {
+			return bad("requires a B argument")
+		}
+		return msType{base: 'B', z: x.z, o: x.o, n: x.n, d: x.d, u: true, e: x.e, f: x.f, s: x.s, m: x.m}, nil
+	}
+	return bad("unknown fragment")
+}
+
+func (n *msNode) compile(ctx MiniscriptContext) ([]byte, error) {
+	var (
+		b    = txscript.NewScriptBuilder()
+		subs = make([][]byte, len(n.subs))
+		err  error
+	)
+	for i, sub := range n.subs {
+		subs[i], err = sub.compile(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch n.frag {
+	case "0":
+		b.AddOp(txscript.OP_0)
+	case "1":
+		b.AddOp(txscript.OP_1)
+	case "pk_k":
+		b.AddData(n.keys[0])
+	case "pk_h":
+		b.AddOp(txscript.OP_DUP).AddOp(txscript.OP_HASH160).AddData(btcutil.Hash160(n.keys[0])).AddOp(txscript.OP_EQUALVERIFY)
+	case "older":
+		b.AddInt64(int64(n.k)).AddOp(txscript.OP_CHECKSEQUENCEVERIFY)
+	case "after":
+		b.AddInt64(int64(n.k)).AddOp(txscript.OP_CHECKLOCKTIMEVERIFY)
+	case "sha256", "hash256", "ripemd160", "hash160":
+		op := map[string]byte{
+			"sha256":    txscript.OP_SHA256,
+			"hash256":   txscript.OP_HASH256,
+			"ripemd160": txscript.OP_RIPEMD160,
+			"hash160":   txscript.OP_HASH160,
+		}[n.frag]
+		b.AddOp(txscript.OP_SIZE).AddInt64(32).AddOp(txscript.OP_EQUALVERIFY).AddOp(op).AddData(n.hash).AddOp(txscript.OP_EQUAL)
+	case "andor":
+		return msCat(subs[0], []byte{txscript.OP_NOTIF}, subs[2], []byte{txscript.OP_ELSE}, subs[1], []byte{txscript.OP_ENDIF}), nil
+	case "and_v":
+		return msCat(subs[0], subs[1]), nil
+	case "and_b":
+		return msCat(subs[0], subs[1], []byte{txscript.OP_BOOLAND}), nil
+	case "or_b":
+		return msCat(subs[0], subs[1], []byte{txscript.OP_BOOLOR}), nil
+	case "or_c":
+		return msCat(subs[0], []byte{txscript.OP_NOTIF}, subs[1], []byte{txscript.OP_ENDIF}), nil
+	case "or_d":
+		return msCat(subs[0], []byte{txscript.OP_IFDUP, txscript.OP_NOTIF}, subs[1], []byte{txscript.OP_ENDIF}), nil
+	case "or_i":
+		return msCat([]byte{txscript.OP_IF}, subs[0], []byte{txscript.OP_ELSE}, subs[1], []byte{txscript.OP_ENDIF}), nil
+	case "thresh":
+		script := msCat(subs[0])
+		for _, sub := range subs[1:] {
+			script = msCat(script, sub, []byte{txscript.OP_ADD})
+		}
+		tail, err := b.AddInt64(int64(n.k)).AddOp(txscript.OP_EQUAL).Script()
+		if err != nil {
+			return nil, err
+		}
+		return msCat(script, tail), nil
+	case "multi":
+		b.AddInt64(int64(n.k))
+		for _, key := range n.keys {
+			b.AddData(key)
+		}
+		b.AddInt64(int64(len(n.keys))).AddOp(txscript.OP_CHECKMULTISIG)
+	case "multi_a":
+		for i, key := range n.keys {
+			b.AddData(key)
+			if i == 0 {
+				b.AddOp(txscript.OP_CHECKSIG)
+			} else {
+				b.AddOp(txscript.OP_CHECKSIGADD)
+			}
+		}
+		b.AddInt64(int64(n.k)).AddOp(txscript.OP_NUMEQUAL)
+	case "a":
+		return msCat([]byte{txscript.OP_TOALTSTACK}, subs[0], []byte{txscript.OP_FROMALTSTACK}), nil
+	case "s":
+		return msCat([]byte{txscript.OP_SWAP}, subs[0]), nil
+	case "c":
+		return msCat(subs[0], []byte{txscript.OP_CHECKSIG}), nil
+	case "d":
+		return msCat([]byte{txscript.OP_DUP, txscript.OP_IF}, subs[0], []byte{txscript.OP_ENDIF}), nil
+	case "v":
+		script := msCat(subs[0])
+		switch script[len(script)-1] {
+		case txscript.OP_EQUAL, txscript.OP_CHECKSIG, txscript.OP_CHECKMULTISIG, txscript.OP_NUMEQUAL:
+			// the VERIFY variant of each of these opcodes is the next opcode
+			script[len(script)-1]++
+			return script, nil
+		}
+		return msCat(script, []byte{txscript.OP_VERIFY}), nil
+	case "j":
+		return msCat([]byte{txscript.OP_SIZE, txscript.OP_0NOTEQUAL, txscript.OP_IF}, subs[0], []byte{txscript.OP_ENDIF}), nil
+	case "n":
+		return msCat(subs[0], []byte{txscript.OP_0NOTEQUAL}), nil
+	}
+	return b.Script()
+}
+
+func msCat(parts ...[]byte) []byte {
+	script := make([]byte, 0)
+	for _, p := range parts {
+		script = append(script, p...)
+	}
+	return script
+}
@@ -0,0 +1,383 @@
+package psbt_sdk
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// MiniscriptSatisfier supplies the data needed to satisfy a miniscript:
+// signatures by public key, hash preimages and timelock availability.
+type MiniscriptSatisfier interface {
+	// Signature returns the serialized signature (including the sighash
+	// byte where one is required) for the given public key.
+	Signature(pubKey []byte) ([]byte, bool)
+	// Preimage returns the preimage of hash under hashFunc, one of
+	// "sha256", "hash256", "ripemd160" or "hash160".
+	Preimage(hashFunc string, hash []byte) ([]byte, bool)
+	CheckOlder(n uint32) bool
+	CheckAfter(n uint32) bool
+}
+
+// msSize is an upper bound of a witness stack: element count and serialized
+// size of the elements.
+type msSize struct {
+	ok    bool
+	elems int
+	size  int
+}
+
+func (a msSize) add(b msSize) msSize {
+	if !a.ok || !b.ok {
+		return msSize{}
+	}
+	return msSize{ok: true, elems: a.elems + b.elems, size: a.size + b.size}
+}
+
+func (a msSize) or(b msSize) msSize {
+	switch {
+	case !a.ok:
+		return b
+	case !b.ok:
+		return a
+	}
+	if b.elems > a.elems {
+		a.elems = b.elems
+	}
+	if b.size > a.size {
+		a.size = b.size
+	}
+	return a
+}
+
+func msElem(n int) msSize {
+	return msSize{ok: true, elems: 1, size: wire.VarIntSerializeSize(uint64(n)) + n}
+}
+
+var msEmpty = msSize{ok: true}
+
+func msSigLen(ctx MiniscriptContext) int {
+	if ctx == Tapscript {
+		// 64 byte schnorr signature plus an optional sighash byte
+		return 65
+	}
+	// DER signature of at most 72 bytes plus the sighash byte
+	return 73
+}
+
+// maxSizes returns upper bounds for the satisfaction and dissatisfaction
+// witness stacks of n.
+func (n *msNode) maxSizes(ctx MiniscriptContext) (sat msSize, dsat msSize) {
+	subSat := make([]msSize, len(n.subs))
+	subDsat := make([]msSize, len(n.subs))
+	for i, sub := range n.subs {
+		subSat[i], subDsat[i] = sub.maxSizes(ctx)
+	}
+
+	switch n.frag {
+	case "0":
+		return msSize{}, msEmpty
+	case "1", "older", "after":
+		return msEmpty, msSize{}
+	case "pk_k":
+		return msElem(msSigLen(ctx)), msElem(0)
+	case "pk_h":
+		return msElem(msSigLen(ctx)).add(msElem(len(n.keys[0]))), msElem(0).add(msElem(len(n.keys[0])))
+	case "sha256", "hash256", "ripemd160", "hash160":
+		return msElem(32), msElem(32)
+	case "andor":
+		sat = subSat[1].add(subSat[0]).or(subSat[2].add(subDsat[0]))
+		dsat = subDsat[2].add(subDsat[0])
+	case "and_v":
+		sat = subSat[1].add(subSat[0])
+		dsat = subDsat[1].add(subSat[0])
+	case "and_b":
+		sat = subSat[1].add(subSat[0])
+		dsat = subDsat[1].add(subDsat[0]).or(subSat[1].add(subDsat[0])).or(subDsat[1].add(subSat[0]))
+	case "or_b":
+		sat = subDsat[1].add(subSat[0]).or(subSat[1].add(subDsat[0])).or(subSat[1].add(subSat[0]))
+		dsat = subDsat[1].add(subDsat[0])
+	case "or_c":
+		sat = subSat[0].or(subSat[1].add(subDsat[0]))
+	case "or_d":
+		sat = subSat[0].or(subSat[1].add(subDsat[0]))
+		dsat = subDsat[1].add(subDsat[0])
+	case "or_i":
+		sat = subSat[0].add(msElem(1)).or(subSat[1].add(msElem(0)))
+		dsat = subDsat[0].add(msElem(1)).or(subDsat[1].add(msElem(0)))
+	case "thresh":
+		// dp[j] is the largest stack with exactly j satisfied subs
+		dp := []msSize{msEmpty}
+		for i := range n.subs {
+			next := make([]msSize, len(dp)+1)
+			for j, prev := range dp {
+				next[j] = next[j].or(prev.add(subDsat[i]))
+				next[j+1] = next[j+1].or(prev.add(subSat[i]))
+			}
+			dp = next
+		}
+		sat = dp[n.k]
+		for j, v := range dp {
+			if j != int(n.k) {
+				dsat = dsat.or(v)
+			}
+		}
+	case "multi":
+		sat = msElem(0)
+		for i := uint32(0); i < n.k; i++ {
+			sat = sat.add(msElem(msSigLen(ctx)))
+		}
+		dsat = msElem(0)
+		for i := uint32(0); i < n.k; i++ {
+			dsat = dsat.add(msElem(0))
+		}
+	case "multi_a":
+		sat, dsat = msEmpty, msEmpty
+		for i := range n.keys {
+			if i < int(n.k) {
+				sat = sat.add(msElem(msSigLen(ctx)))
+			} else {
+				sat = sat.add(msElem(0))
+			}
+			dsat = dsat.add(msElem(0))
+		}
+	case "a", "s", "c", "n":
+		return subSat[0], subDsat[0]
+	case "d":
+		return subSat[0].add(msElem(1)), msElem(0)
+	case "v":
+		return subSat[0], msSize{}
+	case "j":
+		return subSat[0], msElem(0)
+	}
+	return sat, dsat
+}
+
+// MaxSatisfactionSize returns the maximum number of witness elements and
+// their serialized size needed to satisfy the script, not counting the
+// script itself.
+func (m *Miniscript) MaxSatisfactionSize() (int, int, error) {
+	sat, _ := m.root.maxSizes(m.ctx)
+	if !sat.ok {
+		return 0, 0, errors.New(fmt.Sprintf("miniscript %s can not be satisfied", m.expr))
+	}
+	return sat.elems, sat.size, nil
+}
+
+// MaxWitnessSize returns the maximum serialized witness size of an input
+// spending the script. For tapscript the control block is not included and
+// must be added by the caller.
+func (m *Miniscript) MaxWitnessSize() (int, error) {
+	elems, size, err := m.MaxSatisfactionSize()
+	if err != nil {
+		return 0, err
+	}
+	// the script and, for tapscript, the control block follow the satisfaction
+	elems++
+	if m.ctx == Tapscript {
+		elems++
+	}
+	return wire.VarIntSerializeSize(uint64(elems)) + size +
+		wire.VarIntSerializeSize(uint64(len(m.script))) + len(m.script), nil
+}
+
+// msWitness is a candidate witness stack, ok is false when it can not be
+// produced with the available data.
+type msWitness struct {
+	ok    bool
+	stack [][]byte
+}
+
+func msStack(elems ...[]byte) msWitness {
+	return msWitness{ok: true, stack: elems}
+}
+
+func (a msWitness) add(b msWitness) msWitness {
+	if !a.ok || !b.ok {
+		return msWitness{}
+	}
+	stack := make([][]byte, 0, len(a.stack)+len(b.stack))
+	stack = append(append(stack, a.stack...), b.stack...)
+	return msWitness{ok: true, stack: stack}
+}
+
+func (a msWitness) size() int {
+	size := 0
+	for _, elem := range a.stack {
+		size += wire.VarIntSerializeSize(uint64(len(elem))) + len(elem)
+	}
+	return size
+}
+
+// or picks the smaller of two available witnesses.
+func (a msWitness) or(b msWitness) msWitness {
+	switch {
+	case !a.ok:
+		return b
+	case !b.ok:
+		return a
+	case b.size() < a.size():
+		return b
+	}
+	return a
+}
+
+func (n *msNode) satisfy(ctx MiniscriptContext, sat MiniscriptSatisfier) (msWitness, msWitness) {
+	subSat := make([]msWitness, len(n.subs))
+	subDsat := make([]msWitness, len(n.subs))
+	for i, sub := range n.subs {
+		subSat[i], subDsat[i] = sub.satisfy(ctx, sat)
+	}
+	var (
+		one  = []byte{1}
+		zero = []byte{}
+		s, d msWitness
+	)
+
+	switch n.frag {
+	case "0":
+		return msWitness{}, msStack()
+	case "1":
+		return msStack(), msWitness{}
+	case "pk_k":
+		if sig, ok := sat.Signature(n.keys[0]); ok {
+			s = msStack(sig)
+		}
+		return s, msStack(zero)
+	case "pk_h":
+		if sig, ok := sat.Signature(n.keys[0]); ok {
+			s = msStack(sig, n.keys[0])
+		}
+		return s, msStack(zero, n.keys[0])
+	case "older":
+		if sat.CheckOlder(n.k) {
+			s = msStack()
+		}
+		return s, msWitness{}
+	case "after":
+		if sat.CheckAfter(n.k) {
+			s = msStack()
+		}
+		return s, msWitness{}
+	case "sha256", "hash256", "ripemd160", "hash160":
+		if preimage, ok := sat.Preimage(n.frag, n.hash); ok && len(preimage) == 32 {
+			s = msStack(preimage)
+		}
+		return s, msStack(make([]byte, 32))
+	case "andor":
+		s = subSat[1].add(subSat[0]).or(subSat[2].add(subDsat[0]))
+		d = subDsat[2].add(subDsat[0])
+	case "and_v":
+		s = subSat[1].add(subSat[0])
+		d = subDsat[1].add(subSat[0])
+	case "and_b":
+		s = subSat[1].add(subSat[0])
+		d = subDsat[1].add(subDsat[0])
+	case "or_b":
+		s = subDsat[1].add(subSat[0]).or(subSat[1].add(subDsat[0]))
+		d = subDsat[1].add(subDsat[0])
+	case "or_c":
+		s = subSat[0].or(subSat[1].add(subDsat[0]))
+	case "or_d":
+		s = subSat[0].or(subSat[1].add(subDsat[0]))
+		d = subDsat[1].add(subDsat[0])
+	case "or_i":
+		s = subSat[0].add(msStack(one)).or(subSat[1].add(msStack(zero)))
+		d = subDsat[0].add(msStack(one)).or(subDsat[1].add(msStack(zero)))
+	case "thresh":
+		// dp[j] is the smallest stack with exactly j satisfied subs; the
+		// first sub runs first so its witness ends up on top of the stack
+		dp := []msWitness{msStack()}
+		for i := range n.subs {
+			next := make([]msWitness, len(dp)+1)
+			for j, prev := range dp {
+				next[j] = next[j].or(subDsat[i].add(prev))
+				next[j+1] = next[j+1].or(subSat[i].add(prev))
+			}
+			dp = next
+		}
+		s, d = dp[n.k], dp[0]
+	case "multi":
+		sigs := make([][]byte, 0, n.k)
+		for _, key := range n.keys {
+			if sig, ok := sat.Signature(key); ok && len(sigs) < int(n.k) {
+				sigs = append(sigs, sig)
+			}
+		}
+		if len(sigs) == int(n.k) {
+			s = msStack(append([][]byte{zero}, sigs...)...)
+		}
+		d = msStack(make([][]byte, n.k+1)...)
+		for i := range d.stack {
+			d.stack[i] = zero
+		}
+	case "multi_a":
+		var (
+			stack = make([][]byte, len(n.keys))
+			count = 0
+		)
+		// the first key's CHECKSIG consumes the top of the stack
+		for i, key := range n.keys {
+			pos := len(n.keys) - 1 - i
+			stack[pos] = zero
+			if sig, ok := sat.Signature(key); ok && count < int(n.k) {
+				stack[pos] = sig
+				count++
+			}
+		}
+		if count == int(n.k) {
+			s = msStack(stack...)
+		}
+		d = msStack(make([][]byte, len(n.keys))...)
+		for i := range d.stack {
+			d.stack[i] = zero
+		}
+	case "a", "s", "c", "n":
+		return subSat[0], subDsat[0]
+	case "d":
+		return subSat[0].add(msStack(one)), msStack(zero)
+	case "v":
+		return subSat[0], msWitness{}
+	case "j":
+		return subSat[0], msStack(zero)
+	}
+	return s, d
+}
+
+// Satisfy builds the smallest witness stack satisfying the script from the
+// data available to sat. The returned stack does not include the script or
+// control block.
+func (m *Miniscript) Satisfy(sat MiniscriptSatisfier) (wire.TxWitness, error) {
+	s, _ := m.root.satisfy(m.ctx, sat)
+	if !s.ok {
+		return nil, errors.New(fmt.Sprintf("miniscript %s can not be satisfied with the available data", m.expr))
+	}
+	return s.stack, nil
+}
+
+// Keys returns the public keys referenced by the script, in script order
+// and without duplicates.
+func (m *Miniscript) Keys() [][]byte {
+	var (
+		keys [][]byte
+		walk func(n *msNode)
+	)
+	walk = func(n *msNode) {
+		for _, key := range n.keys {
+			dup := false
+			for _, k := range keys {
+				dup = dup || bytes.Equal(k, key)
+			}
+			if !dup {
+				keys = append(keys, key)
+			}
+		}
+		for _, sub := range n.subs {
+			walk(sub)
+		}
+	}
+	walk(m.root)
+	return keys
+}
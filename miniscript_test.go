@@ -0,0 +1,238 @@
+package psbt_sdk
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func testPrivKey(i byte) *btcec.PrivateKey {
+	privateKey, _ := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{i}, 32))
+	return privateKey
+}
+
+func testPubHex(i byte, ctx MiniscriptContext) string {
+	if ctx == Tapscript {
+		return hex.EncodeToString(schnorr.SerializePubKey(testPrivKey(i).PubKey()))
+	}
+	return hex.EncodeToString(testPrivKey(i).PubKey().SerializeCompressed())
+}
+
+func TestParseMiniscript(t *testing.T) {
+	key := testPubHex(1, SegwitV0)
+	ms, err := ParseMiniscript(fmt.Sprintf("pk(%s)", key), SegwitV0)
+	if err != nil {
+		t.Fatalf("ParseMiniscript() error = %v", err)
+	}
+	if got, want := hex.EncodeToString(ms.Script()), "21"+key+"ac"; got != want {
+		t.Fatalf("Script() = %s, want %s", got, want)
+	}
+	// 1 + 74 signature + 1 + 35 script
+	if size, _ := ms.MaxWitnessSize(); size != 111 {
+		t.Fatalf("MaxWitnessSize() = %d, want 111", size)
+	}
+
+	invalid := []string{
+		fmt.Sprintf("and_v(pk(%s),pk(%s))", key, key),
+		fmt.Sprintf("pk_k(%s)", key),
+		fmt.Sprintf("multi_a(1,%s)", key),
+		"older(0)",
+		"sha256(00)",
+		fmt.Sprintf("or_b(pk(%s)", key),
+	}
+	for _, expr := range invalid {
+		if _, err := ParseMiniscript(expr, SegwitV0); err == nil {
+			t.Errorf("ParseMiniscript(%q) expected error", expr)
+		}
+	}
+}
+
+func TestMiniscript_CheckSane(t *testing.T) {
+	key := func(i byte) string { return testPubHex(i, SegwitV0) }
+	sane := []string{
+		fmt.Sprintf("multi(2,%s,%s)", key(1), key(2)),
+		fmt.Sprintf("or_d(pk(%s),and_v(v:pkh(%s),older(10)))", key(1), key(2)),
+		// height and time timelocks in different spending paths
+		fmt.Sprintf("or_i(and_v(v:pk(%s),older(10)),and_v(v:pk(%s),older(4194305)))", key(1), key(2)),
+	}
+	for _, expr := range sane {
+		ms, err := ParseMiniscript(expr, SegwitV0)
+		if err != nil {
+			t.Fatalf("ParseMiniscript(%q) error = %v", expr, err)
+		}
+		if err = ms.CheckSane(); err != nil {
+			t.Errorf("CheckSane(%q) error = %v", expr, err)
+		}
+	}
+
+	insane := map[string]string{
+		"thresh(2,older(10),s:older(4194305))":                                                    "",
+		fmt.Sprintf("and_v(v:older(10),and_v(v:older(4194305),pk(%s)))", key(1)):                  "timelocks",
+		fmt.Sprintf("thresh(2,pk(%s),s:pk(%s),sln:older(10),sln:older(4194305))", key(1), key(2)): "timelocks",
+		fmt.Sprintf("and_v(v:pk(%s),pk(%s))", key(1), key(1)):                                     "repeats key",
+		fmt.Sprintf("multi(1,%s,%s)", key(1), key(1)):                                             "repeats key",
+		"and_v(v:older(10),sha256(" + strings.Repeat("42", 32) + "))":                             "without a signature",
+		fmt.Sprintf("and_v(v:pk(%s),or_i(older(10),after(10)))", key(1)):                          "non-malleable",
+	}
+	for expr, reason := range insane {
+		ms, err := ParseMiniscript(expr, SegwitV0)
+		if err == nil {
+			err = ms.CheckSane()
+		}
+		if err == nil || !strings.Contains(err.Error(), reason) {
+			t.Errorf("ParseMiniscript(%q).CheckSane() error = %v, want %q", expr, err, reason)
+		}
+	}
+
+	// the builder refuses to attach a miniscript that is not sane
+	ms, _ := ParseMiniscript(fmt.Sprintf("and_v(v:pk(%s),pk(%s))", key(1), key(1)), SegwitV0)
+	scriptHash := sha256.Sum256(ms.Script())
+	builder := testBuilder(t, 1)
+	err := builder.UpdateMiniscriptInput([]*InputSign{{UtxoType: Witness, Index: 0, Amount: 60000,
+		PkScript: "0020" + hex.EncodeToString(scriptHash[:]), SighashType: txscript.SigHashAll, Miniscript: ms.String()}})
+	if err == nil || builder.PsbtUpdater.Upsbt.Inputs[0].WitnessScript != nil {
+		t.Fatalf("UpdateMiniscriptInput() of a repeated key error = %v, want error", err)
+	}
+}
+
+func TestPsbtBuilder_Miniscript(t *testing.T) {
+	preimage := bytes.Repeat([]byte{0x42}, 32)
+	hash := sha256.Sum256(preimage)
+	seg := func(i byte) string { return testPubHex(i, SegwitV0) }
+	tap := func(i byte) string { return testPubHex(i, Tapscript) }
+
+	tests := []struct {
+		name     string
+		ctx      MiniscriptContext
+		expr     string
+		signers  []byte
+		sequence uint32
+		preimage bool
+	}{
+		{"multi", SegwitV0, fmt.Sprintf("multi(2,%s,%s,%s)", seg(1), seg(2), seg(3)), []byte{1, 3}, wire.MaxTxInSequenceNum, false},
+		{"thresh", SegwitV0, fmt.Sprintf("thresh(2,pk(%s),s:pk(%s),s:pk(%s))", seg(1), seg(2), seg(3)), []byte{2, 3}, wire.MaxTxInSequenceNum, false},
+		{"timelock", SegwitV0, fmt.Sprintf("or_d(pk(%s),and_v(v:pkh(%s),older(10)))", seg(1), seg(2)), []byte{2}, 10, false},
+		{"hashlock", SegwitV0, fmt.Sprintf("and_v(v:sha256(%x),pk(%s))", hash, seg(1)), []byte{1}, wire.MaxTxInSequenceNum, true},
+		{"multi_a", Tapscript, fmt.Sprintf("multi_a(2,%s,%s,%s)", tap(1), tap(2), tap(3)), []byte{1, 3}, wire.MaxTxInSequenceNum, false},
+		{"tap_timelock", Tapscript, fmt.Sprintf("andor(pk(%s),older(5),pk(%s))", tap(1), tap(2)), []byte{2}, wire.MaxTxInSequenceNum, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms, err := ParseMiniscript(tt.expr, tt.ctx)
+			if err != nil {
+				t.Fatalf("ParseMiniscript() error = %v", err)
+			}
+
+			var (
+				pkScript     []byte
+				controlBlock []byte
+				utxoType     = Witness
+			)
+			if tt.ctx == SegwitV0 {
+				scriptHash := sha256.Sum256(ms.Script())
+				pkScript, _ = txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(scriptHash[:]).Script()
+			} else {
+				utxoType = Taproot
+				internalKey := testPrivKey(9).PubKey()
+				tree := txscript.AssembleTaprootScriptTree(txscript.NewBaseTapLeaf(ms.Script()))
+				cb := tree.LeafMerkleProofs[0].ToControlBlock(internalKey)
+				controlBlock, _ = cb.ToBytes()
+				rootHash := tree.RootNode.TapHash()
+				outputKey := txscript.ComputeTaprootOutputKey(internalKey, rootHash[:])
+				pkScript, _ = txscript.NewScriptBuilder().AddOp(txscript.OP_1).AddData(schnorr.SerializePubKey(outputKey)).Script()
+			}
+
+			builder, err := CreatePsbtBuilder(&chaincfg.SigNetParams,
+				[]Input{{OutTxId: "4db9ef8a51c06267fc1def09f21c79bc9f5ab3d3ba618edcfa18b5dc13340140", OutIndex: 1}},
+				[]Output{{Script: "0014" + strings.Repeat("11", 20), Amount: 90000}})
+			if err != nil {
+				t.Fatalf("CreatePsbtBuilder() error = %v", err)
+			}
+			builder.PsbtUpdater.Upsbt.UnsignedTx.TxIn[0].Sequence = tt.sequence
+
+			sighashType := txscript.SigHashAll
+			if tt.ctx == Tapscript {
+				sighashType = txscript.SigHashDefault
+			}
+			signIn := &InputSign{
+				UtxoType:            utxoType,
+				Index:               0,
+				PkScript:            hex.EncodeToString(pkScript),
+				ControlBlockWitness: hex.EncodeToString(controlBlock),
+				Amount:              100000,
+				SighashType:         sighashType,
+				Miniscript:          tt.expr,
+			}
+			if err = builder.UpdateMiniscriptInput([]*InputSign{signIn}); err != nil {
+				t.Fatalf("UpdateMiniscriptInput() error = %v", err)
+			}
+			for i, signer := range tt.signers {
+				// the miniscript travels in the psbt between signers
+				if i > 0 {
+					psbtHex, err := builder.ToString()
+					if err != nil {
+						t.Fatalf("ToString() error = %v", err)
+					}
+					if builder, err = NewPsbtBuilder(&chaincfg.SigNetParams, psbtHex); err != nil {
+						t.Fatalf("NewPsbtBuilder() error = %v", err)
+					}
+				}
+				signIn.PriHex = hex.EncodeToString(testPrivKey(signer).Serialize())
+				if err = builder.SignMiniscriptInput([]*InputSign{signIn}); err != nil {
+					t.Fatalf("SignMiniscriptInput() error = %v", err)
+				}
+			}
+			psbtHex, err := builder.ToString()
+			if err != nil {
+				t.Fatalf("ToString() error = %v", err)
+			}
+			if builder, err = NewPsbtBuilder(&chaincfg.SigNetParams, psbtHex); err != nil {
+				t.Fatalf("NewPsbtBuilder() error = %v", err)
+			}
+			if tt.preimage {
+				if err = builder.AddInPreimage("sha256", preimage, 0); err != nil {
+					t.Fatalf("AddInPreimage() error = %v", err)
+				}
+			}
+			estimate, err := builder.CalTxSize()
+			if err != nil {
+				t.Fatalf("CalTxSize() error = %v", err)
+			}
+
+			txHex, err := builder.ExtractPsbtTransaction()
+			if err != nil {
+				t.Fatalf("ExtractPsbtTransaction() error = %v", err)
+			}
+			txBytes, _ := hex.DecodeString(txHex)
+			tx := wire.NewMsgTx(2)
+			if err = tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+				t.Fatalf("Deserialize() error = %v", err)
+			}
+			prevOut := &wire.TxOut{Value: 100000, PkScript: pkScript}
+			fetcher := txscript.NewCannedPrevOutputFetcher(pkScript, 100000)
+			vm, err := txscript.NewEngine(pkScript, tx, 0, txscript.StandardVerifyFlags, nil,
+				txscript.NewTxSigHashes(tx, fetcher), prevOut.Value, fetcher)
+			if err != nil {
+				t.Fatalf("NewEngine() error = %v", err)
+			}
+			if err = vm.Execute(); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			weight := int64(tx.SerializeSizeStripped()*3 + tx.SerializeSize())
+			if vSize := (weight + 3) / 4; estimate < vSize {
+				t.Fatalf("CalTxSize() = %d, below actual vsize %d", estimate, vSize)
+			}
+		})
+	}
+}
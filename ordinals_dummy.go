@@ -0,0 +1,199 @@
+package psbt_sdk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// DummyUtxoAmount is the default amount of the dummy utxos of a split
+const DummyUtxoAmount btcutil.Amount = 600
+
+// MaxDummyUtxoAmount is the largest utxo taken as a dummy, bigger ones are
+// left to pay the purchase
+const MaxDummyUtxoAmount btcutil.Amount = 1000
+
+// FindDummyUtxos picks the count smallest utxos worth at most
+// MaxDummyUtxoAmount, it returns nil when the wallet has fewer. The utxos
+// must not carry inscriptions, a purchase spends the dummies to its output 0.
+func FindDummyUtxos(utxos []*Utxo, count int) ([]*Utxo, error) {
+	type candidate struct {
+		utxo   *Utxo
+		amount btcutil.Amount
+	}
+	candidates := make([]candidate, 0)
+	for _, u := range utxos {
+		amount, err := u.amount()
+		if err != nil {
+			return nil, err
+		}
+		if amount > 0 && amount <= MaxDummyUtxoAmount {
+			candidates = append(candidates, candidate{utxo: u, amount: amount})
+		}
+	}
+	if len(candidates) < count {
+		return nil, nil
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].amount < candidates[j].amount
+	})
+	dummies := make([]*Utxo, 0, count)
+	for _, c := range candidates[:count] {
+		dummies = append(dummies, c.utxo)
+	}
+	return dummies, nil
+}
+
+// amount returns the utxo amount, read from the previous tx of NonWitness
+// utxos without one
+func (u *Utxo) amount() (btcutil.Amount, error) {
+	if u.Amount != 0 || u.UtxoType != NonWitness {
+		return u.Amount, nil
+	}
+	raw, err := hex.DecodeString(u.OutRaw)
+	if err != nil {
+		return 0, err
+	}
+	tx := wire.NewMsgTx(2)
+	if err = tx.Deserialize(bytes.NewReader(raw)); err != nil {
+		return 0, err
+	}
+	if int(u.OutIndex) >= len(tx.TxOut) {
+		return 0, errors.New(fmt.Sprintf("previous tx of %s has no output %d", u.OutTxId, u.OutIndex))
+	}
+	return btcutil.Amount(tx.TxOut[u.OutIndex].Value), nil
+}
+
+// DummySplit pays Count dummy utxos of Amount to Address from Payments, the
+// rest goes back to ChangeAddress
+type DummySplit struct {
+	Payments      []*Utxo        `json:"payments"`
+	Address       string         `json:"address"`
+	Count         int            `json:"count"`  // PurchaseDummyInputs when zero
+	Amount        btcutil.Amount `json:"amount"` // DummyUtxoAmount when zero
+	ChangeAddress string         `json:"change_address"`
+	FeeRate       int64          `json:"fee_rate"` // sat/vB
+}
+
+func (d *DummySplit) count() int {
+	if d.Count == 0 {
+		return PurchaseDummyInputs
+	}
+	return d.Count
+}
+
+func (d *DummySplit) dummyAmount() btcutil.Amount {
+	if d.Amount == 0 {
+		return DummyUtxoAmount
+	}
+	return d.Amount
+}
+
+// CreateDummySplitPsbt builds the split tx of a DummySplit: the dummy
+// outputs first, then the change, dropped when it would be dust. The
+// payment inputs are left unsigned for the wallet.
+func CreateDummySplitPsbt(netParams *chaincfg.Params, split *DummySplit) (*PsbtBuilder, error) {
+	if split.count() < 0 {
+		return nil, errors.New(fmt.Sprintf("invalid dummy count %d", split.Count))
+	}
+	if split.dummyAmount() > MaxDummyUtxoAmount {
+		return nil, &AmountError{Input: -1, Output: 0, Amount: split.dummyAmount(),
+			Reason: fmt.Sprintf("dummy above %d", int64(MaxDummyUtxoAmount))}
+	}
+	if len(split.Payments) == 0 {
+		return nil, errors.New("split without payment inputs")
+	}
+	if split.FeeRate <= 0 {
+		return nil, errors.New(fmt.Sprintf("invalid fee rate %d", split.FeeRate))
+	}
+
+	ins := make([]Input, 0, len(split.Payments))
+	for _, payment := range split.Payments {
+		ins = append(ins, payment.Input)
+	}
+	outs := make([]Output, 0, split.count()+1)
+	for i := 0; i < split.count(); i++ {
+		outs = append(outs, Output{Address: split.Address, Amount: split.dummyAmount()})
+	}
+	outs = append(outs, Output{Address: split.ChangeAddress})
+
+	builder, err := CreatePsbtBuilder(netParams, ins, outs)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < split.count(); i++ {
+		if err = checkOutputDust(builder, i); err != nil {
+			return nil, err
+		}
+	}
+	for i, payment := range split.Payments {
+		if err = builder.addUtxo(payment, i); err != nil {
+			return nil, err
+		}
+	}
+	if err = builder.setChange(split.FeeRate); err != nil {
+		return nil, err
+	}
+	return builder, nil
+}
+
+// Utxos returns the dummies and the change of the signed split tx, ready for
+// Purchase.Dummies and Purchase.Payments. The change is nil when the split
+// dropped it.
+func (d *DummySplit) Utxos(split *PsbtBuilder) ([]*Utxo, *Utxo, error) {
+	split.mu.RLock()
+	defer split.mu.RUnlock()
+	if !split.PsbtUpdater.Upsbt.IsComplete() {
+		return nil, nil, errors.New("split psbt is not finalized")
+	}
+	// signature scripts are part of the txid, take it from the final tx
+	tx, err := extractTx(split.PsbtUpdater.Upsbt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tx.TxOut) < d.count() {
+		return nil, nil, errors.New(fmt.Sprintf("split has %d outputs, want %d dummies", len(tx.TxOut), d.count()))
+	}
+	var raw bytes.Buffer
+	if err = tx.Serialize(&raw); err != nil {
+		return nil, nil, err
+	}
+	txHash := tx.TxHash()
+	dummies := make([]*Utxo, 0, d.count())
+	for i := 0; i < d.count(); i++ {
+		dummies = append(dummies, outputUtxo(&txHash, uint32(i), tx.TxOut[i], raw.Bytes()))
+	}
+	var change *Utxo
+	if len(tx.TxOut) > d.count() {
+		change = outputUtxo(&txHash, uint32(d.count()), tx.TxOut[d.count()], raw.Bytes())
+	}
+	return dummies, change, nil
+}
+
+// outputUtxo describes output index of tx as a Utxo, with the previous tx
+// for scripts other than segwit
+func outputUtxo(txHash *chainhash.Hash, index uint32, txOut *wire.TxOut, raw []byte) *Utxo {
+	u := &Utxo{
+		Input:    Input{OutTxId: txHash.String(), OutIndex: index},
+		PkScript: hex.EncodeToString(txOut.PkScript),
+		Amount:   btcutil.Amount(txOut.Value),
+	}
+	switch {
+	case txscript.IsPayToTaproot(txOut.PkScript):
+		u.UtxoType = Taproot
+	case txscript.IsPayToWitnessPubKeyHash(txOut.PkScript), txscript.IsPayToWitnessScriptHash(txOut.PkScript):
+		u.UtxoType = Witness
+	default:
+		u.UtxoType = NonWitness
+		u.OutRaw = hex.EncodeToString(raw)
+	}
+	return u
+}
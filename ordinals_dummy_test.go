@@ -0,0 +1,75 @@
+package psbt_sdk
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+func TestFindDummyUtxos(t *testing.T) {
+	_, prevRaw := testPrevTx(t, 700, testP2wpkhScript(2))
+	utxos := []*Utxo{
+		{Input: Input{OutIndex: 0}, UtxoType: Taproot, PkScript: testP2trScript(3), Amount: 50000},
+		{Input: Input{OutIndex: 1}, UtxoType: Witness, PkScript: testP2wpkhScript(2), Amount: 900},
+		{Input: Input{OutIndex: 0}, UtxoType: NonWitness, OutRaw: prevRaw},
+		{Input: Input{OutIndex: 3}, UtxoType: Taproot, PkScript: testP2trScript(3), Amount: 1001},
+	}
+	dummies, err := FindDummyUtxos(utxos, 2)
+	if err != nil || len(dummies) != 2 || dummies[0] != utxos[2] || dummies[1] != utxos[1] {
+		t.Fatalf("FindDummyUtxos() = %v, %v, want the two smallest", dummies, err)
+	}
+	if dummies, err = FindDummyUtxos(utxos, 3); err != nil || dummies != nil {
+		t.Fatalf("FindDummyUtxos(3) = %v, %v, want nil", dummies, err)
+	}
+}
+
+func TestCreateDummySplitPsbt(t *testing.T) {
+	split := &DummySplit{
+		Payments: []*Utxo{{Input: Input{OutTxId: "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b", OutIndex: 5},
+			UtxoType: Taproot, PkScript: testP2trScript(3), Amount: 300000}},
+		Address:       testP2trAddress(t, 3),
+		ChangeAddress: testP2trAddress(t, 3),
+		FeeRate:       10,
+	}
+	builder, err := CreateDummySplitPsbt(&chaincfg.SigNetParams, split)
+	if err != nil {
+		t.Fatalf("CreateDummySplitPsbt() error = %v", err)
+	}
+	outputs := builder.GetOutputs()
+	if len(outputs) != 3 || outputs[0].Value != 600 || outputs[1].Value != 600 {
+		t.Fatalf("outputs = %v", outputs)
+	}
+	if _, _, err = split.Utxos(builder); err == nil {
+		t.Fatalf("Utxos() of an unsigned split error = nil")
+	}
+	if err = builder.UpdateAndSignInput([]*InputSign{{UtxoType: Taproot, PkScript: testP2trScript(3), Amount: 300000,
+		SighashType: txscript.SigHashDefault, PriHex: hex.EncodeToString(testPrivKey(3).Serialize())}}); err != nil {
+		t.Fatalf("UpdateAndSignInput() error = %v", err)
+	}
+	dummies, change, err := split.Utxos(builder)
+	if err != nil || len(dummies) != 2 || change == nil || change.Amount != btcutil.Amount(outputs[2].Value) ||
+		dummies[1].OutIndex != 1 || dummies[1].UtxoType != Taproot {
+		t.Fatalf("Utxos() = %v, %v, %v", dummies, change, err)
+	}
+
+	// the split feeds the purchase
+	purchase := testPurchase(t)
+	purchase.Dummies = dummies
+	purchase.Payments = []*Utxo{change}
+	builder, err = CreatePurchasePsbt(&chaincfg.SigNetParams, purchase)
+	if err != nil {
+		t.Fatalf("CreatePurchasePsbt() error = %v", err)
+	}
+	if outputs = builder.GetOutputs(); outputs[0].Value != 1200 {
+		t.Fatalf("outputs = %v, want the dummies merged into output 0", outputs)
+	}
+
+	split.Amount = 200
+	if _, err = CreateDummySplitPsbt(&chaincfg.SigNetParams, split); !errors.Is(err, ErrInvalidAmount) {
+		t.Fatalf("CreateDummySplitPsbt() of dust dummies error = %v, want ErrInvalidAmount", err)
+	}
+}
@@ -0,0 +1,264 @@
+package psbt_sdk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Envelope is an ordinals envelope found in the tapscript of an input, the
+// Offset-th one of the input. The flags mark envelopes ord treats as
+// cursed or partly ignores.
+type Envelope struct {
+	Input       int          `json:"input"`
+	Offset      int          `json:"offset"`
+	Inscription *Inscription `json:"inscription"`
+	// DuplicateField is set when a single value tag appears twice, the
+	// first value is kept
+	DuplicateField bool `json:"duplicate_field"`
+	// IncompleteField is set when the last tag before the body has no value
+	IncompleteField bool `json:"incomplete_field"`
+	// UnrecognizedEvenField is set for unknown even tags, which make the
+	// inscription unbound
+	UnrecognizedEvenField bool `json:"unrecognized_even_field"`
+	// Pushnum is set when the envelope pushes with OP_1..OP_16 or
+	// OP_1NEGATE instead of data pushes
+	Pushnum bool `json:"pushnum"`
+}
+
+// DecodeTxEnvelopes parses the envelopes of the script path spends of a raw
+// transaction
+func DecodeTxEnvelopes(txHex string) ([]*Envelope, error) {
+	raw, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, err
+	}
+	tx := wire.NewMsgTx(2)
+	if err = tx.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, err
+	}
+	envelopes := make([]*Envelope, 0)
+	for i, txIn := range tx.TxIn {
+		envelopes = append(envelopes, decodeScriptEnvelopes(i, witnessTapscript(txIn.Witness))...)
+	}
+	return envelopes, nil
+}
+
+// DecodeEnvelopes parses the envelopes of the psbt inputs, from the
+// FinalScriptWitness of finalized inputs and the TaprootLeafScript of the
+// others
+func (s *PsbtBuilder) DecodeEnvelopes() ([]*Envelope, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	envelopes := make([]*Envelope, 0)
+	for i := range s.PsbtUpdater.Upsbt.Inputs {
+		pIn := &s.PsbtUpdater.Upsbt.Inputs[i]
+		if pIn.FinalScriptWitness != nil {
+			witness, err := parseTxWitness(pIn.FinalScriptWitness)
+			if err != nil {
+				return nil, s.inputError(i, err)
+			}
+			envelopes = append(envelopes, decodeScriptEnvelopes(i, witnessTapscript(witness))...)
+			continue
+		}
+		for _, leaf := range pIn.TaprootLeafScript {
+			envelopes = append(envelopes, decodeScriptEnvelopes(i, leaf.Script)...)
+		}
+	}
+	return envelopes, nil
+}
+
+// witnessTapscript returns the script of a script path witness, the item
+// before the control block once the annex is dropped
+func witnessTapscript(witness wire.TxWitness) []byte {
+	if len(witness) >= 2 && len(witness[len(witness)-1]) > 0 && witness[len(witness)-1][0] == txscript.TaprootAnnexTag {
+		witness = witness[:len(witness)-1]
+	}
+	if len(witness) < 2 {
+		return nil
+	}
+	return witness[len(witness)-2]
+}
+
+// decodeScriptEnvelopes finds every OP_FALSE OP_IF "ord" ... OP_ENDIF in a
+// script, envelopes holding other opcodes are skipped
+func decodeScriptEnvelopes(input int, script []byte) []*Envelope {
+	type instruction struct {
+		opcode byte
+		data   []byte
+	}
+	instructions := make([]instruction, 0)
+	// a script that fails to parse still yields the envelopes before the
+	// failure
+	tokenizer := txscript.MakeScriptTokenizer(0, script)
+	for tokenizer.Next() {
+		instructions = append(instructions, instruction{opcode: tokenizer.Opcode(), data: tokenizer.Data()})
+	}
+
+	envelopes := make([]*Envelope, 0)
+	for i := 0; i+2 < len(instructions); i++ {
+		if instructions[i].opcode != txscript.OP_FALSE || instructions[i+1].opcode != txscript.OP_IF ||
+			!bytes.Equal(instructions[i+2].data, []byte(InscriptionProtocolId)) {
+			continue
+		}
+		var (
+			pushes  [][]byte
+			pushnum bool
+			end     = -1
+		)
+		for j := i + 3; j < len(instructions); j++ {
+			if instructions[j].opcode == txscript.OP_ENDIF {
+				end = j
+				break
+			}
+			data, num, ok := envelopePush(instructions[j].opcode, instructions[j].data)
+			if !ok {
+				break
+			}
+			pushnum = pushnum || num
+			pushes = append(pushes, data)
+		}
+		if end < 0 {
+			continue
+		}
+		envelope := decodeEnvelopePayload(pushes)
+		envelope.Input, envelope.Offset, envelope.Pushnum = input, len(envelopes), pushnum
+		envelopes = append(envelopes, envelope)
+		i = end
+	}
+	return envelopes
+}
+
+// envelopePush returns the data of a push opcode, num is set for the small
+// integer opcodes
+func envelopePush(opcode byte, data []byte) ([]byte, bool, bool) {
+	switch {
+	case opcode <= txscript.OP_PUSHDATA4:
+		if data == nil {
+			data = []byte{}
+		}
+		return data, false, true
+	case opcode == txscript.OP_1NEGATE:
+		return []byte{0x81}, true, true
+	case opcode >= txscript.OP_1 && opcode <= txscript.OP_16:
+		return []byte{opcode - txscript.OP_1 + 1}, true, true
+	}
+	return nil, false, false
+}
+
+// decodeEnvelopePayload reads the tag value pairs up to the body tag and
+// concatenates the body pushes after it
+func decodeEnvelopePayload(pushes [][]byte) *Envelope {
+	var (
+		envelope = &Envelope{Inscription: &Inscription{}}
+		fields   = make(map[byte][][]byte)
+		order    = make([]byte, 0)
+	)
+	for i := 0; i < len(pushes); i += 2 {
+		if len(pushes[i]) == 0 {
+			body := make([]byte, 0)
+			for _, push := range pushes[i+1:] {
+				body = append(body, push...)
+			}
+			envelope.Inscription.Body = body
+			break
+		}
+		if i+1 >= len(pushes) {
+			envelope.IncompleteField = true
+			break
+		}
+		// multi byte tags are never recognized
+		tag := pushes[i][0]
+		if len(pushes[i]) > 1 {
+			if pushes[i][0]%2 == 0 {
+				envelope.UnrecognizedEvenField = true
+			}
+			continue
+		}
+		if _, ok := fields[tag]; !ok {
+			order = append(order, tag)
+		}
+		fields[tag] = append(fields[tag], pushes[i+1])
+	}
+
+	single := func(tag byte) []byte {
+		values := fields[tag]
+		delete(fields, tag)
+		if len(values) == 0 {
+			return nil
+		}
+		if len(values) > 1 {
+			envelope.DuplicateField = true
+		}
+		return values[0]
+	}
+	inscription := envelope.Inscription
+	if v := single(tagContentType); v != nil {
+		inscription.ContentType = string(v)
+	}
+	if v := single(tagContentEncoding); v != nil {
+		inscription.ContentEncoding = string(v)
+	}
+	if v := single(tagMetaprotocol); v != nil {
+		inscription.Metaprotocol = string(v)
+	}
+	if v := single(tagDelegate); v != nil {
+		inscription.Delegate, _ = decodeInscriptionId(v)
+	}
+	if v := single(tagPointer); v != nil {
+		inscription.Pointer = decodePointer(v)
+	}
+	if v := single(tagRune); v != nil && len(v) <= 16 {
+		inscription.Rune = RuneName(decodeRuneCommitment(v))
+	}
+	for _, v := range fields[tagParent] {
+		if id, ok := decodeInscriptionId(v); ok {
+			inscription.Parents = append(inscription.Parents, id)
+		}
+	}
+	delete(fields, tagParent)
+	for _, v := range fields[tagMetadata] {
+		inscription.Metadata = append(inscription.Metadata, v...)
+	}
+	delete(fields, tagMetadata)
+	for _, tag := range order {
+		if _, ok := fields[tag]; ok && tag%2 == 0 {
+			envelope.UnrecognizedEvenField = true
+		}
+	}
+	return envelope
+}
+
+// decodeInscriptionId reverses encodeInscriptionId, it rejects values ord
+// ignores
+func decodeInscriptionId(v []byte) (string, bool) {
+	if len(v) < chainhash.HashSize || len(v) > chainhash.HashSize+4 {
+		return "", false
+	}
+	indexBytes := make([]byte, 4)
+	copy(indexBytes, v[chainhash.HashSize:])
+	if len(v) > chainhash.HashSize && v[len(v)-1] == 0 {
+		return "", false
+	}
+	txHash, _ := chainhash.NewHash(v[:chainhash.HashSize])
+	return fmt.Sprintf("%si%d", txHash.String(), binary.LittleEndian.Uint32(indexBytes)), true
+}
+
+// decodePointer reads a little endian pointer, bytes past the eighth must be
+// zero
+func decodePointer(v []byte) *uint64 {
+	for i := 8; i < len(v); i++ {
+		if v[i] != 0 {
+			return nil
+		}
+	}
+	pointerBytes := make([]byte, 8)
+	copy(pointerBytes, v)
+	pointer := binary.LittleEndian.Uint64(pointerBytes)
+	return &pointer
+}
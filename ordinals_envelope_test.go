@@ -0,0 +1,108 @@
+package psbt_sdk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+func TestDecodeEnvelopes(t *testing.T) {
+	pointer := uint64(DefaultPostage)
+	child := &Inscription{
+		ContentType:     "text/html;charset=utf-8",
+		ContentEncoding: "br",
+		Body:            bytes.Repeat([]byte{0x42}, 1500),
+		Metaprotocol:    "collection",
+		Parents:         []string{"93b3484083c31769c6a82b9c1cb66979463d86b3edea5044dede1ffdb74c3db2i0"},
+		Delegate:        "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33bi300",
+		Metadata:        bytes.Repeat([]byte{0xa1}, 600),
+		Pointer:         &pointer,
+	}
+	inscribe := &Inscribe{
+		Inscription: &Inscription{ContentType: "text/plain", Body: []byte("first")},
+		Destination: testP2trAddress(t, 3),
+		Batch:       []*BatchInscription{{Inscription: child, Destination: testP2trAddress(t, 3)}},
+		RevealKey:   hex.EncodeToString(testPrivKey(4).Serialize()),
+		FeeRate:     3,
+	}
+	want := []*Envelope{
+		{Input: 0, Offset: 0, Inscription: &Inscription{ContentType: "text/plain", Body: []byte("first")}},
+		{Input: 0, Offset: 1, Inscription: child},
+	}
+
+	// the unsigned reveal is read from its leaf script
+	_, skeleton, err := inscribe.commit(&chaincfg.SigNetParams)
+	if err != nil {
+		t.Fatalf("commit() error = %v", err)
+	}
+	envelopes, err := skeleton.DecodeEnvelopes()
+	if err != nil || !reflect.DeepEqual(envelopes, want) {
+		t.Fatalf("DecodeEnvelopes() = %+v, %v", envelopes, err)
+	}
+
+	reveal, err := CreateInscriptionRevealPsbt(&chaincfg.SigNetParams, inscribe,
+		"4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b")
+	if err != nil {
+		t.Fatalf("CreateInscriptionRevealPsbt() error = %v", err)
+	}
+	if envelopes, err = reveal.DecodeEnvelopes(); err != nil || !reflect.DeepEqual(envelopes, want) {
+		t.Fatalf("DecodeEnvelopes() of the signed reveal = %+v, %v", envelopes, err)
+	}
+	revealHex, err := reveal.ExtractPsbtTransaction()
+	if err != nil {
+		t.Fatalf("ExtractPsbtTransaction() error = %v", err)
+	}
+	if envelopes, err = DecodeTxEnvelopes(revealHex); err != nil || !reflect.DeepEqual(envelopes, want) {
+		t.Fatalf("DecodeTxEnvelopes() = %+v, %v", envelopes, err)
+	}
+}
+
+func TestDecodeEnvelopes_Flags(t *testing.T) {
+	for name, test := range map[string]struct {
+		script string
+		want   *Envelope
+	}{
+		"duplicate": {
+			script: "0063036f7264" + "0101" + "0161" + "0101" + "0162" + "68",
+			want:   &Envelope{Inscription: &Inscription{ContentType: "a"}, DuplicateField: true},
+		},
+		"incomplete": {
+			script: "0063036f7264" + "0101" + "68",
+			want:   &Envelope{Inscription: &Inscription{}, IncompleteField: true},
+		},
+		"unrecognized even": {
+			script: "0063036f7264" + "0104" + "0161" + "0105" + "0162" + "00" + "0163" + "68",
+			want: &Envelope{Inscription: &Inscription{Metadata: []byte("b"), Body: []byte("c")},
+				UnrecognizedEvenField: true},
+		},
+		"pushnum": {
+			script: "0063036f7264" + "51" + "0161" + "00" + "52" + "68",
+			want:   &Envelope{Inscription: &Inscription{ContentType: "a", Body: []byte{2}}, Pushnum: true},
+		},
+	} {
+		envelopes := decodeScriptEnvelopes(0, mustDecodeHex(t, test.script))
+		if len(envelopes) != 1 || !reflect.DeepEqual(envelopes[0], test.want) {
+			t.Fatalf("%s: decodeScriptEnvelopes() = %+v, want %+v", name, envelopes, test.want)
+		}
+	}
+
+	// an envelope with other opcodes is not an inscription, the next one is
+	script := "0063036f7264" + "0101" + "0161" + "75" + "68" + "0063036f7264" + "00" + "0163" + "68"
+	envelopes := decodeScriptEnvelopes(2, mustDecodeHex(t, script))
+	if len(envelopes) != 1 || envelopes[0].Input != 2 || envelopes[0].Offset != 0 ||
+		!bytes.Equal(envelopes[0].Inscription.Body, []byte("c")) {
+		t.Fatalf("decodeScriptEnvelopes() = %+v", envelopes)
+	}
+
+	// a key path spend has no tapscript
+	if script := witnessTapscript([][]byte{make([]byte, 64)}); script != nil {
+		t.Fatalf("witnessTapscript() = %x, want nil", script)
+	}
+	if script := witnessTapscript([][]byte{{1}, {2}, {3}, {txscript.TaprootAnnexTag}}); !bytes.Equal(script, []byte{2}) {
+		t.Fatalf("witnessTapscript() with annex = %x, want 02", script)
+	}
+}
@@ -0,0 +1,350 @@
+package psbt_sdk
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// DefaultPostage is the amount of the inscription output when Postage is zero
+const DefaultPostage btcutil.Amount = 10000
+
+// Inscribe inscribes Inscription to Destination, followed by the Batch
+// inscriptions in the same reveal. Payments fund the commit output, which
+// pays the postage of every inscription and the reveal fee, RevealKey signs
+// the reveal script path. With a Parent every inscription is its child.
+// With an Etching the reveal also etches the rune, committed to by the first
+// inscription or alone in the script, and pays the premine to Destination.
+type Inscribe struct {
+	Inscription   *Inscription        `json:"inscription"`
+	RevealKey     string              `json:"reveal_key"` // private key hex
+	Destination   string              `json:"destination"`
+	Batch         []*BatchInscription `json:"batch"`
+	Parent        *ParentInscription  `json:"parent"`
+	Etching       *Etching            `json:"etching"`
+	Postage       btcutil.Amount      `json:"postage"` // DefaultPostage when zero
+	Payments      []*Utxo             `json:"payments"`
+	ChangeAddress string              `json:"change_address"`
+	FeeRate       int64               `json:"fee_rate"` // sat/vB
+}
+
+// BatchInscription is an inscription of a batch reveal and its destination
+type BatchInscription struct {
+	Inscription *Inscription `json:"inscription"`
+	Destination string       `json:"destination"`
+}
+
+// ParentInscription is the parent of the inscriptions of a reveal. The reveal
+// spends Utxo, the utxo holding the parent at its first sat, before the
+// commit output and returns it to Owner in output 0.
+type ParentInscription struct {
+	Id    string `json:"id"`
+	Utxo  *Utxo  `json:"utxo"`
+	Owner string `json:"owner"`
+}
+
+// InscriptionCommit is the taproot output committing to the reveal script,
+// its Amount pays the postage and the reveal fee
+type InscriptionCommit struct {
+	Script       []byte         `json:"script"`
+	ControlBlock []byte         `json:"control_block"`
+	PkScript     []byte         `json:"pk_script"`
+	Address      string         `json:"address"`
+	Amount       btcutil.Amount `json:"amount"`
+	RevealFee    btcutil.Amount `json:"reveal_fee"`
+}
+
+func (in *Inscribe) postage() btcutil.Amount {
+	if in.Postage == 0 {
+		return DefaultPostage
+	}
+	return in.Postage
+}
+
+// items lists the inscriptions of the reveal in order. They carry the parent
+// tag of a Parent, and each one after the first a pointer to its own postage
+// output, which follow the parent output.
+func (in *Inscribe) items() ([]*BatchInscription, error) {
+	items := make([]*BatchInscription, 0, 1+len(in.Batch))
+	if in.Inscription != nil {
+		items = append(items, &BatchInscription{Inscription: in.Inscription, Destination: in.Destination})
+	}
+	items = append(items, in.Batch...)
+	if len(items) == 0 && in.Etching == nil {
+		return nil, errors.New("inscribe without inscription")
+	}
+	var offset uint64
+	if in.Parent != nil {
+		if in.Parent.Utxo == nil {
+			return nil, errors.New(fmt.Sprintf("parent %s without utxo", in.Parent.Id))
+		}
+		if _, _, err := parseInscriptionId(in.Parent.Id); err != nil {
+			return nil, err
+		}
+		amount, err := in.Parent.Utxo.amount()
+		if err != nil {
+			return nil, err
+		}
+		offset = uint64(amount)
+	}
+	for i, item := range items {
+		if item == nil || item.Inscription == nil {
+			return nil, errors.New(fmt.Sprintf("batch inscription %d is empty", i))
+		}
+		inscription := *item.Inscription
+		if in.Parent != nil && !containsString(inscription.Parents, in.Parent.Id) {
+			inscription.Parents = append(append([]string{}, inscription.Parents...), in.Parent.Id)
+		}
+		if i > 0 {
+			pointer := offset + uint64(i)*uint64(in.postage())
+			inscription.Pointer = &pointer
+		} else if in.Etching != nil {
+			inscription.Rune = in.Etching.Rune
+		}
+		items[i] = &BatchInscription{Inscription: &inscription, Destination: item.Destination}
+	}
+	return items, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// revealIns are the parent utxo and the commit output at a placeholder
+// outpoint
+func (in *Inscribe) revealIns() []Input {
+	ins := make([]Input, 0, 2)
+	if in.Parent != nil {
+		ins = append(ins, in.Parent.Utxo.Input)
+	}
+	return append(ins, Input{OutTxId: OccupiedTxId, OutIndex: OccupiedTxIndex})
+}
+
+// revealOutputs are the parent output, the postage outputs of the
+// inscriptions and the etching outputs
+func (in *Inscribe) revealOutputs(items []*BatchInscription) ([]Output, error) {
+	outs := make([]Output, 0, 1+len(items))
+	if in.Parent != nil {
+		amount, err := in.Parent.Utxo.amount()
+		if err != nil {
+			return nil, err
+		}
+		outs = append(outs, Output{Address: in.Parent.Owner, Amount: amount})
+	}
+	for _, item := range items {
+		outs = append(outs, Output{Address: item.Destination, Amount: in.postage()})
+	}
+	if in.Etching != nil {
+		etchingOuts, err := in.etchingOutputs(len(outs))
+		if err != nil {
+			return nil, err
+		}
+		outs = append(outs, etchingOuts...)
+	}
+	return outs, nil
+}
+
+func (in *Inscribe) revealKey() (*btcec.PrivateKey, error) {
+	privateKeyBytes, err := hex.DecodeString(in.RevealKey)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, _ := btcec.PrivKeyFromBytes(privateKeyBytes)
+	return privateKey, nil
+}
+
+// Commit derives the commit output of the reveal key and the inscriptions,
+// a single leaf tree under the reveal key
+func (in *Inscribe) Commit(netParams *chaincfg.Params) (*InscriptionCommit, error) {
+	commit, _, err := in.commit(netParams)
+	return commit, err
+}
+
+// commit returns the commit output and the reveal skeleton spending it from
+// a placeholder outpoint, the commit input is the last one
+func (in *Inscribe) commit(netParams *chaincfg.Params) (*InscriptionCommit, *PsbtBuilder, error) {
+	items, err := in.items()
+	if err != nil {
+		return nil, nil, err
+	}
+	if in.FeeRate <= 0 {
+		return nil, nil, errors.New(fmt.Sprintf("invalid fee rate %d", in.FeeRate))
+	}
+	if in.Etching != nil {
+		if err = in.checkEtching(); err != nil {
+			return nil, nil, err
+		}
+	}
+	privateKey, err := in.revealKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	inscriptions := make([]*Inscription, 0, len(items))
+	for _, item := range items {
+		inscriptions = append(inscriptions, item.Inscription)
+	}
+	var script []byte
+	if len(inscriptions) > 0 {
+		script, err = InscriptionScript(privateKey.PubKey(), inscriptions...)
+	} else {
+		script, err = RuneCommitScript(privateKey.PubKey(), in.Etching.Rune)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	commit, err := newInscriptionCommit(netParams, privateKey.PubKey(), script)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	outs, err := in.revealOutputs(items)
+	if err != nil {
+		return nil, nil, err
+	}
+	reveal, err := CreatePsbtBuilder(netParams, in.revealIns(), outs)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range outs {
+		if err = checkOutputDust(reveal, i); err != nil {
+			return nil, nil, err
+		}
+	}
+	firstOut := 0
+	if in.Parent != nil {
+		if err = reveal.addUtxo(in.Parent.Utxo, 0); err != nil {
+			return nil, nil, err
+		}
+		firstOut = 1
+	}
+	// the commit pays every output but the parent one
+	var postage btcutil.Amount
+	for i, out := range outs[firstOut:] {
+		if postage, err = addAmount(postage, out.Amount); err != nil {
+			return nil, nil, &AmountError{Input: -1, Output: firstOut + i, Amount: out.Amount, Reason: err.Error()}
+		}
+	}
+
+	// the reveal input is sized from its leaf script, the parent input from
+	// its utxo type
+	revealIndex := len(reveal.PsbtUpdater.Upsbt.Inputs) - 1
+	if in.Etching != nil {
+		reveal.PsbtUpdater.Upsbt.UnsignedTx.TxIn[revealIndex].Sequence = RuneCommitConfirmations - 1
+	}
+	revealIn := &reveal.PsbtUpdater.Upsbt.Inputs[revealIndex]
+	revealIn.WitnessUtxo = wire.NewTxOut(0, commit.PkScript)
+	revealIn.TaprootLeafScript = []*psbt.TaprootTapLeafScript{{ControlBlock: commit.ControlBlock, Script: script,
+		LeafVersion: txscript.BaseLeafVersion}}
+	vSize, err := reveal.calTxSize()
+	if err != nil {
+		return nil, nil, err
+	}
+	commit.RevealFee = btcutil.Amount(vSize * in.FeeRate)
+	if commit.Amount, err = addAmount(postage, commit.RevealFee); err != nil {
+		return nil, nil, &AmountError{Input: -1, Output: 0, Amount: commit.RevealFee, Reason: err.Error()}
+	}
+	revealIn.WitnessUtxo.Value = int64(commit.Amount)
+	return commit, reveal, nil
+}
+
+// newInscriptionCommit builds the taproot output of a single script leaf
+// under internalKey
+func newInscriptionCommit(netParams *chaincfg.Params, internalKey *btcec.PublicKey, script []byte) (*InscriptionCommit, error) {
+	tree := txscript.AssembleTaprootScriptTree(txscript.NewBaseTapLeaf(script))
+	rootHash := tree.RootNode.TapHash()
+	outputKey := txscript.ComputeTaprootOutputKey(internalKey, rootHash[:])
+	proof := tree.LeafMerkleProofs[0].ToControlBlock(internalKey)
+	controlBlock, err := proof.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	address, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(outputKey), netParams)
+	if err != nil {
+		return nil, err
+	}
+	pkScript, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return nil, err
+	}
+	return &InscriptionCommit{Script: script, ControlBlock: controlBlock, PkScript: pkScript,
+		Address: address.EncodeAddress()}, nil
+}
+
+// CreateInscriptionCommitPsbt builds the commit tx paying the commit output
+// at index 0 and the change, the payment inputs are left unsigned for the
+// wallet
+func CreateInscriptionCommitPsbt(netParams *chaincfg.Params, inscribe *Inscribe) (*PsbtBuilder, error) {
+	commit, err := inscribe.Commit(netParams)
+	if err != nil {
+		return nil, err
+	}
+	if len(inscribe.Payments) == 0 {
+		return nil, errors.New("inscribe without payment inputs")
+	}
+	ins := make([]Input, 0, len(inscribe.Payments))
+	for _, payment := range inscribe.Payments {
+		ins = append(ins, payment.Input)
+	}
+	outs := []Output{
+		{Script: hex.EncodeToString(commit.PkScript), Amount: commit.Amount},
+		{Address: inscribe.ChangeAddress},
+	}
+	builder, err := CreatePsbtBuilder(netParams, ins, outs)
+	if err != nil {
+		return nil, err
+	}
+	for i, payment := range inscribe.Payments {
+		if err = builder.addUtxo(payment, i); err != nil {
+			return nil, err
+		}
+	}
+	if err = builder.setChange(inscribe.FeeRate); err != nil {
+		return nil, err
+	}
+	return builder, nil
+}
+
+// CreateInscriptionRevealPsbt builds and signs the reveal tx spending output
+// 0 of the commit tx commitTxId through the inscription script path. The
+// inscriptions follow the parent output in order, the parent input is left
+// unsigned for its owner.
+func CreateInscriptionRevealPsbt(netParams *chaincfg.Params, inscribe *Inscribe, commitTxId string) (*PsbtBuilder, error) {
+	commit, builder, err := inscribe.commit(netParams)
+	if err != nil {
+		return nil, err
+	}
+	txHash, err := chainhash.NewHashFromStr(commitTxId)
+	if err != nil {
+		return nil, err
+	}
+	revealIndex := len(builder.PsbtUpdater.Upsbt.UnsignedTx.TxIn) - 1
+	builder.PsbtUpdater.Upsbt.UnsignedTx.TxIn[revealIndex].PreviousOutPoint = *wire.NewOutPoint(txHash, 0)
+	err = builder.UpdateAndSignTaprootInput([]*InputSign{{
+		UtxoType:            Taproot,
+		Index:               revealIndex,
+		PkScript:            hex.EncodeToString(commit.PkScript),
+		Amount:              commit.Amount,
+		RedeemScript:        hex.EncodeToString(commit.Script),
+		ControlBlockWitness: hex.EncodeToString(commit.ControlBlock),
+		SighashType:         txscript.SigHashDefault,
+		PriHex:              inscribe.RevealKey,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	return builder, nil
+}
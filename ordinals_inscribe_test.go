@@ -0,0 +1,237 @@
+package psbt_sdk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// txVSize is the virtual size of a tx with its witnesses
+func txVSize(tx *wire.MsgTx) int64 {
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx))
+	return (weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor
+}
+
+func TestInscription_Envelope(t *testing.T) {
+	pointer := uint64(300)
+	envelope, err := (&Inscription{
+		ContentType:  "text/plain;charset=utf-8",
+		Body:         []byte("hello"),
+		Metaprotocol: "brc-20",
+		Parents:      []string{"93b3484083c31769c6a82b9c1cb66979463d86b3edea5044dede1ffdb74c3db2i1"},
+		Pointer:      &pointer,
+	}).Envelope()
+	if err != nil {
+		t.Fatalf("Envelope() error = %v", err)
+	}
+	want := "0063036f7264" +
+		"0101" + "18" + hex.EncodeToString([]byte("text/plain;charset=utf-8")) +
+		"0107" + "06" + hex.EncodeToString([]byte("brc-20")) +
+		"0103" + "21" + "b23d4cb7fd1fdede4450eaedb3863d467969b61c9c2ba8c66917c3834048b393" + "01" +
+		"0102" + "02" + "2c01" +
+		"00" + "05" + hex.EncodeToString([]byte("hello")) + "68"
+	if hex.EncodeToString(envelope) != want {
+		t.Fatalf("Envelope() = %x, want %s", envelope, want)
+	}
+
+	// the body is split into 520 byte pushes
+	envelope, _ = (&Inscription{Body: bytes.Repeat([]byte{0xaa}, 1200)}).Envelope()
+	tokenizer := txscript.MakeScriptTokenizer(0, envelope)
+	var pushes []int
+	for tokenizer.Next() {
+		if len(tokenizer.Data()) > 3 {
+			pushes = append(pushes, len(tokenizer.Data()))
+		}
+	}
+	if tokenizer.Err() != nil || len(pushes) != 3 || pushes[0] != 520 || pushes[1] != 520 || pushes[2] != 160 {
+		t.Fatalf("body pushes = %v, %v", pushes, tokenizer.Err())
+	}
+
+	if _, err = (&Inscription{ContentType: strings.Repeat("a", 521)}).Envelope(); err == nil {
+		t.Fatalf("Envelope() of a 521 byte content type error = nil")
+	}
+	if _, err = (&Inscription{Delegate: "93b3484083c31769c6a82b9c1cb66979463d86b3edea5044dede1ffdb74c3db2"}).Envelope(); err == nil {
+		t.Fatalf("Envelope() of an invalid delegate id error = nil")
+	}
+}
+
+func TestCreateInscriptionRevealPsbt(t *testing.T) {
+	for _, size := range []int{5, 20000} {
+		inscribe := &Inscribe{
+			Inscription: &Inscription{ContentType: "text/plain", Body: bytes.Repeat([]byte("a"), size)},
+			RevealKey:   hex.EncodeToString(testPrivKey(4).Serialize()),
+			Destination: testP2trAddress(t, 3),
+			Payments: []*Utxo{{Input: Input{OutTxId: "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b", OutIndex: 5},
+				UtxoType: Taproot, PkScript: testP2trScript(3), Amount: 300000}},
+			ChangeAddress: testP2trAddress(t, 3),
+			FeeRate:       7,
+		}
+		commit, err := inscribe.Commit(&chaincfg.SigNetParams)
+		if err != nil {
+			t.Fatalf("Commit() error = %v", err)
+		}
+		commitBuilder, err := CreateInscriptionCommitPsbt(&chaincfg.SigNetParams, inscribe)
+		if err != nil {
+			t.Fatalf("CreateInscriptionCommitPsbt() error = %v", err)
+		}
+		outputs := commitBuilder.GetOutputs()
+		if outputs[0].Value != int64(commit.Amount) || !bytes.Equal(outputs[0].PkScript, commit.PkScript) {
+			t.Fatalf("commit output = %v, want %d to %s", outputs[0], commit.Amount, commit.Address)
+		}
+		if err = commitBuilder.UpdateAndSignInput([]*InputSign{{UtxoType: Taproot, PkScript: testP2trScript(3), Amount: 300000,
+			SighashType: txscript.SigHashDefault, PriHex: hex.EncodeToString(testPrivKey(3).Serialize())}}); err != nil {
+			t.Fatalf("UpdateAndSignInput() error = %v", err)
+		}
+		commitTx := wire.NewMsgTx(2)
+		commitHex, _ := commitBuilder.ExtractPsbtTransaction()
+		if err = commitTx.Deserialize(hex.NewDecoder(strings.NewReader(commitHex))); err != nil {
+			t.Fatalf("Deserialize() error = %v", err)
+		}
+
+		reveal, err := CreateInscriptionRevealPsbt(&chaincfg.SigNetParams, inscribe, commitTx.TxHash().String())
+		if err != nil {
+			t.Fatalf("CreateInscriptionRevealPsbt() error = %v", err)
+		}
+		if results, err := reveal.Verify(); err != nil || !results[0].Valid {
+			t.Fatalf("Verify() error = %v", err)
+		}
+		revealHex, err := reveal.ExtractPsbtTransaction()
+		if err != nil {
+			t.Fatalf("ExtractPsbtTransaction() error = %v", err)
+		}
+		revealTx := wire.NewMsgTx(2)
+		_ = revealTx.Deserialize(hex.NewDecoder(strings.NewReader(revealHex)))
+		if fee := btcutil.Amount(txVSize(revealTx) * 7); fee != commit.RevealFee || revealTx.TxOut[0].Value != int64(DefaultPostage) {
+			t.Fatalf("reveal fee = %d, want %d", fee, commit.RevealFee)
+		}
+		if witness := revealTx.TxIn[0].Witness; len(witness) != 3 || !bytes.Equal(witness[1], commit.Script) {
+			t.Fatalf("reveal witness = %x", witness)
+		}
+	}
+}
+
+func TestCreateInscriptionRevealPsbt_Batch(t *testing.T) {
+	inscribe := &Inscribe{
+		Inscription: &Inscription{ContentType: "image/png", Body: bytes.Repeat([]byte{1}, 3000)},
+		Destination: testP2trAddress(t, 3),
+		Batch: []*BatchInscription{
+			{Inscription: &Inscription{ContentType: "image/png", Body: bytes.Repeat([]byte{2}, 3000)}, Destination: testP2trAddress(t, 4)},
+			{Inscription: &Inscription{ContentType: "image/png", Body: bytes.Repeat([]byte{3}, 3000)}, Destination: testP2trAddress(t, 5)},
+		},
+		RevealKey: hex.EncodeToString(testPrivKey(4).Serialize()),
+		Postage:   546,
+		FeeRate:   12,
+	}
+	commit, skeleton, err := inscribe.commit(&chaincfg.SigNetParams)
+	if err != nil {
+		t.Fatalf("commit() error = %v", err)
+	}
+	// inscriptions after the first point at their own postage output
+	for i, pointer := range []string{"", "0102022202", "0102024404"} {
+		inscription := &Inscription{ContentType: "image/png", Body: bytes.Repeat([]byte{byte(i + 1)}, 3000)}
+		envelope, _ := inscription.Envelope()
+		if pointer != "" {
+			envelope = append(envelope[:6+2+1+len("image/png")], append(mustDecodeHex(t, pointer), envelope[6+2+1+len("image/png"):]...)...)
+		}
+		if !bytes.Contains(commit.Script, envelope) {
+			t.Fatalf("reveal script misses envelope %d %x", i, envelope)
+		}
+	}
+	if inscribe.Batch[0].Inscription.Pointer != nil {
+		t.Fatalf("commit() changed the batch inscriptions")
+	}
+
+	// CalTxSize sizes the unsigned script path spend exactly
+	if vSize, err := skeleton.CalTxSize(); err != nil || btcutil.Amount(vSize*12) != commit.RevealFee {
+		t.Fatalf("CalTxSize() = %d, %v, want the fee %d at 12 sat/vB", vSize, err, commit.RevealFee)
+	}
+
+	reveal, err := CreateInscriptionRevealPsbt(&chaincfg.SigNetParams, inscribe,
+		"4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b")
+	if err != nil {
+		t.Fatalf("CreateInscriptionRevealPsbt() error = %v", err)
+	}
+	outputs := reveal.GetOutputs()
+	if len(outputs) != 3 || outputs[2].Value != 546 || hex.EncodeToString(outputs[2].PkScript) != testP2trScript(5) {
+		t.Fatalf("reveal outputs = %v", outputs)
+	}
+	if fee, err := reveal.Fee(); err != nil || fee != commit.RevealFee || commit.Amount != 3*546+fee {
+		t.Fatalf("Fee() = %d, %v, want %d", fee, err, commit.RevealFee)
+	}
+	if vSize, err := reveal.CalTxSize(); err != nil || btcutil.Amount(vSize*12) != commit.RevealFee {
+		t.Fatalf("CalTxSize() of the signed reveal = %d, %v", vSize, err)
+	}
+}
+
+func TestCreateInscriptionRevealPsbt_Parent(t *testing.T) {
+	parentId := "93b3484083c31769c6a82b9c1cb66979463d86b3edea5044dede1ffdb74c3db2i0"
+	for name, parent := range map[string]*InputSign{
+		"taproot": {UtxoType: Taproot, PkScript: testP2trScript(5), Amount: 546, SighashType: txscript.SigHashDefault},
+		"segwit":  {UtxoType: Witness, PkScript: testP2wpkhScript(5), Amount: 546, SighashType: txscript.SigHashAll},
+	} {
+		inscribe := &Inscribe{
+			Inscription: &Inscription{ContentType: "text/plain", Body: []byte("child 0")},
+			Destination: testP2trAddress(t, 3),
+			Batch: []*BatchInscription{
+				{Inscription: &Inscription{ContentType: "text/plain", Body: []byte("child 1")}, Destination: testP2trAddress(t, 3)},
+			},
+			Parent: &ParentInscription{
+				Id: parentId,
+				Utxo: &Utxo{Input: Input{OutTxId: "93b3484083c31769c6a82b9c1cb66979463d86b3edea5044dede1ffdb74c3db2"},
+					UtxoType: parent.UtxoType, PkScript: parent.PkScript, Amount: 546},
+				Owner: testP2trAddress(t, 5),
+			},
+			RevealKey: hex.EncodeToString(testPrivKey(4).Serialize()),
+			FeeRate:   5,
+		}
+		commit, err := inscribe.Commit(&chaincfg.SigNetParams)
+		if err != nil {
+			t.Fatalf("%s: Commit() error = %v", name, err)
+		}
+		// both children carry the parent tag, the second points past the
+		// parent output and the first child
+		parentTag := "0103" + "20" + "b23d4cb7fd1fdede4450eaedb3863d467969b61c9c2ba8c66917c3834048b393"
+		if strings.Count(hex.EncodeToString(commit.Script), parentTag) != 2 ||
+			!strings.Contains(hex.EncodeToString(commit.Script), parentTag+"0102"+"02"+"3229") {
+			t.Fatalf("%s: reveal script = %x", name, commit.Script)
+		}
+
+		reveal, err := CreateInscriptionRevealPsbt(&chaincfg.SigNetParams, inscribe,
+			"4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b")
+		if err != nil {
+			t.Fatalf("%s: CreateInscriptionRevealPsbt() error = %v", name, err)
+		}
+		outputs := reveal.GetOutputs()
+		if len(outputs) != 3 || outputs[0].Value != 546 || hex.EncodeToString(outputs[0].PkScript) != testP2trScript(5) {
+			t.Fatalf("%s: reveal outputs = %v", name, outputs)
+		}
+		if reveal.IsComplete() || reveal.PsbtUpdater.Upsbt.Inputs[1].FinalScriptWitness == nil {
+			t.Fatalf("%s: want the commit input signed and the parent input unsigned", name)
+		}
+
+		// the owner signs the parent input through its own path
+		parent.PriHex = hex.EncodeToString(testPrivKey(5).Serialize())
+		if err = reveal.UpdateAndSignInput([]*InputSign{parent}); err != nil {
+			t.Fatalf("%s: UpdateAndSignInput() error = %v", name, err)
+		}
+		if results, err := reveal.Verify(); err != nil || !results[0].Valid || !results[1].Valid {
+			t.Fatalf("%s: Verify() error = %v", name, err)
+		}
+		revealHex, err := reveal.ExtractPsbtTransaction()
+		if err != nil {
+			t.Fatalf("%s: ExtractPsbtTransaction() error = %v", name, err)
+		}
+		revealTx := wire.NewMsgTx(2)
+		_ = revealTx.Deserialize(hex.NewDecoder(strings.NewReader(revealHex)))
+		if fee := btcutil.Amount(txVSize(revealTx) * 5); fee > commit.RevealFee || fee < commit.RevealFee-5 {
+			t.Fatalf("%s: reveal fee = %d, want %d", name, fee, commit.RevealFee)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package psbt_sdk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// InscriptionProtocolId is pushed after OP_FALSE OP_IF to mark an ordinals
+// envelope
+const InscriptionProtocolId = "ord"
+
+// MaxScriptElementSize is the largest push tapscript accepts, the body and
+// long tag values are split into pushes of this size
+const MaxScriptElementSize = txscript.MaxScriptElementSize
+
+// envelope tags, the body follows tagBody
+const (
+	tagBody            byte = 0
+	tagContentType     byte = 1
+	tagPointer         byte = 2
+	tagParent          byte = 3
+	tagMetadata        byte = 5
+	tagMetaprotocol    byte = 7
+	tagContentEncoding byte = 9
+	tagDelegate        byte = 11
+	tagRune            byte = 13
+)
+
+// Inscription is the content of an ordinals envelope. Parents and Delegate
+// are inscription ids, <txid>i<index>. Pointer is the sat offset in the
+// reveal outputs the inscription is made on, the first sat when nil.
+// Metadata is CBOR, split into 520 byte pushes like the body. Rune is the
+// name of a rune the reveal etches, pushed as its commitment.
+type Inscription struct {
+	ContentType     string   `json:"content_type"`
+	ContentEncoding string   `json:"content_encoding"`
+	Body            []byte   `json:"body"`
+	Metaprotocol    string   `json:"metaprotocol"`
+	Parents         []string `json:"parents"`
+	Delegate        string   `json:"delegate"`
+	Metadata        []byte   `json:"metadata"`
+	Pointer         *uint64  `json:"pointer"`
+	Rune            string   `json:"rune"`
+}
+
+// Envelope serializes the inscription into OP_FALSE OP_IF "ord" <tag>
+// <value>... OP_0 <body>... OP_ENDIF
+func (i *Inscription) Envelope() ([]byte, error) {
+	script := []byte{txscript.OP_FALSE, txscript.OP_IF}
+	script = appendPush(script, []byte(InscriptionProtocolId))
+
+	var err error
+	if i.ContentType != "" {
+		if script, err = appendTag(script, tagContentType, []byte(i.ContentType)); err != nil {
+			return nil, err
+		}
+	}
+	if i.ContentEncoding != "" {
+		if script, err = appendTag(script, tagContentEncoding, []byte(i.ContentEncoding)); err != nil {
+			return nil, err
+		}
+	}
+	if i.Metaprotocol != "" {
+		if script, err = appendTag(script, tagMetaprotocol, []byte(i.Metaprotocol)); err != nil {
+			return nil, err
+		}
+	}
+	for _, parent := range i.Parents {
+		id, err := encodeInscriptionId(parent)
+		if err != nil {
+			return nil, err
+		}
+		script, _ = appendTag(script, tagParent, id)
+	}
+	if i.Delegate != "" {
+		id, err := encodeInscriptionId(i.Delegate)
+		if err != nil {
+			return nil, err
+		}
+		script, _ = appendTag(script, tagDelegate, id)
+	}
+	for _, chunk := range splitPushes(i.Metadata) {
+		script, _ = appendTag(script, tagMetadata, chunk)
+	}
+	if i.Pointer != nil {
+		script, _ = appendTag(script, tagPointer, trimLittleEndian(*i.Pointer))
+	}
+	if i.Rune != "" {
+		commitment, err := RuneCommitment(i.Rune)
+		if err != nil {
+			return nil, err
+		}
+		script, _ = appendTag(script, tagRune, commitment)
+	}
+	if i.Body != nil {
+		script = appendPush(script, []byte{})
+		for _, chunk := range splitPushes(i.Body) {
+			script = appendPush(script, chunk)
+		}
+	}
+	return append(script, txscript.OP_ENDIF), nil
+}
+
+// splitPushes splits data into pushes of MaxScriptElementSize
+func splitPushes(data []byte) [][]byte {
+	chunks := make([][]byte, 0, (len(data)+MaxScriptElementSize-1)/MaxScriptElementSize)
+	for len(data) > 0 {
+		n := len(data)
+		if n > MaxScriptElementSize {
+			n = MaxScriptElementSize
+		}
+		chunks = append(chunks, data[:n])
+		data = data[n:]
+	}
+	return chunks
+}
+
+// InscriptionScript is the tapscript revealing inscriptions, a checksig of
+// the reveal key followed by an envelope per inscription. The reveal numbers
+// them <revealtxid>i0, i1... in this order.
+func InscriptionScript(revealKey *btcec.PublicKey, inscriptions ...*Inscription) ([]byte, error) {
+	if len(inscriptions) == 0 {
+		return nil, errors.New("inscription script without inscriptions")
+	}
+	script := appendPush(nil, schnorr.SerializePubKey(revealKey))
+	script = append(script, txscript.OP_CHECKSIG)
+	for _, inscription := range inscriptions {
+		envelope, err := inscription.Envelope()
+		if err != nil {
+			return nil, err
+		}
+		script = append(script, envelope...)
+	}
+	return script, nil
+}
+
+// appendTag pushes a tag and its value, values can't be split
+func appendTag(script []byte, tag byte, value []byte) ([]byte, error) {
+	if len(value) > MaxScriptElementSize {
+		return nil, errors.New(fmt.Sprintf("inscription tag %d of %d bytes, limit %d", tag, len(value), MaxScriptElementSize))
+	}
+	script = appendPush(script, []byte{tag})
+	return appendPush(script, value), nil
+}
+
+// appendPush appends a data push. Unlike txscript.ScriptBuilder it keeps one
+// byte values as data pushes, as ord writes them, and has no script size
+// limit, which tapscript lifts.
+func appendPush(script []byte, data []byte) []byte {
+	switch n := len(data); {
+	case n == 0:
+		script = append(script, txscript.OP_0)
+	case n < txscript.OP_PUSHDATA1:
+		script = append(script, byte(n))
+	case n <= 0xff:
+		script = append(script, txscript.OP_PUSHDATA1, byte(n))
+	default:
+		script = append(script, txscript.OP_PUSHDATA2, byte(n), byte(n>>8))
+	}
+	return append(script, data...)
+}
+
+// encodeInscriptionId encodes <txid>i<index> as the txid in tx byte order
+// followed by the little endian index without trailing zeros
+func encodeInscriptionId(id string) ([]byte, error) {
+	txHash, index, err := parseInscriptionId(id)
+	if err != nil {
+		return nil, err
+	}
+	return append(txHash.CloneBytes(), trimLittleEndian(uint64(index))...), nil
+}
+
+func parseInscriptionId(id string) (*chainhash.Hash, uint32, error) {
+	sep := strings.LastIndexByte(id, 'i')
+	if sep < 0 {
+		return nil, 0, errors.New(fmt.Sprintf("invalid inscription id %s", id))
+	}
+	txHash, err := chainhash.NewHashFromStr(id[:sep])
+	if err != nil {
+		return nil, 0, errors.New(fmt.Sprintf("invalid inscription id %s: %v", id, err))
+	}
+	index, err := strconv.ParseUint(id[sep+1:], 10, 32)
+	if err != nil {
+		return nil, 0, errors.New(fmt.Sprintf("invalid inscription id %s: %v", id, err))
+	}
+	return txHash, uint32(index), nil
+}
+
+// trimLittleEndian encodes v little endian without trailing zero bytes,
+// zero is empty
+func trimLittleEndian(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, v)
+	n := len(b)
+	for n > 0 && b[n-1] == 0 {
+		n--
+	}
+	return b[:n]
+}
@@ -0,0 +1,108 @@
+package psbt_sdk
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// ListingSighashType is the sighash of a seller input, it commits to the
+// inscription input and the payout output at the same index only
+const ListingSighashType = txscript.SigHashSingle | txscript.SigHashAnyOneCanPay
+
+// Listing offers an inscription for sale. Seller describes the inscription
+// utxo and the seller key as for UpdateAndSignInput, its Index and
+// SighashType are set by the listing.
+type Listing struct {
+	Inscription   Input          `json:"inscription"`
+	Seller        *InputSign     `json:"seller"`
+	Price         btcutil.Amount `json:"price"`
+	PayoutAddress string         `json:"payout_address"`
+	// Fees are royalty and platform outputs following the payout. The seller
+	// signature does not commit to them, the purchase keeps them.
+	Fees []Output `json:"fees"`
+}
+
+// CreateListingPsbt builds the listing psbt of an inscription: the signed
+// and finalized seller input paired with the payout output, followed by the
+// fee outputs. The seller input is at index 0, a legacy one follows
+// PurchaseDummyInputs placeholder inputs and outputs: its sighash commits to
+// its index and the number of outputs before the payout, so it is signed
+// where the purchase puts it.
+func CreateListingPsbt(netParams *chaincfg.Params, listing *Listing) (*PsbtBuilder, error) {
+	if listing.Seller == nil {
+		return nil, errors.New("listing without seller input")
+	}
+	var (
+		sellerIndex int
+		ins         []Input
+		outs        []Output
+	)
+	if listing.Seller.UtxoType == NonWitness {
+		sellerIndex = PurchaseDummyInputs
+	}
+	for i := 0; i < sellerIndex; i++ {
+		ins = append(ins, Input{OutTxId: OccupiedTxId, OutIndex: OccupiedTxIndex + uint32(i)})
+		outs = append(outs, Output{Script: listingPlaceholderScript})
+	}
+	ins = append(ins, listing.Inscription)
+	outs = append(outs, Output{Address: listing.PayoutAddress, Amount: listing.Price})
+	outs = append(outs, listing.Fees...)
+	builder, err := CreatePsbtBuilder(netParams, ins, outs)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkOutputDust(builder, sellerIndex); err != nil {
+		return nil, err
+	}
+
+	seller := *listing.Seller
+	seller.Index = sellerIndex
+	seller.OutPoint = ""
+	seller.SighashType = ListingSighashType
+	if err = builder.UpdateAndSignInput([]*InputSign{&seller}); err != nil {
+		return nil, err
+	}
+	if !isFinalized(&builder.PsbtUpdater.Upsbt.Inputs[sellerIndex]) {
+		return nil, builder.inputError(sellerIndex, errors.New("seller input is not finalized"))
+	}
+	return builder, nil
+}
+
+// listingPlaceholderScript is the zero value OP_RETURN standing for a
+// purchase output before the payout of a legacy listing
+const listingPlaceholderScript = "6a"
+
+// listingSellerIndex returns the index of the seller input of a listing,
+// the last input after the placeholders of a legacy listing
+func listingSellerIndex(listing *PsbtBuilder) (int, error) {
+	tx := listing.PsbtUpdater.Upsbt.UnsignedTx
+	sellerIndex := len(tx.TxIn) - 1
+	if sellerIndex != 0 && sellerIndex != PurchaseDummyInputs || len(tx.TxOut) <= sellerIndex {
+		return 0, errors.New("listing must have one seller input and a payout output")
+	}
+	for i := 0; i < sellerIndex; i++ {
+		if tx.TxIn[i].PreviousOutPoint.Hash.String() != OccupiedTxId || listing.inputUtxo(i) != nil {
+			return 0, listing.inputError(i, errors.New("listing input before the seller is not a placeholder"))
+		}
+	}
+	return sellerIndex, nil
+}
+
+func isFinalized(pIn *psbt.PInput) bool {
+	return pIn.FinalScriptSig != nil || pIn.FinalScriptWitness != nil
+}
+
+// checkOutputDust rejects an output the network would not relay
+func checkOutputDust(builder *PsbtBuilder, index int) error {
+	payout := builder.PsbtUpdater.Upsbt.UnsignedTx.TxOut[index]
+	if threshold := DustThreshold(payout, DefaultPolicyOptions().DustRelayFeeRate); payout.Value < threshold {
+		return &AmountError{Input: -1, Output: index, Amount: btcutil.Amount(payout.Value),
+			Reason: fmt.Sprintf("below dust threshold %d", threshold)}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package psbt_sdk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func testP2trAddress(t testing.TB, i byte) string {
+	outputKey := txscript.ComputeTaprootKeyNoScript(testPrivKey(i).PubKey())
+	address, err := btcutil.NewAddressTaproot(outputKey.SerializeCompressed()[1:], &chaincfg.SigNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressTaproot() error = %v", err)
+	}
+	return address.EncodeAddress()
+}
+
+// testPrevTx is a previous tx paying value to pkScript at output 0
+func testPrevTx(t testing.TB, value int64, pkScript string) (*wire.MsgTx, string) {
+	prevTx := wire.NewMsgTx(2)
+	prevTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 7}, nil, nil))
+	script, _ := hex.DecodeString(pkScript)
+	prevTx.AddTxOut(wire.NewTxOut(value, script))
+	var raw bytes.Buffer
+	if err := prevTx.Serialize(&raw); err != nil {
+		t.Fatalf("Serialize() error = %v", err)
+	}
+	return prevTx, hex.EncodeToString(raw.Bytes())
+}
+
+func TestCreateListingPsbt(t *testing.T) {
+	priHex := hex.EncodeToString(testPrivKey(1).Serialize())
+	p2pkh, _ := btcutil.NewAddressPubKeyHash(btcutil.Hash160(testPrivKey(1).PubKey().SerializeCompressed()), &chaincfg.SigNetParams)
+	p2pkhScript, _ := txscript.PayToAddrScript(p2pkh)
+	prevTx, prevRaw := testPrevTx(t, 546, hex.EncodeToString(p2pkhScript))
+
+	for name, listing := range map[string]*Listing{
+		"legacy": {
+			Inscription: Input{OutTxId: prevTx.TxHash().String()},
+			Seller:      &InputSign{UtxoType: NonWitness, OutRaw: prevRaw, PriHex: priHex},
+		},
+		"segwit": {
+			Inscription: Input{OutTxId: "93b3484083c31769c6a82b9c1cb66979463d86b3edea5044dede1ffdb74c3db2", OutIndex: 1},
+			Seller:      &InputSign{UtxoType: Witness, PkScript: testP2wpkhScript(1), Amount: 546, PriHex: priHex},
+		},
+		"taproot": {
+			Inscription: Input{OutTxId: "93b3484083c31769c6a82b9c1cb66979463d86b3edea5044dede1ffdb74c3db2", OutIndex: 2},
+			Seller:      &InputSign{UtxoType: Taproot, PkScript: testP2trScript(1), Amount: 10000, PriHex: priHex},
+		},
+	} {
+		listing.Price = 250000
+		listing.PayoutAddress = testP2trAddress(t, 1)
+		listing.Fees = []Output{{Script: testP2wpkhScript(9), Amount: 5000}}
+		builder, err := CreateListingPsbt(&chaincfg.SigNetParams, listing)
+		if err != nil {
+			t.Fatalf("%s: CreateListingPsbt() error = %v", name, err)
+		}
+
+		// a legacy seller is signed behind the purchase dummies
+		seller, committed := 0, []int{0}
+		if listing.Seller.UtxoType == NonWitness {
+			seller, committed = PurchaseDummyInputs, []int{0, 1, 2}
+		}
+		outputs := builder.GetOutputs()
+		if len(outputs) != seller+2 || outputs[seller].Value != 250000 ||
+			hex.EncodeToString(outputs[seller].PkScript) != testP2trScript(1) || outputs[seller+1].Value != 5000 {
+			t.Fatalf("%s: outputs = %v", name, outputs)
+		}
+		commitments := builder.SignatureCommitments()
+		if len(commitments) != 1 || commitments[0].Signature.SighashType != ListingSighashType ||
+			!reflect.DeepEqual(commitments[0].Inputs, []int{seller}) || !reflect.DeepEqual(commitments[0].Outputs, committed) {
+			t.Fatalf("%s: SignatureCommitments() = %+v", name, commitments)
+		}
+		if results, err := builder.Verify(); err != nil || !results[seller].Valid {
+			t.Fatalf("%s: Verify() error = %v", name, err)
+		}
+		if listing.Seller.SighashType != 0 || listing.Seller.Index != 0 {
+			t.Fatalf("%s: CreateListingPsbt() changed the seller input", name)
+		}
+	}
+
+	// the taproot signature carries the explicit sighash byte
+	builder, _ := CreateListingPsbt(&chaincfg.SigNetParams, &Listing{
+		Inscription:   Input{OutTxId: "93b3484083c31769c6a82b9c1cb66979463d86b3edea5044dede1ffdb74c3db2"},
+		Seller:        &InputSign{UtxoType: Taproot, PkScript: testP2trScript(1), Amount: 10000, PriHex: priHex},
+		Price:         1000,
+		PayoutAddress: testP2trAddress(t, 1),
+	})
+	witness, err := parseTxWitness(builder.PsbtUpdater.Upsbt.Inputs[0].FinalScriptWitness)
+	if err != nil || len(witness) != 1 || len(witness[0]) != 65 || witness[0][64] != byte(ListingSighashType) {
+		t.Fatalf("taproot witness = %x, %v, want a 65 byte signature ending in 0x83", witness, err)
+	}
+
+	_, err = CreateListingPsbt(&chaincfg.SigNetParams, &Listing{
+		Inscription:   Input{OutTxId: "93b3484083c31769c6a82b9c1cb66979463d86b3edea5044dede1ffdb74c3db2"},
+		Seller:        &InputSign{UtxoType: Taproot, PkScript: testP2trScript(1), Amount: 10000, PriHex: priHex},
+		Price:         300,
+		PayoutAddress: testP2trAddress(t, 1),
+	})
+	if !errors.Is(err, ErrInvalidAmount) {
+		t.Fatalf("CreateListingPsbt() of a dust price error = %v, want ErrInvalidAmount", err)
+	}
+}
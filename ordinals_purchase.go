@@ -0,0 +1,216 @@
+package psbt_sdk
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// PurchaseDummyInputs is the least number of dummy inputs of a purchase, the
+// first two are merged into output 0 so the inscription lands at the start
+// of its own output
+const PurchaseDummyInputs = 2
+
+// Purchase buys a listed inscription. Dummies are buyer utxos padding the
+// seller input to the index of its payout, Payments fund the price, the fees
+// and the network fee. The buyer inputs are left unsigned for the wallet.
+type Purchase struct {
+	Listing        string   `json:"listing"` // listing psbt hex
+	Dummies        []*Utxo  `json:"dummies"`
+	Payments       []*Utxo  `json:"payments"`
+	ReceiveAddress string   `json:"receive_address"`
+	ChangeAddress  string   `json:"change_address"`
+	PlatformFees   []Output `json:"platform_fees"`
+	FeeRate        int64    `json:"fee_rate"` // sat/vB
+}
+
+// CreatePurchasePsbt merges a listing into the purchase psbt of its buyer.
+// With n dummies the inputs are the dummies, the seller input at index n and
+// the payments, the outputs are the dummies, the inscription at n-1, the
+// payout at n, the listing fees, PlatformFees and the change, dropped when
+// it would be dust.
+func CreatePurchasePsbt(netParams *chaincfg.Params, purchase *Purchase) (*PsbtBuilder, error) {
+	listing, err := NewPsbtBuilder(netParams, purchase.Listing)
+	if err != nil {
+		return nil, err
+	}
+	listingIndex, err := checkListing(listing)
+	if err != nil {
+		return nil, err
+	}
+	if len(purchase.Dummies) < PurchaseDummyInputs {
+		return nil, errors.New(fmt.Sprintf("purchase needs at least %d dummy inputs", PurchaseDummyInputs))
+	}
+	if listingIndex > 0 && len(purchase.Dummies) != listingIndex {
+		return nil, errors.New(fmt.Sprintf("legacy listing is signed at input %d, purchase needs exactly %d dummy inputs",
+			listingIndex, listingIndex))
+	}
+	if len(purchase.Payments) == 0 {
+		return nil, errors.New("purchase without payment inputs")
+	}
+	if purchase.FeeRate <= 0 {
+		return nil, errors.New(fmt.Sprintf("invalid fee rate %d", purchase.FeeRate))
+	}
+
+	var (
+		sellerIndex = len(purchase.Dummies)
+		listingTx   = listing.PsbtUpdater.Upsbt.UnsignedTx
+		seller      = listingTx.TxIn[listingIndex]
+		inscription = listing.inputUtxo(listingIndex)
+		ins         = make([]Input, 0, sellerIndex+1+len(purchase.Payments))
+		outs        = make([]Output, 0, sellerIndex+len(listingTx.TxOut)+len(purchase.PlatformFees)+1)
+	)
+	for _, dummy := range purchase.Dummies {
+		ins = append(ins, dummy.Input)
+	}
+	ins = append(ins, Input{OutTxId: seller.PreviousOutPoint.Hash.String(), OutIndex: seller.PreviousOutPoint.Index})
+	for _, payment := range purchase.Payments {
+		ins = append(ins, payment.Input)
+	}
+	// dummy amounts are only known once their utxos are added
+	for i := 1; i < sellerIndex; i++ {
+		outs = append(outs, Output{Address: purchase.ChangeAddress})
+	}
+	outs = append(outs, Output{Address: purchase.ReceiveAddress, Amount: btcutil.Amount(inscription.Value)})
+	for _, txOut := range listingTx.TxOut[listingIndex:] {
+		outs = append(outs, Output{Script: hex.EncodeToString(txOut.PkScript), Amount: btcutil.Amount(txOut.Value)})
+	}
+	outs = append(outs, purchase.PlatformFees...)
+	outs = append(outs, Output{Address: purchase.ChangeAddress})
+
+	builder, err := CreatePsbtBuilder(netParams, ins, outs)
+	if err != nil {
+		return nil, err
+	}
+	// the seller signature commits to the version, the lock time and its
+	// own sequence
+	tx := builder.PsbtUpdater.Upsbt.UnsignedTx
+	tx.Version, tx.LockTime = listingTx.Version, listingTx.LockTime
+	tx.TxIn[sellerIndex].Sequence = seller.Sequence
+	builder.PsbtUpdater.Upsbt.Inputs[sellerIndex] = clonePInput(&listing.PsbtUpdater.Upsbt.Inputs[listingIndex])
+
+	for i, dummy := range purchase.Dummies {
+		if err = builder.addUtxo(dummy, i); err != nil {
+			return nil, err
+		}
+	}
+	for i, payment := range purchase.Payments {
+		if err = builder.addUtxo(payment, sellerIndex+1+i); err != nil {
+			return nil, err
+		}
+	}
+	if err = builder.setDummyOutputs(sellerIndex); err != nil {
+		return nil, err
+	}
+	if err = builder.setChange(purchase.FeeRate); err != nil {
+		return nil, err
+	}
+	if err = builder.verifyFinalizedInput(sellerIndex, inscription, builder.newSigHashCache()); err != nil {
+		return nil, builder.inputError(sellerIndex, err)
+	}
+	// the first sat of the listed utxo, where the inscription usually sits
+	listed := builder.outPoint(sellerIndex).String()
+	err = builder.checkInscriptions([]*InscribedSat{{Id: listed, Input: sellerIndex}}, map[string]int{listed: sellerIndex - 1})
+	if err != nil {
+		return nil, err
+	}
+	return builder, nil
+}
+
+// checkListing checks a listing is a single seller input signed with
+// ListingSighashType and finalized, it returns the index of the seller
+func checkListing(listing *PsbtBuilder) (int, error) {
+	sellerIndex, err := listingSellerIndex(listing)
+	if err != nil {
+		return 0, err
+	}
+	if listing.inputUtxo(sellerIndex) == nil {
+		return 0, listing.missingUtxoError(sellerIndex)
+	}
+	if !isFinalized(&listing.PsbtUpdater.Upsbt.Inputs[sellerIndex]) {
+		return 0, listing.inputError(sellerIndex, errors.New("seller input is not finalized"))
+	}
+	for _, commitment := range listing.SignatureCommitments() {
+		if commitment.Signature.SighashType != ListingSighashType {
+			return 0, listing.inputError(sellerIndex, errors.New(fmt.Sprintf("seller signed with sighash %d, want %d",
+				commitment.Signature.SighashType, ListingSighashType)))
+		}
+	}
+	return sellerIndex, checkOutputDust(listing, sellerIndex)
+}
+
+// addUtxo adds the utxo of the unsigned input at index
+func (s *PsbtBuilder) addUtxo(u *Utxo, index int) error {
+	signIn := u.signIn(index)
+	if err := s.addSignInUtxo(signIn); err != nil {
+		return err
+	}
+	if u.RedeemScript == "" {
+		return nil
+	}
+	redeemScript, err := hex.DecodeString(u.RedeemScript)
+	if err != nil {
+		return err
+	}
+	return s.PsbtUpdater.AddInRedeemScript(redeemScript, index)
+}
+
+// setDummyOutputs returns the dummy amounts to the outputs before the
+// inscription, output 0 takes the first two
+func (s *PsbtBuilder) setDummyOutputs(dummies int) error {
+	tx := s.PsbtUpdater.Upsbt.UnsignedTx
+	var merged btcutil.Amount
+	for i := 0; i < dummies; i++ {
+		prevOut := s.inputUtxo(i)
+		if prevOut == nil {
+			return s.missingUtxoError(i)
+		}
+		if i >= PurchaseDummyInputs {
+			tx.TxOut[i-1].Value = prevOut.Value
+			continue
+		}
+		amount, err := addAmount(merged, btcutil.Amount(prevOut.Value))
+		if err != nil {
+			return &AmountError{Input: i, Output: -1, Amount: btcutil.Amount(prevOut.Value), Reason: err.Error()}
+		}
+		merged = amount
+	}
+	tx.TxOut[0].Value = int64(merged)
+	return nil
+}
+
+// setChange sets the last output to the inputs left after the
+// outputs and the fee at feeRate, it drops the output when that is dust
+func (s *PsbtBuilder) setChange(feeRate int64) error {
+	var (
+		tx     = s.PsbtUpdater.Upsbt.UnsignedTx
+		change = len(tx.TxOut) - 1
+	)
+	available, err := s.fee()
+	if err != nil {
+		return err
+	}
+	vSize, err := s.calTxSize()
+	if err != nil {
+		return err
+	}
+	if amount := available - btcutil.Amount(vSize*feeRate); amount >= 0 &&
+		amount >= btcutil.Amount(DustThreshold(tx.TxOut[change], DefaultPolicyOptions().DustRelayFeeRate)) {
+		tx.TxOut[change].Value = int64(amount)
+		return nil
+	}
+
+	tx.TxOut = tx.TxOut[:change]
+	s.PsbtUpdater.Upsbt.Outputs = s.PsbtUpdater.Upsbt.Outputs[:change]
+	if vSize, err = s.calTxSize(); err != nil {
+		return err
+	}
+	if fee := btcutil.Amount(vSize * feeRate); available < fee {
+		return &AmountError{Input: -1, Output: -1, Amount: available,
+			Reason: fmt.Sprintf("inputs short of the network fee %d", int64(fee))}
+	}
+	return nil
+}
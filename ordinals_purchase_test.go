@@ -0,0 +1,169 @@
+package psbt_sdk
+
+import (
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+func testListingHex(t *testing.T) string {
+	listing, err := CreateListingPsbt(&chaincfg.SigNetParams, &Listing{
+		Inscription: Input{OutTxId: "93b3484083c31769c6a82b9c1cb66979463d86b3edea5044dede1ffdb74c3db2", OutIndex: 2},
+		Seller: &InputSign{UtxoType: Taproot, PkScript: testP2trScript(1), Amount: 10000,
+			PriHex: hex.EncodeToString(testPrivKey(1).Serialize())},
+		Price:         250000,
+		PayoutAddress: testP2trAddress(t, 1),
+		Fees:          []Output{{Script: testP2wpkhScript(9), Amount: 5000}},
+	})
+	if err != nil {
+		t.Fatalf("CreateListingPsbt() error = %v", err)
+	}
+	listingHex, err := listing.ToString()
+	if err != nil {
+		t.Fatalf("ToString() error = %v", err)
+	}
+	return listingHex
+}
+
+func testPurchase(t *testing.T) *Purchase {
+	txId := "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"
+	return &Purchase{
+		Listing: testListingHex(t),
+		Dummies: []*Utxo{
+			{Input: Input{OutTxId: txId, OutIndex: 0}, UtxoType: Witness, PkScript: testP2wpkhScript(2), Amount: 600},
+			{Input: Input{OutTxId: txId, OutIndex: 1}, UtxoType: Witness, PkScript: testP2wpkhScript(2), Amount: 600},
+		},
+		Payments: []*Utxo{
+			{Input: Input{OutTxId: txId, OutIndex: 2}, UtxoType: Taproot, PkScript: testP2trScript(3), Amount: 300000},
+		},
+		ReceiveAddress: testP2trAddress(t, 3),
+		ChangeAddress:  testP2trAddress(t, 3),
+		PlatformFees:   []Output{{Script: testP2wpkhScript(8), Amount: 2000}},
+		FeeRate:        10,
+	}
+}
+
+func TestCreatePurchasePsbt(t *testing.T) {
+	purchase := testPurchase(t)
+	builder, err := CreatePurchasePsbt(&chaincfg.SigNetParams, purchase)
+	if err != nil {
+		t.Fatalf("CreatePurchasePsbt() error = %v", err)
+	}
+
+	outputs := builder.GetOutputs()
+	if len(outputs) != 6 || outputs[0].Value != 1200 || outputs[1].Value != 10000 ||
+		hex.EncodeToString(outputs[1].PkScript) != testP2trScript(3) || outputs[2].Value != 250000 ||
+		hex.EncodeToString(outputs[2].PkScript) != testP2trScript(1) || outputs[3].Value != 5000 || outputs[4].Value != 2000 {
+		t.Fatalf("outputs = %v", outputs)
+	}
+	commitments := builder.SignatureCommitments()
+	if len(commitments) != 1 || !reflect.DeepEqual(commitments[0].Inputs, []int{2}) ||
+		!reflect.DeepEqual(commitments[0].Outputs, []int{2}) {
+		t.Fatalf("SignatureCommitments() = %+v, want the seller input paired with the payout", commitments)
+	}
+	if builder.IsComplete() {
+		t.Fatalf("IsComplete() = true, want buyer inputs unsigned")
+	}
+	vSize, _ := builder.CalTxSize()
+	if fee, err := builder.Fee(); err != nil || int64(fee) < vSize*10 || int64(fee) > vSize*10+1 {
+		t.Fatalf("Fee() = %d, %v, want %d", fee, err, vSize*10)
+	}
+
+	signIns := []*InputSign{
+		{UtxoType: Witness, Index: 0, PkScript: testP2wpkhScript(2), Amount: 600, SighashType: txscript.SigHashAll,
+			PriHex: hex.EncodeToString(testPrivKey(2).Serialize())},
+		{UtxoType: Witness, Index: 1, PkScript: testP2wpkhScript(2), Amount: 600, SighashType: txscript.SigHashAll,
+			PriHex: hex.EncodeToString(testPrivKey(2).Serialize())},
+		{UtxoType: Taproot, Index: 3, PkScript: testP2trScript(3), Amount: 300000, SighashType: txscript.SigHashDefault,
+			PriHex: hex.EncodeToString(testPrivKey(3).Serialize())},
+	}
+	if err = builder.UpdateAndSignInput(signIns); err != nil {
+		t.Fatalf("UpdateAndSignInput() error = %v", err)
+	}
+	if results, err := builder.Verify(); err != nil || len(results) != 4 {
+		t.Fatalf("Verify() = %v, %v", results, err)
+	}
+	if _, err = builder.ExtractPsbtTransaction(); err != nil {
+		t.Fatalf("ExtractPsbtTransaction() error = %v", err)
+	}
+}
+
+func TestCreatePurchasePsbt_Errors(t *testing.T) {
+	purchase := testPurchase(t)
+	purchase.Dummies = purchase.Dummies[:1]
+	if _, err := CreatePurchasePsbt(&chaincfg.SigNetParams, purchase); err == nil {
+		t.Fatalf("CreatePurchasePsbt() with one dummy error = nil")
+	}
+
+	purchase = testPurchase(t)
+	purchase.Payments[0].Amount = 257000
+	if _, err := CreatePurchasePsbt(&chaincfg.SigNetParams, purchase); !errors.Is(err, ErrInvalidAmount) {
+		t.Fatalf("CreatePurchasePsbt() short of the network fee error = %v, want ErrInvalidAmount", err)
+	}
+
+	// without a change output the remainder goes to the fee
+	purchase = testPurchase(t)
+	purchase.Payments[0].Amount = 261800
+	builder, err := CreatePurchasePsbt(&chaincfg.SigNetParams, purchase)
+	if err != nil || len(builder.GetOutputs()) != 5 {
+		t.Fatalf("CreatePurchasePsbt() = %v, want the dust change dropped", err)
+	}
+
+	// a listing signed with SIGHASH_ALL can't be merged
+	listing, _ := NewPsbtBuilder(&chaincfg.SigNetParams, testListingHex(t))
+	listing.PsbtUpdater.Upsbt.Inputs[0].FinalScriptWitness = nil
+	if err = listing.UpdateAndSignInput([]*InputSign{{UtxoType: Taproot, PkScript: testP2trScript(1), Amount: 10000,
+		SighashType: txscript.SigHashAll, PriHex: hex.EncodeToString(testPrivKey(1).Serialize())}}); err != nil {
+		t.Fatalf("UpdateAndSignInput() error = %v", err)
+	}
+	purchase = testPurchase(t)
+	purchase.Listing, _ = listing.ToString()
+	if _, err = CreatePurchasePsbt(&chaincfg.SigNetParams, purchase); err == nil {
+		t.Fatalf("CreatePurchasePsbt() of a SIGHASH_ALL listing error = nil")
+	}
+}
+
+func TestCreatePurchasePsbt_Legacy(t *testing.T) {
+	p2pkh, _ := btcutil.NewAddressPubKeyHash(btcutil.Hash160(testPrivKey(1).PubKey().SerializeCompressed()), &chaincfg.SigNetParams)
+	p2pkhScript, _ := txscript.PayToAddrScript(p2pkh)
+	prevTx, prevRaw := testPrevTx(t, 10000, hex.EncodeToString(p2pkhScript))
+	listing, err := CreateListingPsbt(&chaincfg.SigNetParams, &Listing{
+		Inscription:   Input{OutTxId: prevTx.TxHash().String()},
+		Seller:        &InputSign{UtxoType: NonWitness, OutRaw: prevRaw, PriHex: hex.EncodeToString(testPrivKey(1).Serialize())},
+		Price:         250000,
+		PayoutAddress: testP2trAddress(t, 1),
+		Fees:          []Output{{Script: testP2wpkhScript(9), Amount: 5000}},
+	})
+	if err != nil {
+		t.Fatalf("CreateListingPsbt() error = %v", err)
+	}
+	purchase := testPurchase(t)
+	if purchase.Listing, err = listing.ToString(); err != nil {
+		t.Fatalf("ToString() error = %v", err)
+	}
+
+	builder, err := CreatePurchasePsbt(&chaincfg.SigNetParams, purchase)
+	if err != nil {
+		t.Fatalf("CreatePurchasePsbt() error = %v", err)
+	}
+	outputs := builder.GetOutputs()
+	if len(outputs) != 6 || outputs[1].Value != 10000 || outputs[2].Value != 250000 || outputs[3].Value != 5000 {
+		t.Fatalf("outputs = %v", outputs)
+	}
+	if results, err := builder.Verify(); err != nil || !results[2].Valid {
+		t.Fatalf("Verify() error = %v, want the legacy seller input valid", err)
+	}
+
+	// the seller signature commits to its index
+	purchase.Dummies = append(purchase.Dummies, &Utxo{Input: Input{OutTxId: strings.Repeat("55", 32)},
+		UtxoType: Witness, PkScript: testP2wpkhScript(2), Amount: 600})
+	if _, err = CreatePurchasePsbt(&chaincfg.SigNetParams, purchase); err == nil {
+		t.Fatalf("CreatePurchasePsbt() of a legacy listing with three dummies error = nil")
+	}
+}
@@ -0,0 +1,176 @@
+package psbt_sdk
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// SatRange is the half open range of ordinal numbers [Start, End)
+type SatRange struct {
+	Start uint64 `json:"start"`
+	End   uint64 `json:"end"`
+}
+
+// InscribedSat is an inscription sitting Offset sats into an input
+type InscribedSat struct {
+	Id     string `json:"id"`
+	Input  int    `json:"input"`
+	Offset uint64 `json:"offset"`
+}
+
+// SatLocation is where an inscribed sat ends: OutputOffset sats into
+// Output, or into the fee when Output is -1
+type SatLocation struct {
+	InscribedSat
+	Output       int    `json:"output"`
+	OutputOffset uint64 `json:"output_offset"`
+}
+
+// AssignSatRanges applies ordinal theory's first in first out assignment:
+// the ranges of the inputs, in order, fill the outputs in order and what is
+// left is the fee. It fails when the ranges hold fewer sats than the outputs.
+func AssignSatRanges(inputs [][]SatRange, outputValues []int64) ([][]SatRange, []SatRange, error) {
+	var pending []SatRange
+	for i, ranges := range inputs {
+		for _, r := range ranges {
+			if r.End < r.Start {
+				return nil, nil, errors.New(fmt.Sprintf("Index-[%d] sat range %d-%d ends before it starts", i, r.Start, r.End))
+			}
+			if r.End > r.Start {
+				pending = append(pending, r)
+			}
+		}
+	}
+
+	outputs := make([][]SatRange, len(outputValues))
+	for i, value := range outputValues {
+		if value < 0 {
+			return nil, nil, &AmountError{Input: -1, Output: i, Amount: btcutil.Amount(value), Reason: "is negative"}
+		}
+		need := uint64(value)
+		for need > 0 {
+			if len(pending) == 0 {
+				return nil, nil, &AmountError{Input: -1, Output: i, Amount: btcutil.Amount(value), Reason: "exceeds the sats of the inputs"}
+			}
+			r := pending[0]
+			if size := r.End - r.Start; size <= need {
+				outputs[i] = append(outputs[i], r)
+				pending = pending[1:]
+				need -= size
+				continue
+			}
+			outputs[i] = append(outputs[i], SatRange{Start: r.Start, End: r.Start + need})
+			pending[0].Start += need
+			need = 0
+		}
+	}
+	return outputs, pending, nil
+}
+
+// LocateInscribedSats follows inscribed sats through a tx spending inputs of
+// inputValues into outputValues
+func LocateInscribedSats(inputValues []int64, outputValues []int64, sats []*InscribedSat) ([]*SatLocation, error) {
+	// start of each input and output in the sats of the tx
+	var total uint64
+	inputStarts := make([]uint64, len(inputValues))
+	for i, value := range inputValues {
+		if value < 0 {
+			return nil, &AmountError{Input: i, Output: -1, Amount: btcutil.Amount(value), Reason: "is negative"}
+		}
+		inputStarts[i] = total
+		total += uint64(value)
+	}
+	var spent uint64
+	outputEnds := make([]uint64, len(outputValues))
+	for i, value := range outputValues {
+		if value < 0 {
+			return nil, &AmountError{Input: -1, Output: i, Amount: btcutil.Amount(value), Reason: "is negative"}
+		}
+		spent += uint64(value)
+		outputEnds[i] = spent
+	}
+	if spent > total {
+		return nil, &AmountError{Input: -1, Output: -1, Amount: btcutil.Amount(int64(spent)), Reason: fmt.Sprintf("of outputs exceeds inputs %d", total)}
+	}
+
+	locations := make([]*SatLocation, 0, len(sats))
+	for _, sat := range sats {
+		if sat.Input < 0 || sat.Input >= len(inputValues) {
+			return nil, &InputIndexError{Index: sat.Input, Count: len(inputValues)}
+		}
+		if sat.Offset >= uint64(inputValues[sat.Input]) {
+			return nil, &AmountError{Input: sat.Input, Output: -1, Amount: btcutil.Amount(inputValues[sat.Input]),
+				Reason: fmt.Sprintf("has no sat at offset %d of inscription %s", sat.Offset, sat.Id)}
+		}
+		position := inputStarts[sat.Input] + sat.Offset
+		location := &SatLocation{InscribedSat: *sat, Output: -1, OutputOffset: position - spent}
+		var start uint64
+		for i, end := range outputEnds {
+			if position < end {
+				location.Output, location.OutputOffset = i, position-start
+				break
+			}
+			start = end
+		}
+		locations = append(locations, location)
+	}
+	return locations, nil
+}
+
+// LocateInscriptions follows inscribed sats of the psbt inputs to the
+// outputs, every input needs its utxo
+func (s *PsbtBuilder) LocateInscriptions(sats []*InscribedSat) ([]*SatLocation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.locateInscriptions(sats)
+}
+
+func (s *PsbtBuilder) locateInscriptions(sats []*InscribedSat) ([]*SatLocation, error) {
+	tx := s.PsbtUpdater.Upsbt.UnsignedTx
+	inputValues := make([]int64, len(tx.TxIn))
+	for i := range tx.TxIn {
+		txOut := s.inputUtxo(i)
+		if txOut == nil {
+			return nil, s.missingUtxoError(i)
+		}
+		inputValues[i] = txOut.Value
+	}
+	return LocateInscribedSats(inputValues, txOutValues(tx.TxOut), sats)
+}
+
+// CheckInscriptions refuses a psbt sending an inscribed sat to fees, or to
+// another output than the one outputs maps its inscription id to. Sats
+// missing from outputs may end in any output.
+func (s *PsbtBuilder) CheckInscriptions(sats []*InscribedSat, outputs map[string]int) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.checkInscriptions(sats, outputs)
+}
+
+func (s *PsbtBuilder) checkInscriptions(sats []*InscribedSat, outputs map[string]int) error {
+	locations, err := s.locateInscriptions(sats)
+	if err != nil {
+		return err
+	}
+	for _, location := range locations {
+		want, ok := outputs[location.Id]
+		if !ok {
+			want = -1
+		}
+		if location.Output < 0 || (want >= 0 && location.Output != want) {
+			return &SatFlowError{Id: location.Id, Input: location.Input, Output: location.Output, Want: want}
+		}
+	}
+	return nil
+}
+
+func txOutValues(txOuts []*wire.TxOut) []int64 {
+	values := make([]int64, len(txOuts))
+	for i, txOut := range txOuts {
+		values[i] = txOut.Value
+	}
+	return values
+}
@@ -0,0 +1,87 @@
+package psbt_sdk
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func TestAssignSatRanges(t *testing.T) {
+	outputs, fee, err := AssignSatRanges([][]SatRange{
+		{{Start: 0, End: 100}, {Start: 200, End: 250}},
+		{{Start: 1000, End: 1100}},
+	}, []int64{120, 50})
+	if err != nil {
+		t.Fatalf("AssignSatRanges() error = %v", err)
+	}
+	want := [][]SatRange{
+		{{Start: 0, End: 100}, {Start: 200, End: 220}},
+		{{Start: 220, End: 250}, {Start: 1000, End: 1020}},
+	}
+	if !reflect.DeepEqual(outputs, want) || !reflect.DeepEqual(fee, []SatRange{{Start: 1020, End: 1100}}) {
+		t.Fatalf("AssignSatRanges() = %v, %v", outputs, fee)
+	}
+
+	if _, _, err = AssignSatRanges([][]SatRange{{{Start: 0, End: 100}}}, []int64{60, 50}); !errors.Is(err, ErrInvalidAmount) {
+		t.Fatalf("AssignSatRanges() error = %v, want outputs exceeding the ranges refused", err)
+	}
+	if _, _, err = AssignSatRanges([][]SatRange{{{Start: 10, End: 0}}}, nil); err == nil {
+		t.Fatalf("AssignSatRanges() accepted a reversed range")
+	}
+}
+
+func TestLocateInscribedSats(t *testing.T) {
+	locations, err := LocateInscribedSats([]int64{1000, 500}, []int64{600, 800}, []*InscribedSat{
+		{Id: "a", Input: 0, Offset: 700},
+		{Id: "b", Input: 1, Offset: 0},
+		{Id: "c", Input: 1, Offset: 450},
+	})
+	if err != nil {
+		t.Fatalf("LocateInscribedSats() error = %v", err)
+	}
+	want := []*SatLocation{
+		{InscribedSat: InscribedSat{Id: "a", Input: 0, Offset: 700}, Output: 1, OutputOffset: 100},
+		{InscribedSat: InscribedSat{Id: "b", Input: 1, Offset: 0}, Output: 1, OutputOffset: 400},
+		{InscribedSat: InscribedSat{Id: "c", Input: 1, Offset: 450}, Output: -1, OutputOffset: 50},
+	}
+	if !reflect.DeepEqual(locations, want) {
+		t.Fatalf("LocateInscribedSats() = %+v", locations)
+	}
+
+	if _, err = LocateInscribedSats([]int64{1000}, nil, []*InscribedSat{{Input: 0, Offset: 1000}}); !errors.Is(err, ErrInvalidAmount) {
+		t.Fatalf("LocateInscribedSats() error = %v, want offset past the input refused", err)
+	}
+	if _, err = LocateInscribedSats([]int64{1000}, nil, []*InscribedSat{{Input: 1}}); !errors.Is(err, ErrInputIndex) {
+		t.Fatalf("LocateInscribedSats() error = %v, want ErrInputIndex", err)
+	}
+	if _, err = LocateInscribedSats([]int64{1000}, []int64{1001}, nil); !errors.Is(err, ErrInvalidAmount) {
+		t.Fatalf("LocateInscribedSats() error = %v, want outputs exceeding inputs refused", err)
+	}
+}
+
+func TestCheckInscriptions(t *testing.T) {
+	builder, err := CreatePurchasePsbt(&chaincfg.SigNetParams, testPurchase(t))
+	if err != nil {
+		t.Fatalf("CreatePurchasePsbt() error = %v", err)
+	}
+	listed := []*InscribedSat{{Id: "listed", Input: 2}}
+	if err = builder.CheckInscriptions(listed, map[string]int{"listed": 1}); err != nil {
+		t.Fatalf("CheckInscriptions() error = %v", err)
+	}
+	if err = builder.CheckInscriptions(listed, nil); err != nil {
+		t.Fatalf("CheckInscriptions() without destinations error = %v", err)
+	}
+
+	var flowErr *SatFlowError
+	err = builder.CheckInscriptions(listed, map[string]int{"listed": 2})
+	if !errors.Is(err, ErrSatFlow) || !errors.As(err, &flowErr) || flowErr.Output != 1 || flowErr.Want != 2 {
+		t.Fatalf("CheckInscriptions() error = %v, want the wrong output refused", err)
+	}
+	// the last sats of the last payment pay the fee
+	err = builder.CheckInscriptions([]*InscribedSat{{Id: "paid", Input: 3, Offset: 299999}}, nil)
+	if !errors.As(err, &flowErr) || flowErr.Output != -1 {
+		t.Fatalf("CheckInscriptions() error = %v, want the sat spent to fees refused", err)
+	}
+}
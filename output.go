@@ -1,7 +1,9 @@
 package psbt_sdk
 
+import "github.com/btcsuite/btcd/btcutil"
+
 type Output struct {
-	Address string `json:"address"`
-	Amount  uint64 `json:"amount"`
-	Script  string `json:"script"`
+	Address string         `json:"address"`
+	Amount  btcutil.Amount `json:"amount"`
+	Script  string         `json:"script"`
 }
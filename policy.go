@@ -0,0 +1,274 @@
+package psbt_sdk
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// PolicyRule names a relay policy rule, the values are the reject reasons
+// of Bitcoin Core
+type PolicyRule string
+
+const (
+	PolicyVersion            PolicyRule = "version"
+	PolicyTxSize             PolicyRule = "tx-size"
+	PolicyTxSizeSmall        PolicyRule = "tx-size-small"
+	PolicyScriptSigSize      PolicyRule = "scriptsig-size"
+	PolicyScriptSigPushOnly  PolicyRule = "scriptsig-not-pushonly"
+	PolicyScriptPubKey       PolicyRule = "scriptpubkey"
+	PolicyBareMultisig       PolicyRule = "bare-multisig"
+	PolicyDust               PolicyRule = "dust"
+	PolicyMultiOpReturn      PolicyRule = "multi-op-return"
+	PolicyNonStandardInputs  PolicyRule = "bad-txns-nonstandard-inputs"
+	PolicyTooManySigOps      PolicyRule = "bad-txns-too-many-sigops"
+	PolicyInputsBelowOutputs PolicyRule = "bad-txns-in-belowout"
+	PolicyMinRelayFeeNotMet  PolicyRule = "min relay fee not met"
+	PolicyMaxFeeRateExceeded PolicyRule = "max-fee-exceeded"
+)
+
+const (
+	policyMaxP2shSigOps           = 15
+	policyMaxScriptSigSize        = 1650
+	policyMinNonWitnessTxSize     = 65
+	policyBytesPerSigOp           = 20
+	policyMaxStandardMultisigKeys = 3
+	policyWitnessSpendSize        = 32 + 4 + 1 + 107/blockchain.WitnessScaleFactor + 4
+	policyLegacySpendSize         = 32 + 4 + 1 + 107 + 4
+)
+
+// PolicyOptions are the relay policy settings of the node, fee rates are in
+// sat/kvB as in Bitcoin Core
+type PolicyOptions struct {
+	MinTxVersion          int32
+	MaxTxVersion          int32
+	MaxStandardTxWeight   int64
+	MaxStandardSigOpsCost int64
+	MinRelayFeeRate       int64
+	DustRelayFeeRate      int64
+	// MaxFeeRate is the absurd fee ceiling of sendrawtransaction, 0 disables it
+	MaxFeeRate            int64
+	MaxDataCarrierSize    int
+	MaxDataCarrierOutputs int
+	PermitBareMultisig    bool
+}
+
+// DefaultPolicyOptions returns the default relay policy of Bitcoin Core
+func DefaultPolicyOptions() *PolicyOptions {
+	return &PolicyOptions{
+		MinTxVersion:          1,
+		MaxTxVersion:          3,
+		MaxStandardTxWeight:   400000,
+		MaxStandardSigOpsCost: 16000,
+		MinRelayFeeRate:       1000,
+		DustRelayFeeRate:      3000,
+		MaxFeeRate:            10000000,
+		MaxDataCarrierSize:    83,
+		MaxDataCarrierOutputs: 1,
+		PermitBareMultisig:    true,
+	}
+}
+
+// PolicyViolation is a relay policy rule broken by a tx. Input and Output
+// address the offending entry, they are -1 for rules of the whole tx.
+type PolicyViolation struct {
+	Rule    PolicyRule
+	Input   int
+	Output  int
+	Message string
+}
+
+func (v *PolicyViolation) String() string {
+	switch {
+	case v.Input >= 0:
+		return fmt.Sprintf("%s: Index-[%d] %s", v.Rule, v.Input, v.Message)
+	case v.Output >= 0:
+		return fmt.Sprintf("%s: Output-[%d] %s", v.Rule, v.Output, v.Message)
+	}
+	return fmt.Sprintf("%s: %s", v.Rule, v.Message)
+}
+
+// CheckPolicy checks the psbt against the relay policy. A complete psbt is
+// checked as the extracted tx, sizes and sigops of an incomplete one are
+// estimated from the unsigned tx.
+func (s *PsbtBuilder) CheckPolicy(opts *PolicyOptions) ([]*PolicyViolation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	prevOuts, complete := s.prevOutputFetcher()
+	var fetcher txscript.PrevOutputFetcher
+	if complete {
+		fetcher = prevOuts
+	}
+	if s.PsbtUpdater.Upsbt.IsComplete() {
+		tx, err := extractTx(s.PsbtUpdater.Upsbt)
+		if err != nil {
+			return nil, err
+		}
+		return CheckPolicy(tx, fetcher, opts), nil
+	}
+	vSize, err := s.calTxSize()
+	if err != nil {
+		return nil, err
+	}
+	return checkPolicy(s.PsbtUpdater.Upsbt.UnsignedTx, fetcher, vSize*blockchain.WitnessScaleFactor, opts), nil
+}
+
+// CheckPolicy checks a signed tx against the relay policy. prevOuts resolves
+// the spent outputs, rules that need them are skipped when it is nil.
+func CheckPolicy(tx *wire.MsgTx, prevOuts txscript.PrevOutputFetcher, opts *PolicyOptions) []*PolicyViolation {
+	weight := int64(tx.SerializeSizeStripped()*(blockchain.WitnessScaleFactor-1) + tx.SerializeSize())
+	return checkPolicy(tx, prevOuts, weight, opts)
+}
+
+func checkPolicy(tx *wire.MsgTx, prevOuts txscript.PrevOutputFetcher, weight int64, opts *PolicyOptions) []*PolicyViolation {
+	if opts == nil {
+		opts = DefaultPolicyOptions()
+	}
+	var violations []*PolicyViolation
+	violate := func(rule PolicyRule, input, output int, format string, args ...any) {
+		violations = append(violations, &PolicyViolation{Rule: rule, Input: input, Output: output,
+			Message: fmt.Sprintf(format, args...)})
+	}
+
+	if tx.Version < opts.MinTxVersion || tx.Version > opts.MaxTxVersion {
+		violate(PolicyVersion, -1, -1, "version %d", tx.Version)
+	}
+	if weight > opts.MaxStandardTxWeight {
+		violate(PolicyTxSize, -1, -1, "weight %d exceeds %d", weight, opts.MaxStandardTxWeight)
+	}
+	if size := tx.SerializeSizeStripped(); size < policyMinNonWitnessTxSize {
+		violate(PolicyTxSizeSmall, -1, -1, "non witness size %d below %d", size, policyMinNonWitnessTxSize)
+	}
+
+	for i, txIn := range tx.TxIn {
+		if len(txIn.SignatureScript) > policyMaxScriptSigSize {
+			violate(PolicyScriptSigSize, i, -1, "script sig of %d bytes", len(txIn.SignatureScript))
+		}
+		if !txscript.IsPushOnlyScript(txIn.SignatureScript) {
+			violate(PolicyScriptSigPushOnly, i, -1, "script sig is not push only")
+		}
+	}
+
+	dataCarriers := 0
+	for i, txOut := range tx.TxOut {
+		if len(txOut.PkScript) > 0 && txOut.PkScript[0] == txscript.OP_RETURN {
+			dataCarriers++
+			if !txscript.IsPushOnlyScript(txOut.PkScript[1:]) || len(txOut.PkScript) > opts.MaxDataCarrierSize {
+				violate(PolicyScriptPubKey, -1, i, "op_return of %d bytes, limit %d", len(txOut.PkScript), opts.MaxDataCarrierSize)
+			}
+			continue
+		}
+		switch txscript.GetScriptClass(txOut.PkScript) {
+		case txscript.NonStandardTy:
+			violate(PolicyScriptPubKey, -1, i, "non standard script %x", txOut.PkScript)
+			continue
+		case txscript.MultiSigTy:
+			_, keys, _ := txscript.CalcMultiSigStats(txOut.PkScript)
+			if keys > policyMaxStandardMultisigKeys {
+				violate(PolicyScriptPubKey, -1, i, "bare multisig with %d keys", keys)
+				continue
+			}
+			if !opts.PermitBareMultisig {
+				violate(PolicyBareMultisig, -1, i, "bare multisig")
+				continue
+			}
+		}
+		if threshold := DustThreshold(txOut, opts.DustRelayFeeRate); txOut.Value < threshold {
+			violate(PolicyDust, -1, i, "amount %d below dust threshold %d", txOut.Value, threshold)
+		}
+	}
+	if dataCarriers > opts.MaxDataCarrierOutputs {
+		violate(PolicyMultiOpReturn, -1, -1, "%d op_return outputs, limit %d", dataCarriers, opts.MaxDataCarrierOutputs)
+	}
+
+	// legacy sigops are counted even without the spent outputs
+	sigOpsCost := int64(0)
+	for _, txIn := range tx.TxIn {
+		sigOpsCost += int64(txscript.GetSigOpCount(txIn.SignatureScript)) * blockchain.WitnessScaleFactor
+	}
+	for _, txOut := range tx.TxOut {
+		sigOpsCost += int64(txscript.GetSigOpCount(txOut.PkScript)) * blockchain.WitnessScaleFactor
+	}
+	if prevOuts == nil {
+		if sigOpsCost > opts.MaxStandardSigOpsCost {
+			violate(PolicyTooManySigOps, -1, -1, "sigops cost %d exceeds %d", sigOpsCost, opts.MaxStandardSigOpsCost)
+		}
+		return violations
+	}
+
+	inputValue, missing := int64(0), false
+	for i, txIn := range tx.TxIn {
+		prevOut := prevOuts.FetchPrevOutput(txIn.PreviousOutPoint)
+		if prevOut == nil {
+			violate(PolicyNonStandardInputs, i, -1, "missing spent output")
+			missing = true
+			continue
+		}
+		inputValue += prevOut.Value
+		switch txscript.GetScriptClass(prevOut.PkScript) {
+		case txscript.NonStandardTy, txscript.WitnessUnknownTy:
+			violate(PolicyNonStandardInputs, i, -1, "spends non standard script %x", prevOut.PkScript)
+		case txscript.ScriptHashTy:
+			p2shSigOps := txscript.GetPreciseSigOpCount(txIn.SignatureScript, prevOut.PkScript, true)
+			if p2shSigOps > policyMaxP2shSigOps {
+				violate(PolicyNonStandardInputs, i, -1, "%d p2sh sigops, limit %d", p2shSigOps, policyMaxP2shSigOps)
+			}
+			sigOpsCost += int64(p2shSigOps) * blockchain.WitnessScaleFactor
+		}
+		sigOpsCost += int64(txscript.GetWitnessSigOpCount(txIn.SignatureScript, prevOut.PkScript, txIn.Witness))
+	}
+	if sigOpsCost > opts.MaxStandardSigOpsCost {
+		violate(PolicyTooManySigOps, -1, -1, "sigops cost %d exceeds %d", sigOpsCost, opts.MaxStandardSigOpsCost)
+	}
+
+	if missing {
+		return violations
+	}
+
+	outputValue := int64(0)
+	for _, txOut := range tx.TxOut {
+		outputValue += txOut.Value
+	}
+	fee := inputValue - outputValue
+	if fee < 0 {
+		violate(PolicyInputsBelowOutputs, -1, -1, "inputs %d below outputs %d", inputValue, outputValue)
+		return violations
+	}
+	// the virtual size accounts for sigops as Bitcoin Core does
+	if sigOpsWeight := sigOpsCost * policyBytesPerSigOp; sigOpsWeight > weight {
+		weight = sigOpsWeight
+	}
+	vSize := (weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor
+	if minFee := feeForSize(opts.MinRelayFeeRate, vSize); fee < minFee {
+		violate(PolicyMinRelayFeeNotMet, -1, -1, "fee %d below %d for %d vbytes", fee, minFee, vSize)
+	}
+	if opts.MaxFeeRate > 0 {
+		if maxFee := feeForSize(opts.MaxFeeRate, vSize); fee > maxFee {
+			violate(PolicyMaxFeeRateExceeded, -1, -1, "fee %d above %d for %d vbytes", fee, maxFee, vSize)
+		}
+	}
+	return violations
+}
+
+// DustThreshold is the smallest amount of an output that is not dust at the
+// dust relay fee rate in sat/kvB, the cost of creating and spending it
+func DustThreshold(txOut *wire.TxOut, dustRelayFeeRate int64) int64 {
+	if txscript.IsUnspendable(txOut.PkScript) {
+		return 0
+	}
+	size := int64(txOut.SerializeSize())
+	if txscript.IsWitnessProgram(txOut.PkScript) {
+		size += policyWitnessSpendSize
+	} else {
+		size += policyLegacySpendSize
+	}
+	return feeForSize(dustRelayFeeRate, size)
+}
+
+// feeForSize is the fee of size vbytes at a rate in sat/kvB, rounded up
+func feeForSize(feeRate, size int64) int64 {
+	return int64(math.Ceil(float64(feeRate) * float64(size) / 1000))
+}
@@ -0,0 +1,117 @@
+package psbt_sdk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func policyRules(violations []*PolicyViolation) []string {
+	rules := make([]string, 0, len(violations))
+	for _, v := range violations {
+		rules = append(rules, v.String())
+	}
+	sort.Strings(rules)
+	return rules
+}
+
+func TestDustThreshold(t *testing.T) {
+	for script, want := range map[string]int64{
+		"76a914" + strings.Repeat("11", 20) + "88ac": 546,
+		"a914" + strings.Repeat("11", 20) + "87":     540,
+		"0014" + strings.Repeat("11", 20):            294,
+		"0020" + strings.Repeat("11", 32):            330,
+		"5120" + strings.Repeat("11", 32):            330,
+		"6a0401020304":                               0,
+	} {
+		pkScript, _ := hex.DecodeString(script)
+		if got := DustThreshold(wire.NewTxOut(0, pkScript), 3000); got != want {
+			t.Errorf("DustThreshold(%s) = %d, want %d", script, got, want)
+		}
+	}
+}
+
+func TestPsbtBuilder_CheckPolicy(t *testing.T) {
+	builder := testBuilder(t, 2)
+	if err := builder.AddOutput([]Output{
+		{Script: testP2wpkhScript(7), Amount: 293},
+		{Script: "51", Amount: 1000},
+		{Script: "6a" + "4c51" + strings.Repeat("00", 81), Amount: 0},
+		{Script: "6a0401020304", Amount: 0},
+	}); err != nil {
+		t.Fatalf("AddOutput() error = %v", err)
+	}
+	signIns := []*InputSign{
+		{UtxoType: Witness, Index: 0, PkScript: testP2wpkhScript(1), Amount: 40000, SighashType: txscript.SigHashAll,
+			PriHex: hex.EncodeToString(testPrivKey(1).Serialize())},
+		{UtxoType: Taproot, Index: 1, PkScript: testP2trScript(2), Amount: 30000, SighashType: txscript.SigHashDefault,
+			PriHex: hex.EncodeToString(testPrivKey(2).Serialize())},
+	}
+	want := []string{
+		"dust: Output-[1] amount 293 below dust threshold 294",
+		"multi-op-return: 2 op_return outputs, limit 1",
+		"scriptpubkey: Output-[2] non standard script 51",
+		"scriptpubkey: Output-[3] op_return of 84 bytes, limit 83",
+	}
+
+	// incomplete psbts are checked with estimated sizes
+	if err := builder.UpdateAndAddInputWitness(signIns); err != nil {
+		t.Fatalf("UpdateAndAddInputWitness() error = %v", err)
+	}
+	violations, err := builder.CheckPolicy(nil)
+	if err != nil || !reflect.DeepEqual(policyRules(violations), want) {
+		t.Fatalf("CheckPolicy() = %v, %v, want %v", policyRules(violations), err, want)
+	}
+
+	if err = builder.UpdateAndSignInput(signIns); err != nil {
+		t.Fatalf("UpdateAndSignInput() error = %v", err)
+	}
+	violations, err = builder.CheckPolicy(nil)
+	if err != nil || !reflect.DeepEqual(policyRules(violations), want) {
+		t.Fatalf("CheckPolicy() = %v, %v, want %v", policyRules(violations), err, want)
+	}
+
+	// the fee of 18707 sats pays between 50 and 100 sat/vB
+	opts := DefaultPolicyOptions()
+	opts.MaxDataCarrierOutputs = 2
+	opts.MaxDataCarrierSize = 84
+	opts.MinRelayFeeRate = 100000
+	opts.MaxFeeRate = 50000
+	violations, _ = builder.CheckPolicy(opts)
+	rules := policyRules(violations)
+	if len(rules) != 4 || !strings.HasPrefix(rules[1], "max-fee-exceeded: fee 18707") ||
+		!strings.HasPrefix(rules[0], "dust") || !strings.HasPrefix(rules[2], "min relay fee not met: fee 18707") {
+		t.Fatalf("CheckPolicy(opts) = %v", rules)
+	}
+
+	txHex, err := builder.ExtractPsbtTransaction()
+	if err != nil {
+		t.Fatalf("ExtractPsbtTransaction() error = %v", err)
+	}
+	tx := wire.NewMsgTx(4)
+	txBytes, _ := hex.DecodeString(txHex)
+	if err = tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		t.Fatalf("Deserialize() error = %v", err)
+	}
+	tx.Version = 4
+	tx.TxOut = tx.TxOut[:1]
+	violations = CheckPolicy(tx, nil, nil)
+	if rules = policyRules(violations); !reflect.DeepEqual(rules, []string{"version: version 4"}) {
+		t.Fatalf("CheckPolicy(tx) = %v", rules)
+	}
+	prevOuts := txscript.NewMultiPrevOutFetcher(nil)
+	prevOuts.AddPrevOut(tx.TxIn[0].PreviousOutPoint, wire.NewTxOut(40000, []byte{txscript.OP_TRUE}))
+	prevOuts.AddPrevOut(tx.TxIn[1].PreviousOutPoint, wire.NewTxOut(1000, mustDecodeHex(t, testP2trScript(2))))
+	violations = CheckPolicy(tx, prevOuts, nil)
+	rules = policyRules(violations)
+	if len(rules) != 3 || rules[0] != "bad-txns-in-belowout: inputs 41000 below outputs 50000" ||
+		!strings.HasPrefix(rules[1], "bad-txns-nonstandard-inputs: Index-[0] spends non standard script 51") {
+		t.Fatalf("CheckPolicy(tx, prevOuts) = %v", rules)
+	}
+}
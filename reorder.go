@@ -0,0 +1,152 @@
+package psbt_sdk
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// RemoveInput removes the input at index with its psbt metadata
+func (s *PsbtBuilder) RemoveInput(index int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkInputIndex(index); err != nil {
+		return err
+	}
+	layout := s.unchangedLayout()
+	layout.inputs = append(layout.inputs[:index], layout.inputs[index+1:]...)
+	if err := s.checkLayout("remove input", layout); err != nil {
+		return err
+	}
+	s.logInput("psbt input removed", index)
+	delete(s.miniscripts, s.outPoint(index))
+	s.applyLayout(layout)
+	return nil
+}
+
+// RemoveOutput removes the output at index with its psbt metadata
+func (s *PsbtBuilder) RemoveOutput(index int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkOutputIndex(index); err != nil {
+		return err
+	}
+	layout := s.unchangedLayout()
+	layout.outputs = append(layout.outputs[:index], layout.outputs[index+1:]...)
+	if err := s.checkLayout("remove output", layout); err != nil {
+		return err
+	}
+	s.logDebug("psbt output removed", "index", index)
+	s.applyLayout(layout)
+	return nil
+}
+
+// MoveInput moves the input at from to position to, the inputs in between
+// shift by one
+func (s *PsbtBuilder) MoveInput(from, to int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkInputIndex(from); err != nil {
+		return err
+	}
+	if err := s.checkInputIndex(to); err != nil {
+		return err
+	}
+	layout := s.unchangedLayout()
+	layout.inputs = movePosition(layout.inputs, from, to)
+	if err := s.checkLayout("move input", layout); err != nil {
+		return err
+	}
+	s.logInput("psbt input moved", from, "to", to)
+	s.applyLayout(layout)
+	return nil
+}
+
+// MoveOutput moves the output at from to position to, the outputs in
+// between shift by one
+func (s *PsbtBuilder) MoveOutput(from, to int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkOutputIndex(from); err != nil {
+		return err
+	}
+	if err := s.checkOutputIndex(to); err != nil {
+		return err
+	}
+	layout := s.unchangedLayout()
+	layout.outputs = movePosition(layout.outputs, from, to)
+	if err := s.checkLayout("move output", layout); err != nil {
+		return err
+	}
+	s.logDebug("psbt output moved", "from", from, "to", to)
+	s.applyLayout(layout)
+	return nil
+}
+
+// SortBip69 orders inputs and outputs as described in BIP69: inputs by
+// previous txid and output index, outputs by amount and pkScript
+func (s *PsbtBuilder) SortBip69() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx := s.PsbtUpdater.Upsbt.UnsignedTx
+	layout := s.unchangedLayout()
+	sort.SliceStable(layout.inputs, func(i, j int) bool {
+		return bip69InputLess(&tx.TxIn[layout.inputs[i]].PreviousOutPoint, &tx.TxIn[layout.inputs[j]].PreviousOutPoint)
+	})
+	sort.SliceStable(layout.outputs, func(i, j int) bool {
+		return bip69OutputLess(tx.TxOut[layout.outputs[i]], tx.TxOut[layout.outputs[j]])
+	})
+	if err := s.checkLayout("bip69 sort", layout); err != nil {
+		return err
+	}
+	s.logDebug("psbt sorted", "standard", "bip69")
+	s.applyLayout(layout)
+	return nil
+}
+
+// bip69InputLess compares txids in their displayed, byte reversed order
+func bip69InputLess(a, b *wire.OutPoint) bool {
+	for k := len(a.Hash) - 1; k >= 0; k-- {
+		if a.Hash[k] != b.Hash[k] {
+			return a.Hash[k] < b.Hash[k]
+		}
+	}
+	return a.Index < b.Index
+}
+
+func bip69OutputLess(a, b *wire.TxOut) bool {
+	if a.Value != b.Value {
+		return a.Value < b.Value
+	}
+	return bytes.Compare(a.PkScript, b.PkScript) < 0
+}
+
+// movePosition moves the entry at from to position to
+func movePosition(positions []int, from, to int) []int {
+	v := positions[from]
+	positions = append(positions[:from], positions[from+1:]...)
+	positions = append(positions[:to], append([]int{v}, positions[to:]...)...)
+	return positions
+}
+
+// applyLayout rearranges the tx and the psbt metadata to the layout, new
+// entries have to be appended and mapped before
+func (s *PsbtBuilder) applyLayout(layout *txLayout) {
+	p := s.PsbtUpdater.Upsbt
+	txIns := make([]*wire.TxIn, len(layout.inputs))
+	pIns := make([]psbt.PInput, len(layout.inputs))
+	for i, old := range layout.inputs {
+		txIns[i] = p.UnsignedTx.TxIn[old]
+		pIns[i] = p.Inputs[old]
+	}
+	txOuts := make([]*wire.TxOut, len(layout.outputs))
+	pOuts := make([]psbt.POutput, len(layout.outputs))
+	for i, old := range layout.outputs {
+		txOuts[i] = p.UnsignedTx.TxOut[old]
+		pOuts[i] = p.Outputs[old]
+	}
+	p.UnsignedTx.TxIn, p.Inputs = txIns, pIns
+	p.UnsignedTx.TxOut, p.Outputs = txOuts, pOuts
+}
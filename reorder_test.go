@@ -0,0 +1,135 @@
+package psbt_sdk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+)
+
+func TestPsbtBuilder_AddOutputKeepsMetadata(t *testing.T) {
+	builder := testBuilder(t, 1)
+	builder.PsbtUpdater.Upsbt.Outputs[0].RedeemScript = []byte{0x51}
+	if err := builder.AddOutput([]Output{{Script: "0014" + strings.Repeat("33", 20), Amount: 1000}}); err != nil {
+		t.Fatalf("AddOutput() error = %v", err)
+	}
+	outputs := builder.PsbtUpdater.Upsbt.Outputs
+	if len(outputs) != 2 || !bytes.Equal(outputs[0].RedeemScript, []byte{0x51}) {
+		t.Fatalf("AddOutput() outputs = %+v, want metadata of output 0 kept", outputs)
+	}
+}
+
+func TestPsbtBuilder_Reorder(t *testing.T) {
+	builder := testBuilder(t, 4)
+	if err := builder.AddOutput([]Output{
+		{Script: "0014" + strings.Repeat("33", 20), Amount: 1000},
+		{Script: "0014" + strings.Repeat("11", 20), Amount: 1000},
+	}); err != nil {
+		t.Fatalf("AddOutput() error = %v", err)
+	}
+	if err := builder.UpdateAndAddInputWitness(testSignIns(4)); err != nil {
+		t.Fatalf("UpdateAndAddInputWitness() error = %v", err)
+	}
+	for i := range builder.PsbtUpdater.Upsbt.Outputs {
+		builder.PsbtUpdater.Upsbt.Outputs[i].RedeemScript = []byte{byte(i)}
+	}
+
+	if err := builder.MoveInput(3, 0); err != nil {
+		t.Fatalf("MoveInput() error = %v", err)
+	}
+	if err := builder.RemoveInput(2); err != nil {
+		t.Fatalf("RemoveInput() error = %v", err)
+	}
+	if err := builder.RemoveOutput(9); !errors.Is(err, ErrOutputIndex) {
+		t.Fatalf("RemoveOutput(9) error = %v, want OutputIndexError", err)
+	}
+	if err := builder.SortBip69(); err != nil {
+		t.Fatalf("SortBip69() error = %v", err)
+	}
+
+	// inputs 0, 2 and 3 remain sorted by vout, each with its own utxo
+	for i, vout := range []uint32{0, 2, 3} {
+		txIn := builder.GetInputs()[i]
+		signIn := testSignIns(4)[vout]
+		if txIn.PreviousOutPoint.Index != vout ||
+			hex.EncodeToString(builder.PsbtUpdater.Upsbt.Inputs[i].WitnessUtxo.PkScript) != signIn.PkScript {
+			t.Fatalf("input %d = %v, want vout %d with its utxo", i, txIn.PreviousOutPoint, vout)
+		}
+	}
+	// outputs by amount then pkScript, metadata follows
+	for i, want := range []byte{2, 1, 0} {
+		if got := builder.PsbtUpdater.Upsbt.Outputs[i].RedeemScript; got[0] != want {
+			t.Fatalf("output %d metadata = %x, want %x", i, got, want)
+		}
+	}
+}
+
+func TestPsbtBuilder_ReorderSigned(t *testing.T) {
+	builder := testBuilder(t, 2)
+	if err := builder.AddOutput([]Output{
+		{Script: "0014" + strings.Repeat("33", 20), Amount: 1000},
+		{Script: "0014" + strings.Repeat("11", 20), Amount: 2000},
+	}); err != nil {
+		t.Fatalf("AddOutput() error = %v", err)
+	}
+	signIns := []*InputSign{
+		{UtxoType: Witness, Index: 0, PkScript: testP2wpkhScript(1), Amount: 40000,
+			SighashType: txscript.SigHashNone | txscript.SigHashAnyOneCanPay, PriHex: hex.EncodeToString(testPrivKey(1).Serialize())},
+		{UtxoType: Taproot, Index: 1, PkScript: testP2trScript(2), Amount: 30000,
+			SighashType: txscript.SigHashSingle | txscript.SigHashAnyOneCanPay, PriHex: hex.EncodeToString(testPrivKey(2).Serialize())},
+	}
+	if err := builder.UpdateAndAddInputWitness(signIns); err != nil {
+		t.Fatalf("UpdateAndAddInputWitness() error = %v", err)
+	}
+	if err := builder.UpdateAndSignInput(signIns); err != nil {
+		t.Fatalf("UpdateAndSignInput() error = %v", err)
+	}
+
+	// input 1 is paired with output 1
+	err := builder.MoveOutput(1, 0)
+	var invalidated *SignatureInvalidatedError
+	if !errors.As(err, &invalidated) || !errors.Is(err, ErrSignatureInvalidated) ||
+		len(invalidated.Signatures) != 1 || invalidated.Signatures[0].Index != 1 {
+		t.Fatalf("MoveOutput() error = %v, want signature of input 1 invalidated", err)
+	}
+	if err = builder.RemoveInput(0); !errors.Is(err, ErrSignatureInvalidated) {
+		t.Fatalf("RemoveInput(0) error = %v, want signature of input 1 invalidated", err)
+	}
+	if err = builder.RemoveOutput(2); err != nil {
+		t.Fatalf("RemoveOutput(2) error = %v", err)
+	}
+	if err = builder.AddInputOnly(Input{OutTxId: strings.Repeat("44", 32), OutIndex: 0}); err != nil {
+		t.Fatalf("AddInputOnly() error = %v", err)
+	}
+	if err = builder.RemoveInput(2); err != nil {
+		t.Fatalf("RemoveInput(2) error = %v", err)
+	}
+	results, _ := builder.Verify()
+	for _, r := range results {
+		if !r.Valid {
+			t.Fatalf("Verify() input %d = %v, want valid", r.Index, r.Err)
+		}
+	}
+
+	// SIGHASH_ALL commits to every input and output
+	builder = testBuilder(t, 2)
+	signIns = testSignIns(2)
+	if err = builder.UpdateAndAddInputWitness(signIns); err != nil {
+		t.Fatalf("UpdateAndAddInputWitness() error = %v", err)
+	}
+	if err = builder.UpdateAndSignInput(signIns[:1]); err != nil {
+		t.Fatalf("UpdateAndSignInput() error = %v", err)
+	}
+	if err = builder.MoveInput(1, 0); !errors.Is(err, ErrSignatureInvalidated) {
+		t.Fatalf("MoveInput() error = %v, want ErrSignatureInvalidated", err)
+	}
+	if err = builder.RemoveOutput(0); !errors.Is(err, ErrSignatureInvalidated) {
+		t.Fatalf("RemoveOutput() error = %v, want ErrSignatureInvalidated", err)
+	}
+	if err = builder.SortBip69(); err != nil {
+		t.Fatalf("SortBip69() of a sorted psbt error = %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package psbt_sdk
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// RuneSpacer is written between the letters of a spaced rune name, '.' is
+// read too
+const RuneSpacer = "•"
+
+// MaxRuneSpacers masks the spacers of a rune, one bit after each of the 28
+// letters but the last
+const MaxRuneSpacers = 0x07ffffff
+
+var (
+	u128Max     = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+	runeLetters = big.NewInt(26)
+)
+
+// RuneValue returns the number of a rune name, A is 0, Z 25, AA 26...
+func RuneValue(name string) (*big.Int, error) {
+	if name == "" {
+		return nil, errors.New("empty rune name")
+	}
+	value := new(big.Int)
+	for i, c := range name {
+		if c < 'A' || c > 'Z' {
+			return nil, errors.New(fmt.Sprintf("invalid rune name %s: character %q", name, c))
+		}
+		if i > 0 {
+			value.Add(value, big.NewInt(1))
+		}
+		value.Mul(value, runeLetters)
+		value.Add(value, big.NewInt(int64(c-'A')))
+		if value.Cmp(u128Max) > 0 {
+			return nil, errors.New(fmt.Sprintf("invalid rune name %s: above u128", name))
+		}
+	}
+	return value, nil
+}
+
+// RuneName returns the name of a rune number, the inverse of RuneValue
+func RuneName(value *big.Int) string {
+	var (
+		n       = new(big.Int).Add(value, big.NewInt(1))
+		letter  = new(big.Int)
+		letters = make([]byte, 0, 28)
+	)
+	for n.Sign() > 0 {
+		n.Sub(n, big.NewInt(1))
+		n.DivMod(n, runeLetters, letter)
+		letters = append(letters, 'A'+byte(letter.Int64()))
+	}
+	for i, j := 0, len(letters)-1; i < j; i, j = i+1, j-1 {
+		letters[i], letters[j] = letters[j], letters[i]
+	}
+	return string(letters)
+}
+
+// RuneCommitment is the little endian number of a rune name without
+// trailing zeros, the push an etching commit script must contain
+func RuneCommitment(name string) ([]byte, error) {
+	value, err := RuneValue(name)
+	if err != nil {
+		return nil, err
+	}
+	commitment := value.Bytes()
+	for i, j := 0, len(commitment)-1; i < j; i, j = i+1, j-1 {
+		commitment[i], commitment[j] = commitment[j], commitment[i]
+	}
+	return commitment, nil
+}
+
+func decodeRuneCommitment(commitment []byte) *big.Int {
+	value := make([]byte, len(commitment))
+	for i, b := range commitment {
+		value[len(commitment)-1-i] = b
+	}
+	return new(big.Int).SetBytes(value)
+}
+
+// SpacedRune is a rune name with spacers, bit i of Spacers puts a spacer
+// after letter i
+type SpacedRune struct {
+	Rune    string `json:"rune"`
+	Spacers uint32 `json:"spacers"`
+}
+
+// ParseSpacedRune parses a name as UNCOMMON•GOODS or UNCOMMON.GOODS
+func ParseSpacedRune(s string) (*SpacedRune, error) {
+	var (
+		name    strings.Builder
+		spacers uint32
+		letters int
+	)
+	for _, c := range s {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			name.WriteRune(c)
+			letters++
+		case c == '.' || string(c) == RuneSpacer:
+			if letters == 0 {
+				return nil, errors.New(fmt.Sprintf("invalid spaced rune %s: leading spacer", s))
+			}
+			if letters > 32 {
+				return nil, errors.New(fmt.Sprintf("invalid spaced rune %s: too long", s))
+			}
+			flag := uint32(1) << (letters - 1)
+			if spacers&flag != 0 {
+				return nil, errors.New(fmt.Sprintf("invalid spaced rune %s: double spacer", s))
+			}
+			spacers |= flag
+		default:
+			return nil, errors.New(fmt.Sprintf("invalid spaced rune %s: character %q", s, c))
+		}
+	}
+	if _, err := RuneValue(name.String()); err != nil {
+		return nil, err
+	}
+	if 32-bits.LeadingZeros32(spacers) >= letters {
+		return nil, errors.New(fmt.Sprintf("invalid spaced rune %s: trailing spacer", s))
+	}
+	return &SpacedRune{Rune: name.String(), Spacers: spacers}, nil
+}
+
+func (r *SpacedRune) String() string {
+	var s strings.Builder
+	for i, c := range r.Rune {
+		s.WriteRune(c)
+		if i < len(r.Rune)-1 && r.Spacers&(uint32(1)<<i) != 0 {
+			s.WriteString(RuneSpacer)
+		}
+	}
+	return s.String()
+}
+
+// RuneId is the etching of a rune, its block height and tx index. 0:0 is
+// the rune etched in the runestone itself.
+type RuneId struct {
+	Block uint64 `json:"block"`
+	Tx    uint32 `json:"tx"`
+}
+
+// ParseRuneId parses <block>:<tx>
+func ParseRuneId(id string) (*RuneId, error) {
+	sep := strings.IndexByte(id, ':')
+	if sep < 0 {
+		return nil, errors.New(fmt.Sprintf("invalid rune id %s", id))
+	}
+	block, err := strconv.ParseUint(id[:sep], 10, 64)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid rune id %s: %v", id, err))
+	}
+	tx, err := strconv.ParseUint(id[sep+1:], 10, 32)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid rune id %s: %v", id, err))
+	}
+	if block == 0 && tx > 0 {
+		return nil, errors.New(fmt.Sprintf("invalid rune id %s: tx without block", id))
+	}
+	return &RuneId{Block: block, Tx: uint32(tx)}, nil
+}
+
+func (r RuneId) String() string {
+	return fmt.Sprintf("%d:%d", r.Block, r.Tx)
+}
+
+func (r RuneId) less(o RuneId) bool {
+	return r.Block < o.Block || (r.Block == o.Block && r.Tx < o.Tx)
+}
+
+// next applies an edict delta, the tx is relative when the block does not
+// change
+func (r RuneId) next(block, tx *big.Int) (RuneId, bool) {
+	if !block.IsUint64() || !tx.IsUint64() || tx.Uint64() > 0xffffffff {
+		return RuneId{}, false
+	}
+	next := RuneId{Block: r.Block + block.Uint64(), Tx: uint32(tx.Uint64())}
+	if next.Block < r.Block {
+		return RuneId{}, false
+	}
+	if block.Sign() == 0 {
+		next.Tx = r.Tx + uint32(tx.Uint64())
+		if next.Tx < r.Tx {
+			return RuneId{}, false
+		}
+	}
+	if next.Block == 0 && next.Tx > 0 {
+		return RuneId{}, false
+	}
+	return next, true
+}
+
+// appendVarint appends n as LEB128
+func appendVarint(b []byte, n *big.Int) []byte {
+	n = new(big.Int).Set(n)
+	for n.BitLen() > 7 {
+		b = append(b, byte(n.Bits()[0]&0x7f)|0x80)
+		n.Rsh(n, 7)
+	}
+	return append(b, byte(n.Uint64()))
+}
+
+// decodeVarint reads a LEB128 u128, it returns the value and its length
+func decodeVarint(b []byte) (*big.Int, int, error) {
+	n := new(big.Int)
+	for i, c := range b {
+		if i > 18 {
+			return nil, 0, errors.New("overlong varint")
+		}
+		value := uint64(c & 0x7f)
+		if i == 18 && value&0x7c != 0 {
+			return nil, 0, errors.New("varint above u128")
+		}
+		n.Or(n, new(big.Int).Lsh(new(big.Int).SetUint64(value), uint(7*i)))
+		if c&0x80 == 0 {
+			return n, i + 1, nil
+		}
+	}
+	return nil, 0, errors.New("unterminated varint")
+}
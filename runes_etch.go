@@ -0,0 +1,76 @@
+package psbt_sdk
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// RuneCommitConfirmations is how deep the commit output must be before the
+// reveal etches its rune. The reveal input carries it as a relative lock
+// time so it can't be mined earlier.
+const RuneCommitConfirmations = 6
+
+// firstReservedRune is AAAAAAAAAAAAAAAAAAAAAAAAAAA, names from it on are
+// assigned to etchings without a name and can't be etched
+var firstReservedRune, _ = RuneValue("AAAAAAAAAAAAAAAAAAAAAAAAAAA")
+
+// RuneCommitScript is the tapscript committing to the etching of a rune
+// without inscription, a checksig of the reveal key followed by the
+// commitment in an OP_FALSE OP_IF envelope
+func RuneCommitScript(revealKey *btcec.PublicKey, name string) ([]byte, error) {
+	commitment, err := RuneCommitment(name)
+	if err != nil {
+		return nil, err
+	}
+	script := appendPush(nil, schnorr.SerializePubKey(revealKey))
+	script = append(script, txscript.OP_CHECKSIG, txscript.OP_FALSE, txscript.OP_IF)
+	script = appendPush(script, commitment)
+	return append(script, txscript.OP_ENDIF), nil
+}
+
+// checkEtching checks the etching can be committed to, it needs a name
+// outside the reserved range
+func (in *Inscribe) checkEtching() error {
+	if in.Etching.Rune == "" {
+		return errors.New("etching without rune name")
+	}
+	value, err := RuneValue(in.Etching.Rune)
+	if err != nil {
+		return err
+	}
+	if value.Cmp(firstReservedRune) >= 0 {
+		return errors.New(fmt.Sprintf("rune %s is reserved", in.Etching.Rune))
+	}
+	return nil
+}
+
+// etchingOutputs are the premine output to Destination, when there is a
+// premine, and the runestone allocating it, they follow the outputs at
+// indexes below first
+func (in *Inscribe) etchingOutputs(first int) ([]Output, error) {
+	var (
+		outs    = make([]Output, 0, 2)
+		pointer *uint32
+	)
+	if in.Etching.Premine != nil && in.Etching.Premine.Sign() > 0 {
+		if in.Destination == "" {
+			return nil, errors.New(fmt.Sprintf("premine of rune %s without destination", in.Etching.Rune))
+		}
+		index := uint32(first)
+		pointer = &index
+		outs = append(outs, Output{Address: in.Destination, Amount: in.postage()})
+	}
+	script, err := (&Runestone{Etching: in.Etching, Pointer: pointer}).Script()
+	if err != nil {
+		return nil, err
+	}
+	if limit := DefaultPolicyOptions().MaxDataCarrierSize; len(script) > limit {
+		return nil, errors.New(fmt.Sprintf("runestone of %d bytes, op_return limit %d", len(script), limit))
+	}
+	return append(outs, Output{Script: hex.EncodeToString(script)}), nil
+}
@@ -0,0 +1,135 @@
+package psbt_sdk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func testEtching() *Etching {
+	var (
+		divisibility = uint8(2)
+		spacers      = uint32(1 << 7)
+		symbol       = '¢'
+		offset       = uint64(4320)
+	)
+	return &Etching{
+		Divisibility: &divisibility,
+		Premine:      big.NewInt(1000000),
+		Rune:         "UNCOMMONGOODS",
+		Spacers:      &spacers,
+		Symbol:       &symbol,
+		Terms:        &Terms{Amount: big.NewInt(100), Cap: big.NewInt(10000), OffsetEnd: &offset},
+	}
+}
+
+func TestRuneCommitScript(t *testing.T) {
+	commitment, err := RuneCommitment("UNCOMMONGOODS")
+	if err != nil {
+		t.Fatalf("RuneCommitment() error = %v", err)
+	}
+	value, _ := RuneValue("UNCOMMONGOODS")
+	if len(commitment) == 0 || commitment[len(commitment)-1] == 0 || decodeRuneCommitment(commitment).Cmp(value) != 0 {
+		t.Fatalf("RuneCommitment() = %x", commitment)
+	}
+	script, err := RuneCommitScript(testPrivKey(4).PubKey(), "UNCOMMONGOODS")
+	if err != nil {
+		t.Fatalf("RuneCommitScript() error = %v", err)
+	}
+	if !bytes.Contains(script, appendPush(nil, commitment)) || script[33] != txscript.OP_CHECKSIG {
+		t.Fatalf("RuneCommitScript() = %x", script)
+	}
+	if commitment, _ = RuneCommitment("A"); len(commitment) != 0 {
+		t.Fatalf("RuneCommitment(A) = %x, want empty", commitment)
+	}
+}
+
+func TestCreateInscriptionRevealPsbt_Etching(t *testing.T) {
+	for _, inscription := range []*Inscription{nil, {ContentType: "text/plain", Body: []byte("uncommon goods")}} {
+		inscribe := &Inscribe{
+			Inscription: inscription,
+			Etching:     testEtching(),
+			RevealKey:   hex.EncodeToString(testPrivKey(4).Serialize()),
+			Destination: testP2trAddress(t, 3),
+			Payments: []*Utxo{{Input: Input{OutTxId: "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b", OutIndex: 5},
+				UtxoType: Taproot, PkScript: testP2trScript(3), Amount: 300000}},
+			ChangeAddress: testP2trAddress(t, 3),
+			FeeRate:       5,
+		}
+		commit, err := inscribe.Commit(&chaincfg.SigNetParams)
+		if err != nil {
+			t.Fatalf("Commit() error = %v", err)
+		}
+		commitment, _ := RuneCommitment("UNCOMMONGOODS")
+		if !bytes.Contains(commit.Script, appendPush(nil, commitment)) {
+			t.Fatalf("commit script %x lacks the rune commitment", commit.Script)
+		}
+		reveal, err := CreateInscriptionRevealPsbt(&chaincfg.SigNetParams, inscribe,
+			"93b3484083c31769c6a82b9c1cb66979463d86b3edea5044dede1ffdb74c3db2")
+		if err != nil {
+			t.Fatalf("CreateInscriptionRevealPsbt() error = %v", err)
+		}
+
+		// the inscription output, then the premine output and the runestone
+		outputs, premine := reveal.GetOutputs(), 0
+		if inscription != nil {
+			premine = 1
+		}
+		if len(outputs) != premine+2 || outputs[premine].Value != int64(DefaultPostage) || outputs[premine].PkScript == nil ||
+			outputs[premine+1].Value != 0 {
+			t.Fatalf("reveal outputs = %v", outputs)
+		}
+		runestone := reveal.DecodeRunestone()
+		if runestone == nil || runestone.IsCenotaph() || runestone.Pointer == nil || int(*runestone.Pointer) != premine ||
+			!reflect.DeepEqual(runestone.Etching, inscribe.Etching) {
+			t.Fatalf("DecodeRunestone() = %+v", runestone)
+		}
+		if sequence := reveal.GetInputs()[0].Sequence; sequence != RuneCommitConfirmations-1 {
+			t.Fatalf("reveal sequence = %d, want %d", sequence, RuneCommitConfirmations-1)
+		}
+		envelopes, _ := reveal.DecodeEnvelopes()
+		if inscription != nil && (len(envelopes) != 1 || envelopes[0].Inscription.Rune != "UNCOMMONGOODS") {
+			t.Fatalf("DecodeEnvelopes() = %+v, want the rune tag", envelopes)
+		}
+		if inscription == nil && len(envelopes) != 0 {
+			t.Fatalf("DecodeEnvelopes() = %+v, want none", envelopes)
+		}
+
+		revealHex, err := reveal.ExtractPsbtTransaction()
+		if err != nil {
+			t.Fatalf("ExtractPsbtTransaction() error = %v", err)
+		}
+		revealTx := wire.NewMsgTx(2)
+		_ = revealTx.Deserialize(hex.NewDecoder(strings.NewReader(revealHex)))
+		if fee := btcutil.Amount(txVSize(revealTx) * 5); fee != commit.RevealFee ||
+			commit.Amount != commit.RevealFee+btcutil.Amount(outputs[premine].Value)*btcutil.Amount(premine+1) {
+			t.Fatalf("reveal fee = %d, commit amount = %d, want fee %d", fee, commit.Amount, commit.RevealFee)
+		}
+	}
+}
+
+func TestInscribe_EtchingErrors(t *testing.T) {
+	for name, etching := range map[string]*Etching{
+		"no name":       {Premine: big.NewInt(1)},
+		"reserved name": {Rune: "AAAAAAAAAAAAAAAAAAAAAAAAAAA"},
+		"supply":        {Rune: "UNCOMMONGOODS", Premine: u128Max, Terms: &Terms{Amount: big.NewInt(1), Cap: big.NewInt(1)}},
+	} {
+		inscribe := &Inscribe{Etching: etching, RevealKey: hex.EncodeToString(testPrivKey(4).Serialize()),
+			Destination: testP2trAddress(t, 3), FeeRate: 5}
+		if _, err := inscribe.Commit(&chaincfg.SigNetParams); err == nil {
+			t.Fatalf("%s: Commit() error = nil", name)
+		}
+	}
+	inscribe := &Inscribe{Etching: testEtching(), RevealKey: hex.EncodeToString(testPrivKey(4).Serialize()), FeeRate: 5}
+	if _, err := inscribe.Commit(&chaincfg.SigNetParams); err == nil {
+		t.Fatalf("Commit() of a premine without destination error = nil")
+	}
+}
@@ -0,0 +1,498 @@
+package psbt_sdk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// RunestoneMagic follows OP_RETURN in the output carrying a runestone
+const RunestoneMagic = txscript.OP_13
+
+// MaxRuneDivisibility is the most decimals a rune can have
+const MaxRuneDivisibility = 38
+
+// runestone tags, odd tags unknown to a decoder are ignored, even ones make
+// a cenotaph. The edicts follow runeTagBody.
+const (
+	runeTagBody         = 0
+	runeTagDivisibility = 1
+	runeTagFlags        = 2
+	runeTagSpacers      = 3
+	runeTagRune         = 4
+	runeTagSymbol       = 5
+	runeTagPremine      = 6
+	runeTagCap          = 8
+	runeTagAmount       = 10
+	runeTagHeightStart  = 12
+	runeTagHeightEnd    = 14
+	runeTagOffsetStart  = 16
+	runeTagOffsetEnd    = 18
+	runeTagMint         = 20
+	runeTagPointer      = 22
+)
+
+// runestone flag bits
+const (
+	runeFlagEtching = 0
+	runeFlagTerms   = 1
+	runeFlagTurbo   = 2
+)
+
+// RunestoneFlaw is why a runestone is a cenotaph
+type RunestoneFlaw string
+
+const (
+	FlawEdictOutput         RunestoneFlaw = "edict_output"
+	FlawEdictRuneId         RunestoneFlaw = "edict_rune_id"
+	FlawInvalidScript       RunestoneFlaw = "invalid_script"
+	FlawOpcode              RunestoneFlaw = "opcode"
+	FlawSupplyOverflow      RunestoneFlaw = "supply_overflow"
+	FlawTrailingIntegers    RunestoneFlaw = "trailing_integers"
+	FlawTruncatedField      RunestoneFlaw = "truncated_field"
+	FlawUnrecognizedEvenTag RunestoneFlaw = "unrecognized_even_tag"
+	FlawUnrecognizedFlag    RunestoneFlaw = "unrecognized_flag"
+	FlawVarint              RunestoneFlaw = "varint"
+)
+
+// Runestone is the runes message of a tx. A runestone with a Flaw is a
+// cenotaph: it burns the runes of the inputs and keeps only the etched
+// Rune and the Mint.
+type Runestone struct {
+	Edicts  []*Edict      `json:"edicts"`
+	Etching *Etching      `json:"etching"`
+	Mint    *RuneId       `json:"mint"`
+	Pointer *uint32       `json:"pointer"`
+	Flaw    RunestoneFlaw `json:"flaw"`
+}
+
+// Etching creates a rune. Rune is its name without spacers, a reserved name
+// is assigned when empty. Premine and the Terms amounts are u128.
+type Etching struct {
+	Divisibility *uint8   `json:"divisibility"`
+	Premine      *big.Int `json:"premine"`
+	Rune         string   `json:"rune"`
+	Spacers      *uint32  `json:"spacers"`
+	Symbol       *rune    `json:"symbol"`
+	Terms        *Terms   `json:"terms"`
+	Turbo        bool     `json:"turbo"`
+}
+
+// Terms are the open mint terms of an etching, Cap mints of Amount each
+// within the absolute Height and the Offset relative to the etching block
+type Terms struct {
+	Amount      *big.Int `json:"amount"`
+	Cap         *big.Int `json:"cap"`
+	HeightStart *uint64  `json:"height_start"`
+	HeightEnd   *uint64  `json:"height_end"`
+	OffsetStart *uint64  `json:"offset_start"`
+	OffsetEnd   *uint64  `json:"offset_end"`
+}
+
+// Edict transfers Amount of rune Id to Output, an Output equal to the
+// output count splits it among the outputs but OP_RETURNs
+type Edict struct {
+	Id     RuneId   `json:"id"`
+	Amount *big.Int `json:"amount"`
+	Output uint32   `json:"output"`
+}
+
+// IsCenotaph reports whether the runestone is malformed
+func (r *Runestone) IsCenotaph() bool {
+	return r.Flaw != ""
+}
+
+// Script encodes the runestone into OP_RETURN OP_13 <payload>..., the
+// payload split into 520 byte pushes
+func (r *Runestone) Script() ([]byte, error) {
+	if r.IsCenotaph() {
+		return nil, errors.New(fmt.Sprintf("runestone is a cenotaph: %s", r.Flaw))
+	}
+	var (
+		payload []byte
+		err     error
+	)
+	if etching := r.Etching; etching != nil {
+		flags := uint64(1) << runeFlagEtching
+		if etching.Terms != nil {
+			flags |= 1 << runeFlagTerms
+		}
+		if etching.Turbo {
+			flags |= 1 << runeFlagTurbo
+		}
+		payload = appendRuneTag(payload, runeTagFlags, new(big.Int).SetUint64(flags))
+		if etching.Rune != "" {
+			value, err := RuneValue(etching.Rune)
+			if err != nil {
+				return nil, err
+			}
+			payload = appendRuneTag(payload, runeTagRune, value)
+		}
+		if etching.Divisibility != nil {
+			if *etching.Divisibility > MaxRuneDivisibility {
+				return nil, errors.New(fmt.Sprintf("rune divisibility %d above %d", *etching.Divisibility, MaxRuneDivisibility))
+			}
+			payload = appendRuneTag(payload, runeTagDivisibility, big.NewInt(int64(*etching.Divisibility)))
+		}
+		if etching.Spacers != nil {
+			if *etching.Spacers > MaxRuneSpacers {
+				return nil, errors.New(fmt.Sprintf("rune spacers %d above %d", *etching.Spacers, MaxRuneSpacers))
+			}
+			payload = appendRuneTag(payload, runeTagSpacers, new(big.Int).SetUint64(uint64(*etching.Spacers)))
+		}
+		if etching.Symbol != nil {
+			if !utf8.ValidRune(*etching.Symbol) {
+				return nil, errors.New(fmt.Sprintf("invalid rune symbol %d", *etching.Symbol))
+			}
+			payload = appendRuneTag(payload, runeTagSymbol, big.NewInt(int64(*etching.Symbol)))
+		}
+		if payload, err = appendRuneAmount(payload, runeTagPremine, etching.Premine); err != nil {
+			return nil, err
+		}
+		if terms := etching.Terms; terms != nil {
+			if payload, err = appendRuneAmount(payload, runeTagAmount, terms.Amount); err != nil {
+				return nil, err
+			}
+			if payload, err = appendRuneAmount(payload, runeTagCap, terms.Cap); err != nil {
+				return nil, err
+			}
+			payload = appendRuneUint64(payload, runeTagHeightStart, terms.HeightStart)
+			payload = appendRuneUint64(payload, runeTagHeightEnd, terms.HeightEnd)
+			payload = appendRuneUint64(payload, runeTagOffsetStart, terms.OffsetStart)
+			payload = appendRuneUint64(payload, runeTagOffsetEnd, terms.OffsetEnd)
+		}
+		if etching.supply() == nil {
+			return nil, errors.New("rune supply above u128")
+		}
+	}
+	if r.Mint != nil {
+		payload = appendRuneTag(payload, runeTagMint, new(big.Int).SetUint64(r.Mint.Block))
+		payload = appendRuneTag(payload, runeTagMint, new(big.Int).SetUint64(uint64(r.Mint.Tx)))
+	}
+	if r.Pointer != nil {
+		payload = appendRuneTag(payload, runeTagPointer, new(big.Int).SetUint64(uint64(*r.Pointer)))
+	}
+
+	if len(r.Edicts) > 0 {
+		payload = appendVarint(payload, big.NewInt(runeTagBody))
+		// edicts are delta encoded, sorted by rune id
+		edicts := make([]*Edict, len(r.Edicts))
+		copy(edicts, r.Edicts)
+		sort.SliceStable(edicts, func(i, j int) bool { return edicts[i].Id.less(edicts[j].Id) })
+		var previous RuneId
+		for _, edict := range edicts {
+			if edict.Amount == nil || edict.Amount.Sign() < 0 || edict.Amount.Cmp(u128Max) > 0 {
+				return nil, errors.New(fmt.Sprintf("edict %s amount %v out of u128", edict.Id, edict.Amount))
+			}
+			block, tx := edict.Id.Block-previous.Block, uint64(edict.Id.Tx)
+			if block == 0 {
+				tx -= uint64(previous.Tx)
+			}
+			payload = appendVarint(payload, new(big.Int).SetUint64(block))
+			payload = appendVarint(payload, new(big.Int).SetUint64(tx))
+			payload = appendVarint(payload, edict.Amount)
+			payload = appendVarint(payload, new(big.Int).SetUint64(uint64(edict.Output)))
+			previous = edict.Id
+		}
+	}
+
+	script := []byte{txscript.OP_RETURN, RunestoneMagic}
+	for _, chunk := range splitPushes(payload) {
+		script = appendPush(script, chunk)
+	}
+	return script, nil
+}
+
+// Output is a zero value output carrying the runestone, for
+// CreatePsbtBuilder and AddOutput
+func (r *Runestone) Output() (Output, error) {
+	script, err := r.Script()
+	if err != nil {
+		return Output{}, err
+	}
+	return Output{Script: hex.EncodeToString(script)}, nil
+}
+
+// supply is the premine plus cap times amount, nil above u128
+func (e *Etching) supply() *big.Int {
+	supply := new(big.Int)
+	if e.Premine != nil {
+		supply.Set(e.Premine)
+	}
+	if e.Terms != nil && e.Terms.Cap != nil && e.Terms.Amount != nil {
+		supply.Add(supply, new(big.Int).Mul(e.Terms.Cap, e.Terms.Amount))
+	}
+	if supply.Cmp(u128Max) > 0 {
+		return nil
+	}
+	return supply
+}
+
+func appendRuneTag(payload []byte, tag int64, value *big.Int) []byte {
+	payload = appendVarint(payload, big.NewInt(tag))
+	return appendVarint(payload, value)
+}
+
+func appendRuneAmount(payload []byte, tag int64, value *big.Int) ([]byte, error) {
+	if value == nil {
+		return payload, nil
+	}
+	if value.Sign() < 0 || value.Cmp(u128Max) > 0 {
+		return nil, errors.New(fmt.Sprintf("runestone tag %d value %v out of u128", tag, value))
+	}
+	return appendRuneTag(payload, tag, value), nil
+}
+
+func appendRuneUint64(payload []byte, tag int64, value *uint64) []byte {
+	if value == nil {
+		return payload
+	}
+	return appendRuneTag(payload, tag, new(big.Int).SetUint64(*value))
+}
+
+// DecodeTxRunestone decodes the runestone of a raw transaction, nil when it
+// has none
+func DecodeTxRunestone(txHex string) (*Runestone, error) {
+	raw, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, err
+	}
+	tx := wire.NewMsgTx(2)
+	if err = tx.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, err
+	}
+	return DecodeRunestone(tx), nil
+}
+
+// DecodeRunestone decodes the runestone of the psbt outputs, nil when it has
+// none
+func (s *PsbtBuilder) DecodeRunestone() *Runestone {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return DecodeRunestone(s.PsbtUpdater.Upsbt.UnsignedTx)
+}
+
+// DecodeRunestone decodes the runestone of the first OP_RETURN OP_13 output
+// as ord does, nil when the tx has none. Malformed runestones are returned
+// as cenotaphs.
+func DecodeRunestone(tx *wire.MsgTx) *Runestone {
+	payload, flaw, ok := runestonePayload(tx)
+	if !ok {
+		return nil
+	}
+	if flaw != "" {
+		return &Runestone{Flaw: flaw}
+	}
+	var integers []*big.Int
+	for len(payload) > 0 {
+		n, size, err := decodeVarint(payload)
+		if err != nil {
+			return &Runestone{Flaw: FlawVarint}
+		}
+		integers = append(integers, n)
+		payload = payload[size:]
+	}
+
+	runestone := &Runestone{}
+	fields, unknownEven := make(runeFields), false
+	for i := 0; i < len(integers); i += 2 {
+		tag := integers[i]
+		if tag.Sign() == 0 {
+			runestone.Edicts, flaw = decodeEdicts(integers[i+1:], len(tx.TxOut))
+			break
+		}
+		if i+1 >= len(integers) {
+			flaw = FlawTruncatedField
+			break
+		}
+		// no tag this large is known, it is only checked for parity
+		if !tag.IsUint64() {
+			unknownEven = unknownEven || tag.Bit(0) == 0
+			continue
+		}
+		fields[tag.Uint64()] = append(fields[tag.Uint64()], integers[i+1])
+	}
+
+	flags := new(big.Int)
+	fields.take(runeTagFlags, 1, func(v []*big.Int) bool { flags.Set(v[0]); return true })
+	if takeRuneFlag(flags, runeFlagEtching) {
+		runestone.Etching = fields.etching(flags)
+	}
+	fields.take(runeTagMint, 2, func(v []*big.Int) bool {
+		if !v[0].IsUint64() || !v[1].IsUint64() || v[1].Uint64() > 0xffffffff || (v[0].Sign() == 0 && v[1].Sign() > 0) {
+			return false
+		}
+		runestone.Mint = &RuneId{Block: v[0].Uint64(), Tx: uint32(v[1].Uint64())}
+		return true
+	})
+	fields.take(runeTagPointer, 1, func(v []*big.Int) bool {
+		if !v[0].IsUint64() || v[0].Uint64() >= uint64(len(tx.TxOut)) {
+			return false
+		}
+		pointer := uint32(v[0].Uint64())
+		runestone.Pointer = &pointer
+		return true
+	})
+
+	if flaw == "" && runestone.Etching != nil && runestone.Etching.supply() == nil {
+		flaw = FlawSupplyOverflow
+	}
+	if flaw == "" && flags.Sign() != 0 {
+		flaw = FlawUnrecognizedFlag
+	}
+	if flaw == "" && (unknownEven || fields.hasEven()) {
+		flaw = FlawUnrecognizedEvenTag
+	}
+	if flaw != "" {
+		cenotaph := &Runestone{Mint: runestone.Mint, Flaw: flaw}
+		if runestone.Etching != nil && runestone.Etching.Rune != "" {
+			cenotaph.Etching = &Etching{Rune: runestone.Etching.Rune}
+		}
+		return cenotaph
+	}
+	return runestone
+}
+
+// runestonePayload concatenates the pushes after OP_RETURN OP_13, ok is
+// false when no output starts with them
+func runestonePayload(tx *wire.MsgTx) ([]byte, RunestoneFlaw, bool) {
+	for _, txOut := range tx.TxOut {
+		tokenizer := txscript.MakeScriptTokenizer(0, txOut.PkScript)
+		if !tokenizer.Next() || tokenizer.Opcode() != txscript.OP_RETURN ||
+			!tokenizer.Next() || tokenizer.Opcode() != RunestoneMagic {
+			continue
+		}
+		payload := make([]byte, 0)
+		for tokenizer.Next() {
+			if tokenizer.Opcode() > txscript.OP_PUSHDATA4 {
+				return nil, FlawOpcode, true
+			}
+			payload = append(payload, tokenizer.Data()...)
+		}
+		if tokenizer.Err() != nil {
+			return nil, FlawInvalidScript, true
+		}
+		return payload, "", true
+	}
+	return nil, "", false
+}
+
+// decodeEdicts reads the delta encoded edicts after the body tag
+func decodeEdicts(integers []*big.Int, outputs int) ([]*Edict, RunestoneFlaw) {
+	var (
+		edicts []*Edict
+		id     RuneId
+	)
+	for ; len(integers) > 0; integers = integers[4:] {
+		if len(integers) < 4 {
+			return edicts, FlawTrailingIntegers
+		}
+		next, ok := id.next(integers[0], integers[1])
+		if !ok {
+			return edicts, FlawEdictRuneId
+		}
+		output := integers[3]
+		if !output.IsUint64() || output.Uint64() > uint64(outputs) {
+			return edicts, FlawEdictOutput
+		}
+		id = next
+		edicts = append(edicts, &Edict{Id: id, Amount: integers[2], Output: uint32(output.Uint64())})
+	}
+	return edicts, ""
+}
+
+// runeFields are the tag values of a runestone before the body
+type runeFields map[uint64][]*big.Int
+
+// take consumes the first n values of tag when valid accepts them, values
+// left behind make an even tag unrecognized
+func (f runeFields) take(tag uint64, n int, valid func(v []*big.Int) bool) {
+	values := f[tag]
+	if len(values) < n || !valid(values[:n]) {
+		return
+	}
+	if len(values) == n {
+		delete(f, tag)
+		return
+	}
+	f[tag] = values[n:]
+}
+
+func (f runeFields) hasEven() bool {
+	for tag := range f {
+		if tag%2 == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// etching takes the etching fields, and the terms when flagged
+func (f runeFields) etching(flags *big.Int) *Etching {
+	etching := &Etching{}
+	f.take(runeTagDivisibility, 1, func(v []*big.Int) bool {
+		if !v[0].IsUint64() || v[0].Uint64() > MaxRuneDivisibility {
+			return false
+		}
+		divisibility := uint8(v[0].Uint64())
+		etching.Divisibility = &divisibility
+		return true
+	})
+	f.take(runeTagPremine, 1, func(v []*big.Int) bool { etching.Premine = v[0]; return true })
+	f.take(runeTagRune, 1, func(v []*big.Int) bool { etching.Rune = RuneName(v[0]); return true })
+	f.take(runeTagSpacers, 1, func(v []*big.Int) bool {
+		if !v[0].IsUint64() || v[0].Uint64() > MaxRuneSpacers {
+			return false
+		}
+		spacers := uint32(v[0].Uint64())
+		etching.Spacers = &spacers
+		return true
+	})
+	f.take(runeTagSymbol, 1, func(v []*big.Int) bool {
+		if !v[0].IsUint64() || v[0].Uint64() > utf8.MaxRune || !utf8.ValidRune(rune(v[0].Uint64())) {
+			return false
+		}
+		symbol := rune(v[0].Uint64())
+		etching.Symbol = &symbol
+		return true
+	})
+	if takeRuneFlag(flags, runeFlagTerms) {
+		terms := &Terms{}
+		f.take(runeTagCap, 1, func(v []*big.Int) bool { terms.Cap = v[0]; return true })
+		f.take(runeTagHeightStart, 1, takeUint64(&terms.HeightStart))
+		f.take(runeTagHeightEnd, 1, takeUint64(&terms.HeightEnd))
+		f.take(runeTagAmount, 1, func(v []*big.Int) bool { terms.Amount = v[0]; return true })
+		f.take(runeTagOffsetStart, 1, takeUint64(&terms.OffsetStart))
+		f.take(runeTagOffsetEnd, 1, takeUint64(&terms.OffsetEnd))
+		etching.Terms = terms
+	}
+	etching.Turbo = takeRuneFlag(flags, runeFlagTurbo)
+	return etching
+}
+
+func takeUint64(dst **uint64) func(v []*big.Int) bool {
+	return func(v []*big.Int) bool {
+		if !v[0].IsUint64() {
+			return false
+		}
+		value := v[0].Uint64()
+		*dst = &value
+		return true
+	}
+}
+
+// takeRuneFlag reports and clears a flag bit
+func takeRuneFlag(flags *big.Int, bit int) bool {
+	if flags.Bit(bit) == 0 {
+		return false
+	}
+	flags.SetBit(flags, bit, 0)
+	return true
+}
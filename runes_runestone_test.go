@@ -0,0 +1,172 @@
+package psbt_sdk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testRunestoneTx is a tx with the runestone of the integers at output 0 and
+// two more outputs
+func testRunestoneTx(integers ...uint64) *wire.MsgTx {
+	var payload []byte
+	for _, n := range integers {
+		payload = appendVarint(payload, new(big.Int).SetUint64(n))
+	}
+	script := appendPush([]byte{txscript.OP_RETURN, RunestoneMagic}, payload)
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(0, script))
+	tx.AddTxOut(wire.NewTxOut(546, []byte{txscript.OP_TRUE}))
+	tx.AddTxOut(wire.NewTxOut(546, []byte{txscript.OP_TRUE}))
+	return tx
+}
+
+func TestRunestone_Script(t *testing.T) {
+	var (
+		divisibility = uint8(2)
+		spacers      = uint32(1 << 7)
+		symbol       = '¢'
+		height       = uint64(840000)
+		offset       = uint64(1000)
+		pointer      = uint32(1)
+	)
+	runestone := &Runestone{
+		Etching: &Etching{
+			Divisibility: &divisibility,
+			Premine:      big.NewInt(1000000),
+			Rune:         "UNCOMMONGOODS",
+			Spacers:      &spacers,
+			Symbol:       &symbol,
+			Terms: &Terms{Amount: big.NewInt(100), Cap: new(big.Int).Lsh(big.NewInt(1), 100),
+				HeightStart: &height, OffsetEnd: &offset},
+			Turbo: true,
+		},
+		Mint:    &RuneId{Block: 840000, Tx: 1},
+		Pointer: &pointer,
+		Edicts: []*Edict{
+			{Id: RuneId{Block: 840001, Tx: 2}, Amount: big.NewInt(7), Output: 2},
+			{Id: RuneId{Block: 840000, Tx: 9}, Amount: big.NewInt(5), Output: 1},
+			{Id: RuneId{Block: 840000, Tx: 3}, Amount: u128Max, Output: 3},
+		},
+	}
+	output, err := runestone.Output()
+	if err != nil {
+		t.Fatalf("Output() error = %v", err)
+	}
+	builder, err := CreatePsbtBuilder(&chaincfg.SigNetParams,
+		[]Input{{OutTxId: "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b", OutIndex: 0}},
+		[]Output{{Script: testP2wpkhScript(2), Amount: 1000}, {Script: testP2wpkhScript(2), Amount: 1000}, output})
+	if err != nil {
+		t.Fatalf("CreatePsbtBuilder() error = %v", err)
+	}
+
+	decoded := builder.DecodeRunestone()
+	if decoded == nil || decoded.IsCenotaph() {
+		t.Fatalf("DecodeRunestone() = %+v", decoded)
+	}
+	// edicts come back sorted by rune id
+	want := *runestone
+	want.Edicts = []*Edict{runestone.Edicts[2], runestone.Edicts[1], runestone.Edicts[0]}
+	if !reflect.DeepEqual(decoded, &want) {
+		t.Fatalf("DecodeRunestone() = %+v, want %+v", decoded, &want)
+	}
+	if decoded.Etching.Terms.Cap.Cmp(runestone.Etching.Terms.Cap) != 0 {
+		t.Fatalf("Cap = %v", decoded.Etching.Terms.Cap)
+	}
+
+	script, _ := runestone.Script()
+	if script[0] != txscript.OP_RETURN || script[1] != txscript.OP_13 {
+		t.Fatalf("Script() = %x", script)
+	}
+	if (&Runestone{}).IsCenotaph() || DecodeRunestone(wire.NewMsgTx(2)) != nil {
+		t.Fatalf("a tx without runestone decoded one")
+	}
+	if _, err = (&Runestone{Flaw: FlawOpcode}).Script(); err == nil {
+		t.Fatalf("Script() encoded a cenotaph")
+	}
+	if _, err = (&Runestone{Etching: &Etching{Rune: "abc"}}).Script(); err == nil {
+		t.Fatalf("Script() accepted an invalid rune name")
+	}
+	overflow := &Runestone{Etching: &Etching{Premine: u128Max, Terms: &Terms{Amount: big.NewInt(1), Cap: big.NewInt(1)}}}
+	if _, err = overflow.Script(); err == nil {
+		t.Fatalf("Script() accepted a supply above u128")
+	}
+}
+
+func TestDecodeTxRunestone(t *testing.T) {
+	tx := testRunestoneTx(runeTagFlags, 1, runeTagRune, 0, runeTagBody, 0, 0, 10, 1)
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize() error = %v", err)
+	}
+	runestone, err := DecodeTxRunestone(hex.EncodeToString(buf.Bytes()))
+	if err != nil || runestone == nil || runestone.Etching == nil || runestone.Etching.Rune != "A" ||
+		len(runestone.Edicts) != 1 || runestone.Edicts[0].Id != (RuneId{}) || runestone.Edicts[0].Output != 1 {
+		t.Fatalf("DecodeTxRunestone() = %+v, %v", runestone, err)
+	}
+}
+
+func TestDecodeRunestone_Cenotaph(t *testing.T) {
+	for name, test := range map[string]struct {
+		tx   *wire.MsgTx
+		flaw RunestoneFlaw
+	}{
+		"truncated field":   {tx: testRunestoneTx(runeTagPointer), flaw: FlawTruncatedField},
+		"trailing integers": {tx: testRunestoneTx(runeTagBody, 1, 1, 5), flaw: FlawTrailingIntegers},
+		"edict rune id":     {tx: testRunestoneTx(runeTagBody, 0, 1, 5, 1), flaw: FlawEdictRuneId},
+		"edict output":      {tx: testRunestoneTx(runeTagBody, 1, 1, 5, 4), flaw: FlawEdictOutput},
+		"unrecognized even": {tx: testRunestoneTx(runeTagMint, 1, runeTagMint, 1, 126, 0), flaw: FlawUnrecognizedEvenTag},
+		"duplicate even":    {tx: testRunestoneTx(runeTagPointer, 1, runeTagPointer, 2), flaw: FlawUnrecognizedEvenTag},
+		"invalid pointer":   {tx: testRunestoneTx(runeTagPointer, 3), flaw: FlawUnrecognizedEvenTag},
+		"unrecognized flag": {tx: testRunestoneTx(runeTagFlags, 1<<3), flaw: FlawUnrecognizedFlag},
+		"supply overflow": {tx: func() *wire.MsgTx {
+			tx := testRunestoneTx()
+			payload := appendRuneTag(nil, runeTagFlags, big.NewInt(3))
+			payload = appendRuneTag(payload, runeTagRune, big.NewInt(5))
+			payload = appendRuneTag(payload, runeTagPremine, u128Max)
+			payload = appendRuneTag(payload, runeTagCap, big.NewInt(1))
+			payload = appendRuneTag(payload, runeTagAmount, big.NewInt(1))
+			tx.TxOut[0].PkScript = appendPush([]byte{txscript.OP_RETURN, RunestoneMagic}, payload)
+			return tx
+		}(), flaw: FlawSupplyOverflow},
+		"varint": {tx: func() *wire.MsgTx {
+			tx := testRunestoneTx()
+			tx.TxOut[0].PkScript = appendPush([]byte{txscript.OP_RETURN, RunestoneMagic}, []byte{0x80})
+			return tx
+		}(), flaw: FlawVarint},
+		"opcode": {tx: func() *wire.MsgTx {
+			tx := testRunestoneTx()
+			tx.TxOut[0].PkScript = []byte{txscript.OP_RETURN, RunestoneMagic, txscript.OP_1}
+			return tx
+		}(), flaw: FlawOpcode},
+		"invalid script": {tx: func() *wire.MsgTx {
+			tx := testRunestoneTx()
+			tx.TxOut[0].PkScript = []byte{txscript.OP_RETURN, RunestoneMagic, txscript.OP_DATA_2, 0}
+			return tx
+		}(), flaw: FlawInvalidScript},
+	} {
+		runestone := DecodeRunestone(test.tx)
+		if runestone == nil || runestone.Flaw != test.flaw || !runestone.IsCenotaph() ||
+			runestone.Edicts != nil || runestone.Pointer != nil {
+			t.Fatalf("%s: DecodeRunestone() = %+v, want flaw %s", name, runestone, test.flaw)
+		}
+	}
+
+	// a cenotaph keeps the etched rune and the mint
+	runestone := DecodeRunestone(testRunestoneTx(runeTagFlags, 1, runeTagRune, 26, runeTagMint, 2, runeTagMint, 3, 126, 0))
+	if runestone.Flaw != FlawUnrecognizedEvenTag || runestone.Etching == nil || runestone.Etching.Rune != "AA" ||
+		runestone.Mint == nil || *runestone.Mint != (RuneId{Block: 2, Tx: 3}) {
+		t.Fatalf("DecodeRunestone() = %+v", runestone)
+	}
+	// unknown odd tags are ignored
+	if runestone = DecodeRunestone(testRunestoneTx(127, 5, runeTagPointer, 2)); runestone.IsCenotaph() || *runestone.Pointer != 2 {
+		t.Fatalf("DecodeRunestone() = %+v", runestone)
+	}
+}
@@ -0,0 +1,86 @@
+package psbt_sdk
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func TestRuneName(t *testing.T) {
+	for value, name := range map[string]string{
+		"0":   "A",
+		"25":  "Z",
+		"26":  "AA",
+		"701": "ZZ",
+		"702": "AAA",
+		"340282366920938463463374607431768211455": "BCGDENLQRQWDSLRUGSNLBTMFIJAV",
+	} {
+		n, _ := new(big.Int).SetString(value, 10)
+		if got := RuneName(n); got != name {
+			t.Fatalf("RuneName(%s) = %s, want %s", value, got, name)
+		}
+		if got, err := RuneValue(name); err != nil || got.Cmp(n) != 0 {
+			t.Fatalf("RuneValue(%s) = %v, %v, want %s", name, got, err, value)
+		}
+	}
+	for _, name := range []string{"", "abc", "BCGDENLQRQWDSLRUGSNLBTMFIJAW", "AAAAAAAAAAAAAAAAAAAAAAAAAAAAA"} {
+		if _, err := RuneValue(name); err == nil {
+			t.Fatalf("RuneValue(%q) accepted", name)
+		}
+	}
+}
+
+func TestParseSpacedRune(t *testing.T) {
+	spaced, err := ParseSpacedRune("UNCOMMON.GOODS")
+	if err != nil || spaced.Rune != "UNCOMMONGOODS" || spaced.Spacers != 1<<7 {
+		t.Fatalf("ParseSpacedRune() = %+v, %v", spaced, err)
+	}
+	if spaced.String() != "UNCOMMON•GOODS" {
+		t.Fatalf("String() = %s", spaced.String())
+	}
+	if spaced, err = ParseSpacedRune("A•B•C"); err != nil || spaced.Spacers != 0b11 {
+		t.Fatalf("ParseSpacedRune() = %+v, %v", spaced, err)
+	}
+	for _, s := range []string{"•A", "A•", "A••B", "A-B", ""} {
+		if _, err := ParseSpacedRune(s); err == nil {
+			t.Fatalf("ParseSpacedRune(%q) accepted", s)
+		}
+	}
+}
+
+func TestParseRuneId(t *testing.T) {
+	id, err := ParseRuneId("840000:3")
+	if err != nil || *id != (RuneId{Block: 840000, Tx: 3}) || id.String() != "840000:3" {
+		t.Fatalf("ParseRuneId() = %v, %v", id, err)
+	}
+	for _, s := range []string{"840000", "0:1", "a:1", "1:4294967296"} {
+		if _, err := ParseRuneId(s); err == nil {
+			t.Fatalf("ParseRuneId(%q) accepted", s)
+		}
+	}
+}
+
+func TestVarint(t *testing.T) {
+	for value, encoded := range map[string]string{
+		"0":   "00",
+		"127": "7f",
+		"128": "8001",
+		"300": "ac02",
+		"340282366920938463463374607431768211455": "ffffffffffffffffffffffffffffffffffff03",
+	} {
+		n, _ := new(big.Int).SetString(value, 10)
+		if got := hex.EncodeToString(appendVarint(nil, n)); got != encoded {
+			t.Fatalf("appendVarint(%s) = %s, want %s", value, got, encoded)
+		}
+		raw, _ := hex.DecodeString(encoded)
+		if got, size, err := decodeVarint(raw); err != nil || got.Cmp(n) != 0 || size != len(raw) {
+			t.Fatalf("decodeVarint(%s) = %v, %d, %v", encoded, got, size, err)
+		}
+	}
+	for _, encoded := range []string{"80", "ffffffffffffffffffffffffffffffffffff04", "8080808080808080808080808080808080808080"} {
+		raw, _ := hex.DecodeString(encoded)
+		if _, _, err := decodeVarint(raw); err == nil {
+			t.Fatalf("decodeVarint(%s) accepted", encoded)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package psbt_sdk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// InputSigHash is the digest an external signer has to sign for an input
+type InputSigHash struct {
+	Index       int
+	OutPoint    wire.OutPoint
+	UtxoType    UtxoType
+	SighashType txscript.SigHashType
+	// SigHash is the legacy, BIP143 or BIP341 signature hash
+	SigHash []byte
+	// Script is the script code the sighash commits to, the leaf script
+	// for tapscript spends and nil for taproot key spends
+	Script []byte
+	// LeafHash is only set for tapscript spends
+	LeafHash []byte
+}
+
+// sigHashCache holds the prevouts and the BIP143/BIP341 midstates of the
+// unsigned transaction, it is built once per signing session and shared by
+// every input signed in it
+type sigHashCache struct {
+	tx                *wire.MsgTx
+	prevOutputFetcher *txscript.MultiPrevOutFetcher
+	sigHashes         *txscript.TxSigHashes
+	// complete is false when an input has no utxo, taproot sighashes
+	// commit to every prevout and can't be computed then
+	complete bool
+}
+
+func (s *PsbtBuilder) newSigHashCache() *sigHashCache {
+	prevOutputFetcher, complete := s.prevOutputFetcher()
+	return &sigHashCache{
+		tx:                s.PsbtUpdater.Upsbt.UnsignedTx,
+		prevOutputFetcher: prevOutputFetcher,
+		sigHashes:         txscript.NewTxSigHashes(s.PsbtUpdater.Upsbt.UnsignedTx, prevOutputFetcher),
+		complete:          complete,
+	}
+}
+
+func (c *sigHashCache) checkTaproot(index int) error {
+	if !c.complete {
+		return &MissingUtxoError{
+			Index:    index,
+			OutPoint: c.tx.TxIn[index].PreviousOutPoint,
+			Reason:   "taproot sighash requires the utxo of every input",
+		}
+	}
+	return nil
+}
+
+// CalcSigHashes returns the sighash of every input that is not finalized,
+// taproot inputs use the key path
+func (s *PsbtBuilder) CalcSigHashes() ([]*InputSigHash, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cache := s.newSigHashCache()
+	hashes := make([]*InputSigHash, 0, len(s.PsbtUpdater.Upsbt.Inputs))
+	for i := range s.PsbtUpdater.Upsbt.Inputs {
+		pIn := &s.PsbtUpdater.Upsbt.Inputs[i]
+		if pIn.FinalScriptSig != nil || pIn.FinalScriptWitness != nil {
+			continue
+		}
+		hash, err := s.calcInputSigHash(i, nil, cache)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// CalcInputSigHash returns the legacy, segwit v0 or taproot key path sighash of an input
+func (s *PsbtBuilder) CalcInputSigHash(index int) (*InputSigHash, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if err := s.checkInputIndex(index); err != nil {
+		return nil, err
+	}
+	return s.calcInputSigHash(index, nil, s.newSigHashCache())
+}
+
+// CalcTapscriptSigHash returns the script path sighash of a taproot input for
+// leafScript, which may be nil when the input carries a single leaf script
+func (s *PsbtBuilder) CalcTapscriptSigHash(index int, leafScript []byte) (*InputSigHash, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if err := s.checkInputIndex(index); err != nil {
+		return nil, err
+	}
+	pIn := &s.PsbtUpdater.Upsbt.Inputs[index]
+	var leaf *psbt.TaprootTapLeafScript
+	for _, l := range pIn.TaprootLeafScript {
+		if leafScript == nil || bytes.Equal(l.Script, leafScript) {
+			if leaf != nil {
+				return nil, s.inputError(index, errors.New("several leaf scripts, the leaf script must be given"))
+			}
+			leaf = l
+		}
+	}
+	if leaf == nil {
+		if leafScript == nil {
+			return nil, s.inputError(index, errors.New("no taproot leaf script"))
+		}
+		leaf = &psbt.TaprootTapLeafScript{Script: leafScript, LeafVersion: txscript.BaseLeafVersion}
+	}
+	return s.calcInputSigHash(index, leaf, s.newSigHashCache())
+}
+
+func (s *PsbtBuilder) calcInputSigHash(index int, leaf *psbt.TaprootTapLeafScript, cache *sigHashCache) (*InputSigHash, error) {
+	var (
+		tx   = s.PsbtUpdater.Upsbt.UnsignedTx
+		pIn  = &s.PsbtUpdater.Upsbt.Inputs[index]
+		hash = &InputSigHash{
+			Index:       index,
+			OutPoint:    tx.TxIn[index].PreviousOutPoint,
+			SighashType: pIn.SighashType,
+		}
+		err error
+	)
+	prevOut := s.inputUtxo(index)
+	if prevOut == nil {
+		return nil, s.missingUtxoError(index)
+	}
+
+	pkScript := prevOut.PkScript
+	if txscript.IsPayToScriptHash(pkScript) && pIn.RedeemScript != nil {
+		pkScript = pIn.RedeemScript
+	}
+	switch {
+	case txscript.IsPayToTaproot(pkScript):
+		if err = cache.checkTaproot(index); err != nil {
+			return nil, err
+		}
+		hash.UtxoType = Taproot
+		if leaf == nil {
+			hash.SigHash, err = txscript.CalcTaprootSignatureHash(cache.sigHashes, hash.SighashType, tx, index, cache.prevOutputFetcher)
+		} else {
+			tapLeaf := txscript.NewTapLeaf(leaf.LeafVersion, leaf.Script)
+			leafHash := tapLeaf.TapHash()
+			hash.Script = leaf.Script
+			hash.LeafHash = leafHash.CloneBytes()
+			hash.SigHash, err = txscript.CalcTapscriptSignaturehash(cache.sigHashes, hash.SighashType, tx, index, cache.prevOutputFetcher, tapLeaf)
+		}
+	case leaf != nil:
+		return nil, s.inputError(index, errors.New("tapscript sighash on a non taproot input"))
+	case txscript.IsPayToWitnessScriptHash(pkScript):
+		if pIn.WitnessScript == nil {
+			return nil, s.inputError(index, errors.New("missing witness script"))
+		}
+		hash.UtxoType = Witness
+		hash.Script = pIn.WitnessScript
+		hash.SigHash, err = txscript.CalcWitnessSigHash(pIn.WitnessScript, cache.sigHashes, s.ecdsaSighashType(index), tx, index, prevOut.Value)
+	case txscript.IsPayToWitnessPubKeyHash(pkScript):
+		hash.UtxoType = Witness
+		hash.Script = pkScript
+		hash.SigHash, err = txscript.CalcWitnessSigHash(pkScript, cache.sigHashes, s.ecdsaSighashType(index), tx, index, prevOut.Value)
+	default:
+		hash.UtxoType = NonWitness
+		hash.Script = pkScript
+		hash.SigHash, err = txscript.CalcSignatureHash(pkScript, s.ecdsaSighashType(index), tx, index)
+	}
+	if err != nil {
+		return nil, &InvalidSighashError{Index: index, OutPoint: hash.OutPoint, SighashType: hash.SighashType, Err: err}
+	}
+	if hash.UtxoType != Taproot {
+		hash.SighashType = s.ecdsaSighashType(index)
+	}
+	return hash, nil
+}
+
+// ecdsaSighashType defaults an unset psbt sighash type to SIGHASH_ALL
+func (s *PsbtBuilder) ecdsaSighashType(index int) txscript.SigHashType {
+	if s.PsbtUpdater.Upsbt.Inputs[index].SighashType == 0 {
+		return txscript.SigHashAll
+	}
+	return s.PsbtUpdater.Upsbt.Inputs[index].SighashType
+}
+
+// add signature produced by an external signer for sigHash, ECDSA signatures
+// carry the sighash byte and taproot signatures may omit it
+func (s *PsbtBuilder) AddInputSignature(sigHash *InputSigHash, pubKey []byte, signature []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := sigHash.Index
+	if err := s.checkInputIndex(index); err != nil {
+		return err
+	}
+	if s.outPoint(index) != sigHash.OutPoint {
+		return s.inputError(index, errors.New(fmt.Sprintf("sighash was computed for %s", sigHash.OutPoint.String())))
+	}
+	if sigHash.UtxoType == Taproot && len(signature) == schnorr.SignatureSize && sigHash.SighashType != txscript.SigHashDefault {
+		signature = append(append([]byte{}, signature...), byte(sigHash.SighashType))
+	}
+	return s.addSignature(index, pubKey, signature, sigHash.LeafHash)
+}
+
+// AddSignature checks signature against the sighash of the input and adds it
+// as an ECDSA partial signature, a taproot key spend signature or, when
+// leafHash is set, a tapscript signature of pubKey
+func (s *PsbtBuilder) AddSignature(index int, pubKey, signature, leafHash []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addSignature(index, pubKey, signature, leafHash)
+}
+
+func (s *PsbtBuilder) addSignature(index int, pubKey, signature, leafHash []byte) error {
+	if err := s.checkInputIndex(index); err != nil {
+		return err
+	}
+	prevOut := s.inputUtxo(index)
+	if prevOut == nil {
+		return s.missingUtxoError(index)
+	}
+	var (
+		tx  = s.PsbtUpdater.Upsbt.UnsignedTx
+		pIn = &s.PsbtUpdater.Upsbt.Inputs[index]
+	)
+	cache := s.newSigHashCache()
+
+	if !txscript.IsPayToTaproot(prevOut.PkScript) {
+		if leafHash != nil {
+			return s.inputError(index, errors.New("tapscript signature on a non taproot input"))
+		}
+		if len(signature) > 0 && pIn.SighashType != 0 && txscript.SigHashType(signature[len(signature)-1]) != pIn.SighashType {
+			return &InvalidSighashError{Index: index, OutPoint: s.outPoint(index),
+				SighashType: txscript.SigHashType(signature[len(signature)-1]),
+				Err:         errors.New(fmt.Sprintf("input requires sighash %d", pIn.SighashType))}
+		}
+		partialSig := &psbt.PartialSig{PubKey: pubKey, Signature: signature}
+		if err := verifyEcdsaPartialSig(tx, index, pIn, prevOut, partialSig, cache.sigHashes); err != nil {
+			return &InvalidSignatureError{Index: index, OutPoint: s.outPoint(index), PubKey: pubKey, Err: err}
+		}
+		res, err := s.PsbtUpdater.Sign(index, signature, pubKey, nil, nil)
+		if err = s.signOutcomeError(index, res, err); err != nil {
+			return err
+		}
+		s.logInput("psbt input signature added", index, "pubkey", s.sensitive(pubKey), "signature", s.sensitive(signature))
+		return nil
+	}
+
+	if err := cache.checkTaproot(index); err != nil {
+		return err
+	}
+	sig, hashType, err := splitSchnorrSig(signature)
+	if err != nil {
+		return &InvalidSignatureError{Index: index, OutPoint: s.outPoint(index), PubKey: pubKey, Err: err}
+	}
+	if pIn.SighashType != 0 && hashType != pIn.SighashType {
+		return &InvalidSighashError{Index: index, OutPoint: s.outPoint(index), SighashType: hashType,
+			Err: errors.New(fmt.Sprintf("input requires sighash %d", pIn.SighashType))}
+	}
+	if leafHash == nil {
+		sigHash, err := txscript.CalcTaprootSignatureHash(cache.sigHashes, hashType, tx, index, cache.prevOutputFetcher)
+		if err != nil {
+			return &InvalidSighashError{Index: index, OutPoint: s.outPoint(index), SighashType: hashType, Err: err}
+		}
+		if err = verifySchnorr(prevOut.PkScript[2:], sig, sigHash); err != nil {
+			return &InvalidSignatureError{Index: index, OutPoint: s.outPoint(index), PubKey: prevOut.PkScript[2:], Err: err}
+		}
+		pIn.TaprootKeySpendSig = signature
+		s.logInput("psbt input signature added", index, "signature", s.sensitive(signature))
+		return nil
+	}
+
+	if len(pubKey) == 33 {
+		pubKey = pubKey[1:]
+	}
+	leafScript, err := psbt.FindLeafScript(pIn, leafHash)
+	if err != nil {
+		return s.inputError(index, err)
+	}
+	leaf := txscript.NewTapLeaf(leafScript.LeafVersion, leafScript.Script)
+	sigHash, err := txscript.CalcTapscriptSignaturehash(cache.sigHashes, hashType, tx, index, cache.prevOutputFetcher, leaf)
+	if err != nil {
+		return &InvalidSighashError{Index: index, OutPoint: s.outPoint(index), SighashType: hashType, Err: err}
+	}
+	if err = verifySchnorr(pubKey, sig, sigHash); err != nil {
+		return &InvalidSignatureError{Index: index, OutPoint: s.outPoint(index), PubKey: pubKey, Err: err}
+	}
+	addTaprootScriptSpendSig(pIn, &psbt.TaprootScriptSpendSig{
+		XOnlyPubKey: pubKey,
+		LeafHash:    leafHash,
+		Signature:   sig,
+		SigHash:     hashType,
+	})
+	s.logInput("psbt input signature added", index, "pubkey", s.sensitive(pubKey),
+		"leaf_hash", hex.EncodeToString(leafHash), "signature", s.sensitive(sig))
+	return nil
+}
+
+// addTaprootScriptSpendSig adds sig to the input, replacing an earlier
+// signature of the same key for the same leaf
+func addTaprootScriptSpendSig(pIn *psbt.PInput, sig *psbt.TaprootScriptSpendSig) {
+	for i, v := range pIn.TaprootScriptSpendSig {
+		if v.EqualKey(sig) {
+			pIn.TaprootScriptSpendSig[i] = sig
+			return
+		}
+	}
+	pIn.TaprootScriptSpendSig = append(pIn.TaprootScriptSpendSig, sig)
+}
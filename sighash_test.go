@@ -0,0 +1,238 @@
+package psbt_sdk
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestPsbtBuilder_ExternalSigning(t *testing.T) {
+	// legacy p2pkh utxo of key 1
+	p2pkh, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_DUP).AddOp(txscript.OP_HASH160).
+		AddData(btcutil.Hash160(testPrivKey(1).PubKey().SerializeCompressed())).
+		AddOp(txscript.OP_EQUALVERIFY).AddOp(txscript.OP_CHECKSIG).Script()
+	prevTx := wire.NewMsgTx(2)
+	prevTx.AddTxIn(&wire.TxIn{PreviousOutPoint: wire.OutPoint{Index: 7}})
+	prevTx.AddTxOut(wire.NewTxOut(25000, p2pkh))
+
+	// tapscript leaf of key 4
+	expr := fmt.Sprintf("pk(%s)", testPubHex(4, Tapscript))
+	ms, _ := ParseMiniscript(expr, Tapscript)
+	internalKey := testPrivKey(9).PubKey()
+	tree := txscript.AssembleTaprootScriptTree(txscript.NewBaseTapLeaf(ms.Script()))
+	controlBlock := tree.LeafMerkleProofs[0].ToControlBlock(internalKey)
+	controlBlockBytes, _ := controlBlock.ToBytes()
+	rootHash := tree.RootNode.TapHash()
+	tapScript := "5120" + hex.EncodeToString(schnorr.SerializePubKey(txscript.ComputeTaprootOutputKey(internalKey, rootHash[:])))
+
+	builder, err := CreatePsbtBuilder(&chaincfg.SigNetParams, []Input{
+		{OutTxId: prevTx.TxHash().String(), OutIndex: 0},
+		{OutTxId: "93b3484083c31769c6a82b9c1cb66979463d86b3edea5044dede1ffdb74c3db2", OutIndex: 1},
+		{OutTxId: "93b3484083c31769c6a82b9c1cb66979463d86b3edea5044dede1ffdb74c3db2", OutIndex: 2},
+		{OutTxId: "93b3484083c31769c6a82b9c1cb66979463d86b3edea5044dede1ffdb74c3db2", OutIndex: 3},
+	}, []Output{{Script: testP2wpkhScript(8), Amount: 90000}})
+	if err != nil {
+		t.Fatalf("CreatePsbtBuilder() error = %v", err)
+	}
+	if err = builder.PsbtUpdater.AddInNonWitnessUtxo(prevTx, 0); err != nil {
+		t.Fatalf("AddInNonWitnessUtxo() error = %v", err)
+	}
+	err = builder.UpdateAndAddInputWitness([]*InputSign{
+		{UtxoType: Witness, Index: 1, PkScript: testP2wpkhScript(2), Amount: 26000, SighashType: txscript.SigHashAll},
+		{UtxoType: Taproot, Index: 2, PkScript: testP2trScript(3), Amount: 27000, SighashType: txscript.SigHashAll | txscript.SigHashAnyOneCanPay},
+	})
+	if err != nil {
+		t.Fatalf("UpdateAndAddInputWitness() error = %v", err)
+	}
+	err = builder.UpdateMiniscriptInput([]*InputSign{{UtxoType: Taproot, Index: 3, PkScript: tapScript, Amount: 28000,
+		ControlBlockWitness: hex.EncodeToString(controlBlockBytes), Miniscript: expr}})
+	if err != nil {
+		t.Fatalf("UpdateMiniscriptInput() error = %v", err)
+	}
+
+	hashes, err := builder.CalcSigHashes()
+	if err != nil {
+		t.Fatalf("CalcSigHashes() error = %v", err)
+	}
+	leafHash, err := builder.CalcTapscriptSigHash(3, nil)
+	if err != nil {
+		t.Fatalf("CalcTapscriptSigHash() error = %v", err)
+	}
+	wantTypes := []UtxoType{NonWitness, Witness, Taproot, Taproot}
+	for i, h := range hashes {
+		if h.UtxoType != wantTypes[i] || len(h.SigHash) != 32 {
+			t.Fatalf("CalcSigHashes()[%d] = %+v", i, h)
+		}
+	}
+
+	// sign outside of the builder and inject the signatures back
+	for i, h := range hashes[:2] {
+		key := testPrivKey(byte(i + 1))
+		sig := append(ecdsa.Sign(key, h.SigHash).Serialize(), byte(h.SighashType))
+		if err = builder.AddInputSignature(h, key.PubKey().SerializeCompressed(), sig); err != nil {
+			t.Fatalf("AddInputSignature(%d) error = %v", i, err)
+		}
+	}
+	tweaked := txscript.TweakTaprootPrivKey(*testPrivKey(3), nil)
+	sig, _ := schnorr.Sign(tweaked, hashes[2].SigHash)
+	if err = builder.AddInputSignature(hashes[2], nil, sig.Serialize()); err != nil {
+		t.Fatalf("AddInputSignature(2) error = %v", err)
+	}
+	sig, _ = schnorr.Sign(testPrivKey(4), leafHash.SigHash)
+	if err = builder.AddInputSignature(leafHash, testPrivKey(4).PubKey().SerializeCompressed(), sig.Serialize()); err != nil {
+		t.Fatalf("AddInputSignature(3) error = %v", err)
+	}
+
+	if _, err = builder.ExtractPsbtTransaction(); err != nil {
+		t.Fatalf("ExtractPsbtTransaction() error = %v", err)
+	}
+	if _, err = builder.Verify(); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+}
+
+func TestPsbtBuilder_AddSignature(t *testing.T) {
+	builder := testBuilder(t, 2)
+	err := builder.UpdateAndAddInputWitness([]*InputSign{
+		{UtxoType: Witness, Index: 0, PkScript: testP2wpkhScript(1), Amount: 26000, SighashType: txscript.SigHashAll},
+		{UtxoType: Taproot, Index: 1, PkScript: testP2trScript(2), Amount: 27000, SighashType: txscript.SigHashDefault},
+	})
+	if err != nil {
+		t.Fatalf("UpdateAndAddInputWitness() error = %v", err)
+	}
+	hashes, err := builder.CalcSigHashes()
+	if err != nil {
+		t.Fatalf("CalcSigHashes() error = %v", err)
+	}
+
+	// signatures of the wrong key or over the wrong digest are rejected
+	wrongSig := append(ecdsa.Sign(testPrivKey(5), hashes[0].SigHash).Serialize(), byte(txscript.SigHashAll))
+	if err = builder.AddSignature(0, testPrivKey(1).PubKey().SerializeCompressed(), wrongSig, nil); err == nil {
+		t.Fatalf("AddSignature() with a foreign signature, want error")
+	}
+	tweaked := txscript.TweakTaprootPrivKey(*testPrivKey(2), nil)
+	schnorrSig, _ := schnorr.Sign(tweaked, hashes[0].SigHash)
+	if err = builder.AddSignature(1, nil, schnorrSig.Serialize(), nil); err == nil {
+		t.Fatalf("AddSignature() with a signature over another input, want error")
+	}
+	if pIn := builder.PsbtUpdater.Upsbt.Inputs; len(pIn[0].PartialSigs) != 0 || pIn[1].TaprootKeySpendSig != nil {
+		t.Fatalf("rejected signatures were added to the psbt")
+	}
+
+	sig := append(ecdsa.Sign(testPrivKey(1), hashes[0].SigHash).Serialize(), byte(txscript.SigHashAll))
+	if err = builder.AddSignature(0, testPrivKey(1).PubKey().SerializeCompressed(), sig, nil); err != nil {
+		t.Fatalf("AddSignature(0) error = %v", err)
+	}
+	// a valid key path signature of another sighash type than the input's
+	schnorrSig, _ = schnorr.Sign(tweaked, hashes[1].SigHash)
+	builder.PsbtUpdater.Upsbt.Inputs[1].SighashType = txscript.SigHashAll
+	if err = builder.AddSignature(1, nil, schnorrSig.Serialize(), nil); !errors.Is(err, ErrInvalidSighash) {
+		t.Fatalf("AddSignature() of a SIGHASH_DEFAULT signature for SIGHASH_ALL error = %v, want ErrInvalidSighash", err)
+	}
+	if builder.PsbtUpdater.Upsbt.Inputs[1].TaprootKeySpendSig != nil {
+		t.Fatalf("signature of the wrong sighash type was added to the psbt")
+	}
+	builder.PsbtUpdater.Upsbt.Inputs[1].SighashType = 0
+	if err = builder.AddSignature(1, nil, schnorrSig.Serialize(), nil); err != nil {
+		t.Fatalf("AddSignature(1) error = %v", err)
+	}
+	if _, err = builder.ExtractPsbtTransaction(); err != nil {
+		t.Fatalf("ExtractPsbtTransaction() error = %v", err)
+	}
+}
+
+func TestPsbtBuilder_AddSigIn(t *testing.T) {
+	p2pkh, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_DUP).AddOp(txscript.OP_HASH160).
+		AddData(btcutil.Hash160(testPrivKey(1).PubKey().SerializeCompressed())).
+		AddOp(txscript.OP_EQUALVERIFY).AddOp(txscript.OP_CHECKSIG).Script()
+	prevTx := wire.NewMsgTx(2)
+	prevTx.AddTxIn(&wire.TxIn{PreviousOutPoint: wire.OutPoint{Index: 3}})
+	prevTx.AddTxOut(wire.NewTxOut(25000, p2pkh))
+	builder, err := CreatePsbtBuilder(&chaincfg.SigNetParams, []Input{
+		{OutTxId: prevTx.TxHash().String(), OutIndex: 0},
+		{OutTxId: "93b3484083c31769c6a82b9c1cb66979463d86b3edea5044dede1ffdb74c3db2", OutIndex: 1},
+	}, []Output{{Script: testP2wpkhScript(8), Amount: 40000}})
+	if err != nil {
+		t.Fatalf("CreatePsbtBuilder() error = %v", err)
+	}
+
+	// a final witness that does not satisfy the utxo is rejected
+	witnessUtxo := wire.NewTxOut(26000, mustDecodeHex(t, testP2wpkhScript(2)))
+	if err = builder.AddSigIn(witnessUtxo, txscript.SigHashAll, []byte{0x00}, 1); err == nil {
+		t.Fatalf("AddSigIn() with an empty witness, want error")
+	}
+	if builder.PsbtUpdater.Upsbt.Inputs[1].WitnessUtxo != nil {
+		t.Fatalf("AddSigIn() left the rejected input in the psbt")
+	}
+
+	sigHash, err := txscript.CalcSignatureHash(p2pkh, txscript.SigHashAll, builder.PsbtUpdater.Upsbt.UnsignedTx, 0)
+	if err != nil {
+		t.Fatalf("CalcSignatureHash() error = %v", err)
+	}
+	partialSigs := []*psbt.PartialSig{{
+		PubKey:    testPrivKey(1).PubKey().SerializeCompressed(),
+		Signature: append(ecdsa.Sign(testPrivKey(1), sigHash).Serialize(), byte(txscript.SigHashAll)),
+	}}
+	if err = builder.AddSigInForNonWitnessUtxo(prevTx, partialSigs, txscript.SigHashAll, nil, 0); err != nil {
+		t.Fatalf("AddSigInForNonWitnessUtxo() error = %v", err)
+	}
+	if len(builder.PsbtUpdater.Upsbt.Inputs[0].PartialSigs) != 1 {
+		t.Fatalf("AddSigInForNonWitnessUtxo() partial sigs = %d, want 1", len(builder.PsbtUpdater.Upsbt.Inputs[0].PartialSigs))
+	}
+	if err = builder.AddSigInForNonWitnessUtxo(wire.NewMsgTx(2), partialSigs, txscript.SigHashAll, nil, 0); err == nil {
+		t.Fatalf("AddSigInForNonWitnessUtxo() with a foreign transaction, want error")
+	}
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("hex.DecodeString() error = %v", err)
+	}
+	return b
+}
+
+func benchmarkSignInputs(b *testing.B, nIn int, utxoType UtxoType) {
+	inputs := make([]Input, 0, nIn)
+	signIns := make([]*InputSign, 0, nIn)
+	for i := 0; i < nIn; i++ {
+		inputs = append(inputs, Input{OutTxId: "93b3484083c31769c6a82b9c1cb66979463d86b3edea5044dede1ffdb74c3db2", OutIndex: uint32(i)})
+		pkScript := testP2wpkhScript(1)
+		if utxoType == Taproot {
+			pkScript = testP2trScript(1)
+		}
+		signIns = append(signIns, &InputSign{UtxoType: utxoType, Index: i, PkScript: pkScript, Amount: 10000,
+			SighashType: txscript.SigHashAll, PriHex: hex.EncodeToString(testPrivKey(1).Serialize())})
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		builder, err := CreatePsbtBuilder(&chaincfg.SigNetParams, inputs, []Output{{Script: testP2wpkhScript(8), Amount: 5000}})
+		if err != nil {
+			b.Fatalf("CreatePsbtBuilder() error = %v", err)
+		}
+		b.StartTimer()
+		if err = builder.UpdateAndSignInput(signIns); err != nil {
+			b.Fatalf("UpdateAndSignInput() error = %v", err)
+		}
+	}
+}
+
+// ns/op grows linearly with the input count, the midstates are computed once
+// per call instead of once per input
+func BenchmarkPsbtBuilder_UpdateAndSignInput(b *testing.B) {
+	for _, nIn := range []int{10, 100, 500} {
+		b.Run(fmt.Sprintf("p2wpkh-%d", nIn), func(b *testing.B) { benchmarkSignInputs(b, nIn, Witness) })
+		b.Run(fmt.Sprintf("p2tr-%d", nIn), func(b *testing.B) { benchmarkSignInputs(b, nIn, Taproot) })
+	}
+}
@@ -0,0 +1,161 @@
+package psbt_sdk
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// inputSig is a signature computed for an input but not yet added to the psbt
+type inputSig struct {
+	signIn       *InputSign
+	pubKey       []byte
+	signature    []byte
+	redeemScript []byte
+}
+
+// SetSignWorkers lets UpdateAndSignInput compute signatures on up to workers
+// goroutines, values below 2 sign on the calling goroutine. Workers only read
+// the unsigned transaction and the shared midstates, the psbt packet is
+// written from the calling goroutine in input order, so the result is the same
+// as a sequential run.
+func (s *PsbtBuilder) SetSignWorkers(workers int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signWorkers = workers
+}
+
+// calcInputSigs computes the signatures of signIns, concurrently when sign
+// workers are set. On failure the error of the first failing signIn is
+// returned and nothing has been written to the psbt.
+func (s *PsbtBuilder) calcInputSigs(signIns []*InputSign, cache *sigHashCache) ([]*inputSig, error) {
+	sigs := make([]*inputSig, len(signIns))
+	workers := s.signWorkers
+	if workers > len(signIns) {
+		workers = len(signIns)
+	}
+	if workers < 2 {
+		for i, v := range signIns {
+			sig, err := s.calcInputSig(v, cache)
+			if err != nil {
+				return nil, err
+			}
+			sigs[i] = sig
+		}
+		return sigs, nil
+	}
+
+	var (
+		errs = make([]error, len(signIns))
+		jobs = make(chan int)
+		wg   sync.WaitGroup
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				sigs[i], errs[i] = s.calcInputSig(signIns[i], cache)
+			}
+		}()
+	}
+	for i := range signIns {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sigs, nil
+}
+
+// calcInputSig signs an input with the midstates of the signing session
+// without touching the psbt packet
+func (s *PsbtBuilder) calcInputSig(v *InputSign, cache *sigHashCache) (*inputSig, error) {
+	if err := s.checkInputIndex(v.Index); err != nil {
+		return nil, err
+	}
+	var (
+		tx  = s.PsbtUpdater.Upsbt.UnsignedTx
+		sig = &inputSig{signIn: v}
+		err error
+	)
+	privateKeyBytes, err := hex.DecodeString(v.PriHex)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, _ := btcec.PrivKeyFromBytes(privateKeyBytes)
+	sig.pubKey = privateKey.PubKey().SerializeCompressed()
+	if v.RedeemScript != "" {
+		sig.redeemScript, err = hex.DecodeString(v.RedeemScript)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	prevOut := s.inputUtxo(v.Index)
+	if prevOut == nil {
+		return nil, s.missingUtxoError(v.Index)
+	}
+	switch v.UtxoType {
+	case NonWitness:
+		sig.signature, err = txscript.RawTxInSignature(tx, v.Index, prevOut.PkScript, v.SighashType, privateKey)
+	case Witness:
+		sig.signature, err = txscript.RawTxInWitnessSignature(tx, cache.sigHashes, v.Index,
+			prevOut.Value, prevOut.PkScript, v.SighashType, privateKey)
+	case Taproot:
+		if err = cache.checkTaproot(v.Index); err != nil {
+			return nil, err
+		}
+		sig.signature, err = signTaprootKeySpend(tx, cache.sigHashes, v.Index, prevOut, v.SighashType, privateKey)
+	default:
+		err = s.inputError(v.Index, errors.New(fmt.Sprintf("unknown utxo type %d", v.UtxoType)))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// applyInputSig adds a computed signature to the psbt and finalizes the input
+func (s *PsbtBuilder) applyInputSig(sig *inputSig) error {
+	v := sig.signIn
+	s.logInput("psbt input signed", v.Index, "utxo_type", v.UtxoType, "sighash_type", v.SighashType,
+		"pubkey", s.sensitive(sig.pubKey), "signature", s.sensitive(sig.signature))
+	if v.UtxoType == Taproot {
+		s.PsbtUpdater.Upsbt.Inputs[v.Index].TaprootKeySpendSig = sig.signature
+	} else {
+		res, err := s.PsbtUpdater.Sign(v.Index, sig.signature, sig.pubKey, sig.redeemScript, nil)
+		if err = s.signOutcomeError(v.Index, res, err); err != nil {
+			return err
+		}
+	}
+	return s.maybeFinalize(v.Index)
+}
+
+// signTaprootKeySpend signs the key path of a taproot input. btcd's
+// TaprootWitnessSignature drops the sighash byte of non default sighash
+// types, it is appended here.
+func signTaprootKeySpend(tx *wire.MsgTx, sigHashes *txscript.TxSigHashes, index int, prevOut *wire.TxOut,
+	sighashType txscript.SigHashType, privateKey *btcec.PrivateKey) ([]byte, error) {
+	witness, err := txscript.TaprootWitnessSignature(tx, sigHashes, index, prevOut.Value, prevOut.PkScript,
+		sighashType, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	sig := witness[0]
+	if sighashType != txscript.SigHashDefault && len(sig) == schnorr.SignatureSize {
+		sig = append(sig, byte(sighashType))
+	}
+	return sig, nil
+}
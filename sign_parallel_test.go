@@ -0,0 +1,82 @@
+package psbt_sdk
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+)
+
+func testSignIns(nIn int) []*InputSign {
+	signIns := make([]*InputSign, 0, nIn)
+	for i := 0; i < nIn; i++ {
+		key := byte(i%5 + 1)
+		signIn := &InputSign{UtxoType: Witness, Index: i, PkScript: testP2wpkhScript(key), Amount: 30000,
+			SighashType: txscript.SigHashAll, PriHex: hex.EncodeToString(testPrivKey(key).Serialize())}
+		if i%2 == 1 {
+			signIn.UtxoType = Taproot
+			signIn.PkScript = testP2trScript(key)
+			signIn.SighashType = txscript.SigHashDefault
+		}
+		signIns = append(signIns, signIn)
+	}
+	return signIns
+}
+
+func TestPsbtBuilder_SetSignWorkers(t *testing.T) {
+	const nIn = 40
+	sequential := testBuilder(t, nIn)
+	if err := sequential.UpdateAndSignInput(testSignIns(nIn)); err != nil {
+		t.Fatalf("UpdateAndSignInput() error = %v", err)
+	}
+	want, err := sequential.ToString()
+	if err != nil {
+		t.Fatalf("ToString() error = %v", err)
+	}
+
+	for _, workers := range []int{2, 8, 64} {
+		parallel := testBuilder(t, nIn)
+		parallel.SetSignWorkers(workers)
+		if err = parallel.UpdateAndSignInput(testSignIns(nIn)); err != nil {
+			t.Fatalf("UpdateAndSignInput() workers %d error = %v", workers, err)
+		}
+		got, err := parallel.ToString()
+		if err != nil {
+			t.Fatalf("ToString() error = %v", err)
+		}
+		if got != want {
+			t.Fatalf("UpdateAndSignInput() workers %d differs from the sequential psbt", workers)
+		}
+	}
+
+	// a bad key fails the whole batch without partially signing it
+	signIns := testSignIns(nIn)
+	signIns[nIn-1].PriHex = "zz"
+	builder := testBuilder(t, nIn)
+	builder.SetSignWorkers(8)
+	if err = builder.UpdateAndSignInput(signIns); err == nil {
+		t.Fatalf("UpdateAndSignInput() with a bad key, want error")
+	}
+	if builder.PsbtUpdater.Upsbt.Inputs[0].FinalScriptWitness != nil {
+		t.Fatalf("UpdateAndSignInput() signed inputs of a failed batch")
+	}
+}
+
+func BenchmarkPsbtBuilder_SignWorkers(b *testing.B) {
+	const nIn = 500
+	signIns := testSignIns(nIn)
+	for _, workers := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("workers-%d", workers), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				b.StopTimer()
+				builder := testBuilder(b, nIn)
+				builder.SetSignWorkers(workers)
+				b.StartTimer()
+				if err := builder.UpdateAndSignInput(signIns); err != nil {
+					b.Fatalf("UpdateAndSignInput() error = %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package psbt_sdk
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+type InputVerifyResult struct {
+	Index     int
+	OutPoint  wire.OutPoint
+	Signed    bool
+	Finalized bool
+	Valid     bool
+	Err       error
+	// per signature results of a partially signed input
+	Signatures []*SignatureVerifyResult
+}
+
+type SignatureVerifyResult struct {
+	PubKey   []byte
+	LeafHash []byte
+	Valid    bool
+	Err      error
+}
+
+// Verify checks every signed or finalized input against its prevout. Finalized
+// inputs run through the script engine, partially signed inputs are checked
+// signature by signature. The error reports the first failing input.
+func (s *PsbtBuilder) Verify() ([]*InputVerifyResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var (
+		tx       = s.PsbtUpdater.Upsbt.UnsignedTx
+		results  = make([]*InputVerifyResult, 0, len(tx.TxIn))
+		firstErr error
+	)
+	cache := s.newSigHashCache()
+
+	for i, txIn := range tx.TxIn {
+		pIn := &s.PsbtUpdater.Upsbt.Inputs[i]
+		result := &InputVerifyResult{
+			Index:     i,
+			OutPoint:  txIn.PreviousOutPoint,
+			Finalized: pIn.FinalScriptSig != nil || pIn.FinalScriptWitness != nil,
+		}
+		result.Signed = result.Finalized || len(pIn.PartialSigs) > 0 ||
+			pIn.TaprootKeySpendSig != nil || len(pIn.TaprootScriptSpendSig) > 0
+		results = append(results, result)
+		if !result.Signed {
+			continue
+		}
+
+		prevOut := s.inputUtxo(i)
+		switch {
+		case prevOut == nil:
+			result.Err = ErrMissingUtxo
+		case !cache.complete && txscript.IsPayToTaproot(prevOut.PkScript):
+			result.Err = fmt.Errorf("%w, taproot verification requires the utxo of every input", ErrMissingUtxo)
+		case result.Finalized:
+			result.Err = s.verifyFinalizedInput(i, prevOut, cache)
+		default:
+			result.Signatures = s.verifyPartialSigs(i, prevOut, cache)
+			for _, sig := range result.Signatures {
+				if sig.Err != nil && result.Err == nil {
+					result.Err = sig.Err
+				}
+			}
+		}
+		result.Valid = result.Err == nil
+		if result.Err != nil && firstErr == nil {
+			firstErr = &InputError{Index: i, OutPoint: txIn.PreviousOutPoint, Err: result.Err}
+		}
+	}
+	return results, firstErr
+}
+
+func (s *PsbtBuilder) verifyFinalizedInput(index int, prevOut *wire.TxOut, cache *sigHashCache) error {
+	pIn := &s.PsbtUpdater.Upsbt.Inputs[index]
+	tx := s.PsbtUpdater.Upsbt.UnsignedTx.Copy()
+	tx.TxIn[index].SignatureScript = pIn.FinalScriptSig
+	if pIn.FinalScriptWitness != nil {
+		witness, err := parseTxWitness(pIn.FinalScriptWitness)
+		if err != nil {
+			return err
+		}
+		tx.TxIn[index].Witness = witness
+	}
+	vm, err := txscript.NewEngine(prevOut.PkScript, tx, index, txscript.StandardVerifyFlags,
+		nil, cache.sigHashes, prevOut.Value, cache.prevOutputFetcher)
+	if err != nil {
+		return err
+	}
+	return vm.Execute()
+}
+
+func (s *PsbtBuilder) verifyPartialSigs(index int, prevOut *wire.TxOut, cache *sigHashCache) []*SignatureVerifyResult {
+	var (
+		tx      = s.PsbtUpdater.Upsbt.UnsignedTx
+		pIn     = &s.PsbtUpdater.Upsbt.Inputs[index]
+		results = make([]*SignatureVerifyResult, 0)
+	)
+
+	for _, partialSig := range pIn.PartialSigs {
+		result := &SignatureVerifyResult{PubKey: partialSig.PubKey}
+		result.Err = verifyEcdsaPartialSig(tx, index, pIn, prevOut, partialSig, cache.sigHashes)
+		result.Valid = result.Err == nil
+		results = append(results, result)
+	}
+
+	if pIn.TaprootKeySpendSig != nil {
+		result := &SignatureVerifyResult{}
+		if txscript.IsPayToTaproot(prevOut.PkScript) {
+			result.PubKey = prevOut.PkScript[2:]
+			sig, hashType, err := splitSchnorrSig(pIn.TaprootKeySpendSig)
+			if err == nil {
+				var sigHash []byte
+				sigHash, err = txscript.CalcTaprootSignatureHash(cache.sigHashes, hashType, tx, index, cache.prevOutputFetcher)
+				if err == nil {
+					err = verifySchnorr(result.PubKey, sig, sigHash)
+				}
+			}
+			result.Err = err
+		} else {
+			result.Err = errors.New("taproot key spend signature on a non taproot input")
+		}
+		result.Valid = result.Err == nil
+		results = append(results, result)
+	}
+
+	for _, scriptSig := range pIn.TaprootScriptSpendSig {
+		result := &SignatureVerifyResult{PubKey: scriptSig.XOnlyPubKey, LeafHash: scriptSig.LeafHash}
+		leafScript, err := psbt.FindLeafScript(pIn, scriptSig.LeafHash)
+		if err == nil {
+			var sigHash []byte
+			leaf := txscript.NewTapLeaf(leafScript.LeafVersion, leafScript.Script)
+			sigHash, err = txscript.CalcTapscriptSignaturehash(cache.sigHashes, scriptSig.SigHash, tx, index, cache.prevOutputFetcher, leaf)
+			if err == nil {
+				err = verifySchnorr(scriptSig.XOnlyPubKey, scriptSig.Signature, sigHash)
+			}
+		}
+		result.Err = err
+		result.Valid = result.Err == nil
+		results = append(results, result)
+	}
+	return results
+}
+
+// verifyEcdsaPartialSig checks a legacy or segwit v0 partial signature
+// against the sighash of the script it commits to
+func verifyEcdsaPartialSig(tx *wire.MsgTx, index int, pIn *psbt.PInput, prevOut *wire.TxOut,
+	partialSig *psbt.PartialSig, sigHashes *txscript.TxSigHashes) error {
+	if len(partialSig.Signature) == 0 {
+		return errors.New("empty signature")
+	}
+	hashType := txscript.SigHashType(partialSig.Signature[len(partialSig.Signature)-1])
+	if pIn.SighashType != 0 && hashType != pIn.SighashType {
+		return fmt.Errorf("%w: signature sighash %d does not match input sighash %d", ErrInvalidSighash, hashType, pIn.SighashType)
+	}
+	sig, err := ecdsa.ParseDERSignature(partialSig.Signature[:len(partialSig.Signature)-1])
+	if err != nil {
+		return err
+	}
+	pubKey, err := btcec.ParsePubKey(partialSig.PubKey)
+	if err != nil {
+		return err
+	}
+
+	var (
+		pkScript = prevOut.PkScript
+		sigHash  []byte
+	)
+	if txscript.IsPayToScriptHash(pkScript) && pIn.RedeemScript != nil {
+		pkScript = pIn.RedeemScript
+	}
+	switch {
+	case txscript.IsPayToWitnessScriptHash(pkScript):
+		if pIn.WitnessScript == nil {
+			return errors.New("missing witness script")
+		}
+		sigHash, err = txscript.CalcWitnessSigHash(pIn.WitnessScript, sigHashes, hashType, tx, index, prevOut.Value)
+	case txscript.IsPayToWitnessPubKeyHash(pkScript):
+		sigHash, err = txscript.CalcWitnessSigHash(pkScript, sigHashes, hashType, tx, index, prevOut.Value)
+	default:
+		sigHash, err = txscript.CalcSignatureHash(pkScript, hashType, tx, index)
+	}
+	if err != nil {
+		return err
+	}
+	if !sig.Verify(sigHash, pubKey) {
+		return errors.New(fmt.Sprintf("invalid signature for pubkey %x", partialSig.PubKey))
+	}
+	return nil
+}
+
+// splitSchnorrSig splits a 64 or 65 byte taproot signature into the
+// signature and its sighash type
+func splitSchnorrSig(sig []byte) ([]byte, txscript.SigHashType, error) {
+	switch len(sig) {
+	case schnorr.SignatureSize:
+		return sig, txscript.SigHashDefault, nil
+	case schnorr.SignatureSize + 1:
+		if txscript.SigHashType(sig[64]) == txscript.SigHashDefault {
+			return nil, 0, errors.New("explicit default sighash byte")
+		}
+		return sig[:64], txscript.SigHashType(sig[64]), nil
+	}
+	return nil, 0, errors.New(fmt.Sprintf("invalid schnorr signature length %d", len(sig)))
+}
+
+func verifySchnorr(xOnlyPubKey, sig, sigHash []byte) error {
+	pubKey, err := schnorr.ParsePubKey(xOnlyPubKey)
+	if err != nil {
+		return err
+	}
+	signature, err := schnorr.ParseSignature(sig)
+	if err != nil {
+		return err
+	}
+	if !signature.Verify(sigHash, pubKey) {
+		return errors.New(fmt.Sprintf("invalid schnorr signature for pubkey %x", xOnlyPubKey))
+	}
+	return nil
+}
+
+// extractTx builds the final tx without psbt.Extract's 10000 byte item cap
+func extractTx(p *psbt.Packet) (*wire.MsgTx, error) {
+	if !p.IsComplete() {
+		return nil, psbt.ErrIncompletePSBT
+	}
+	tx := p.UnsignedTx.Copy()
+	for i := range p.Inputs {
+		tx.TxIn[i].SignatureScript = p.Inputs[i].FinalScriptSig
+		if p.Inputs[i].FinalScriptWitness == nil {
+			continue
+		}
+		witness, err := parseTxWitness(p.Inputs[i].FinalScriptWitness)
+		if err != nil {
+			return nil, err
+		}
+		tx.TxIn[i].Witness = witness
+	}
+	return tx, nil
+}
+
+// parseTxWitness decodes a serialized witness stack as stored in
+// FinalScriptWitness
+func parseTxWitness(b []byte) (wire.TxWitness, error) {
+	r := bytes.NewReader(b)
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	if count > uint64(len(b)) {
+		return nil, errors.New(fmt.Sprintf("invalid witness element count %d", count))
+	}
+	witness := make(wire.TxWitness, count)
+	for i := range witness {
+		witness[i], err = wire.ReadVarBytes(r, 0, uint32(len(b)), "witness")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.Len() != 0 {
+		return nil, errors.New("trailing bytes after witness")
+	}
+	return witness, nil
+}
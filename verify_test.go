@@ -0,0 +1,108 @@
+package psbt_sdk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+func testP2wpkhScript(i byte) string {
+	hash := btcutil.Hash160(testPrivKey(i).PubKey().SerializeCompressed())
+	return "0014" + hex.EncodeToString(hash)
+}
+
+func testP2trScript(i byte) string {
+	outputKey := txscript.ComputeTaprootKeyNoScript(testPrivKey(i).PubKey())
+	return "5120" + hex.EncodeToString(schnorr.SerializePubKey(outputKey))
+}
+
+func testBuilder(t testing.TB, nIn int) *PsbtBuilder {
+	inputs := make([]Input, 0, nIn)
+	for i := 0; i < nIn; i++ {
+		inputs = append(inputs, Input{OutTxId: "93b3484083c31769c6a82b9c1cb66979463d86b3edea5044dede1ffdb74c3db2", OutIndex: uint32(i)})
+	}
+	builder, err := CreatePsbtBuilder(&chaincfg.SigNetParams, inputs,
+		[]Output{{Script: "0014" + strings.Repeat("22", 20), Amount: 50000}})
+	if err != nil {
+		t.Fatalf("CreatePsbtBuilder() error = %v", err)
+	}
+	return builder
+}
+
+func TestPsbtBuilder_Verify(t *testing.T) {
+	builder := testBuilder(t, 2)
+	signIns := []*InputSign{
+		{UtxoType: Witness, Index: 0, PkScript: testP2wpkhScript(1), Amount: 40000, SighashType: txscript.SigHashAll,
+			PriHex: hex.EncodeToString(testPrivKey(1).Serialize())},
+		{UtxoType: Taproot, Index: 1, PkScript: testP2trScript(2), Amount: 30000, SighashType: txscript.SigHashDefault,
+			PriHex: hex.EncodeToString(testPrivKey(2).Serialize())},
+	}
+	if err := builder.UpdateAndAddInputWitness(signIns); err != nil {
+		t.Fatalf("UpdateAndAddInputWitness() error = %v", err)
+	}
+	if err := builder.UpdateAndSignInput(signIns[:1]); err != nil {
+		t.Fatalf("UpdateAndSignInput() error = %v", err)
+	}
+	if err := builder.UpdateAndSignTaprootInput(signIns[1:]); err != nil {
+		t.Fatalf("UpdateAndSignTaprootInput() error = %v", err)
+	}
+
+	results, err := builder.Verify()
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	for _, r := range results {
+		if !r.Signed || !r.Finalized || !r.Valid {
+			t.Fatalf("Verify() input %d = %+v, want finalized and valid", r.Index, r)
+		}
+	}
+
+	// corrupt the taproot signature inside the final witness
+	builder.PsbtUpdater.Upsbt.Inputs[1].FinalScriptWitness[5] ^= 0x01
+	results, err = builder.Verify()
+	if err == nil || !results[0].Valid || results[1].Valid || results[1].Err == nil {
+		t.Fatalf("Verify() after corruption = %v, want input 1 invalid", err)
+	}
+}
+
+func TestPsbtBuilder_VerifyPartialSigs(t *testing.T) {
+	expr := fmt.Sprintf("multi(2,%s,%s)", testPubHex(1, SegwitV0), testPubHex(2, SegwitV0))
+	ms, err := ParseMiniscript(expr, SegwitV0)
+	if err != nil {
+		t.Fatalf("ParseMiniscript() error = %v", err)
+	}
+	scriptHash := sha256.Sum256(ms.Script())
+
+	builder := testBuilder(t, 1)
+	signIn := &InputSign{UtxoType: Witness, Index: 0, PkScript: "0020" + hex.EncodeToString(scriptHash[:]),
+		Amount: 60000, SighashType: txscript.SigHashAll, Miniscript: expr,
+		PriHex: hex.EncodeToString(testPrivKey(2).Serialize())}
+	if err = builder.UpdateMiniscriptInput([]*InputSign{signIn}); err != nil {
+		t.Fatalf("UpdateMiniscriptInput() error = %v", err)
+	}
+	if err = builder.SignMiniscriptInput([]*InputSign{signIn}); err != nil {
+		t.Fatalf("SignMiniscriptInput() error = %v", err)
+	}
+
+	results, err := builder.Verify()
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if r := results[0]; r.Finalized || len(r.Signatures) != 1 || !r.Signatures[0].Valid {
+		t.Fatalf("Verify() = %+v, want one valid partial signature", r)
+	}
+
+	// a signature over a different amount no longer matches the sighash
+	builder.PsbtUpdater.Upsbt.Inputs[0].WitnessUtxo.Value++
+	results, err = builder.Verify()
+	if err == nil || results[0].Signatures[0].Valid {
+		t.Fatalf("Verify() after amount change = %v, want invalid signature", err)
+	}
+}